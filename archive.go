@@ -0,0 +1,214 @@
+package chromedb
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"testing/fstest"
+)
+
+// Archive is a zip or tar archive of one or more profiles, opened as a file
+// system for OpenProfileFS and the FS readers.
+type Archive struct {
+	fs.FS
+	closer io.Closer
+}
+
+func (a *Archive) Close() error {
+	if a.closer == nil {
+		return nil
+	}
+	return a.closer.Close()
+}
+
+// isArchiveName reports whether a file name has the extension of an archive
+// OpenArchive reads.
+func isArchiveName(name string) bool {
+	name = strings.ToLower(name)
+	for _, ext := range []string{".zip", ".tar", ".tar.gz", ".tgz"} {
+		if strings.HasSuffix(name, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// OpenArchive opens a zip archive, or a tar archive that may be gzipped.
+// Zip archives are read in place; tar archives, which can't be read at
+// random, are read into memory.
+func OpenArchive(name string) (*Archive, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	magic := make([]byte, 4)
+	if _, err := io.ReadFull(f, magic); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to read %s: %w", name, err)
+	}
+	if bytes.Equal(magic, []byte("PK\x03\x04")) {
+		f.Close()
+		zr, err := zip.OpenReader(name)
+		if err != nil {
+			return nil, err
+		}
+		return &Archive{FS: zr, closer: zr}, nil
+	}
+
+	defer f.Close()
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	var r io.Reader = bufio.NewReader(f)
+	if magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", name, err)
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	files, err := readTar(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", name, err)
+	}
+	return &Archive{FS: files}, nil
+}
+
+// readTar reads a tar archive's directories and regular files into memory.
+func readTar(r io.Reader) (fstest.MapFS, error) {
+	files := fstest.MapFS{}
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return files, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		name := path.Clean(strings.TrimPrefix(hdr.Name, "/"))
+		if !fs.ValidPath(name) || name == "." {
+			continue
+		}
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			files[name] = &fstest.MapFile{Mode: fs.ModeDir | fs.FileMode(hdr.Mode).Perm(), ModTime: hdr.ModTime}
+		case tar.TypeReg:
+			data, err := io.ReadAll(tr)
+			if err != nil {
+				return nil, err
+			}
+			files[name] = &fstest.MapFile{Data: data, Mode: fs.FileMode(hdr.Mode).Perm(), ModTime: hdr.ModTime}
+		}
+	}
+}
+
+// SplitArchivePath splits a path that runs into an archive, such as
+// profiles.zip/Default, into the archive's path and the directory within
+// it, which is "" if the path names the archive itself. It returns false if
+// no part of the path is an archive.
+func SplitArchivePath(p string) (archive, dir string, ok bool) {
+	var rest []string
+	for cur := filepath.Clean(p); ; cur = filepath.Dir(cur) {
+		if fi, err := os.Stat(cur); err == nil {
+			if fi.Mode().IsRegular() && isArchiveName(cur) {
+				return cur, path.Join(rest...), true
+			}
+			return "", "", false
+		}
+		parent := filepath.Dir(cur)
+		if parent == cur {
+			return "", "", false
+		}
+		rest = append([]string{filepath.Base(cur)}, rest...)
+	}
+}
+
+// findProfileDir returns the directory of the first profile in fsys: the
+// shallowest directory holding Preferences or any of the stores, or the root
+// if none does.
+func findProfileDir(fsys fs.FS) string {
+	best, bestDepth := ".", -1
+	fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || !d.IsDir() {
+			return nil
+		}
+		depth := strings.Count(name, "/")
+		if bestDepth >= 0 && depth >= bestDepth {
+			return fs.SkipDir
+		}
+		if isProfileDir(fsys, name) {
+			best, bestDepth = name, depth
+			return fs.SkipDir
+		}
+		return nil
+	})
+	return best
+}
+
+func isProfileDir(fsys fs.FS, dir string) bool {
+	if _, err := fs.Stat(fsys, path.Join(dir, "Preferences")); err == nil {
+		return true
+	}
+	for store := range storeLocations {
+		if _, err := ResolveStoreFS(fsys, dir, store); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// OpenProfileArchive opens the profile in directory dir of an archive, or
+// if dir is "", the first profile found in it. For snapshots written by
+// CollectProfile, the browser and any key recorded in the manifest are used
+// unless opts supplies a key. Closing the profile closes the archive.
+func OpenProfileArchive(archive, dir string, opts *ProfileOptions) (*Profile, error) {
+	a, err := OpenArchive(archive)
+	if err != nil {
+		return nil, err
+	}
+	m, err := ReadSnapshotManifest(a)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		a.Close()
+		return nil, err
+	}
+	if dir == "" {
+		if m != nil {
+			dir = m.Dir
+		} else {
+			dir = findProfileDir(a)
+		}
+	}
+
+	p, err := OpenProfileFS(a, dir, opts)
+	if err != nil {
+		a.Close()
+		return nil, err
+	}
+	if m != nil && dir == m.Dir {
+		p.Info.Browser, p.Info.BrowserName = m.Profile.Browser, m.Profile.BrowserName
+		p.Info.Name, p.Info.Android = m.Profile.Name, m.Profile.Android
+		p.Info.UserName, p.Info.GaiaName = m.Profile.UserName, m.Profile.GaiaName
+		if m.Key != nil && !p.haveKey && p.opts.KeySource == nil {
+			p.key, p.haveKey = m.Key, true
+		}
+	}
+	p.Path = filepath.Join(archive, filepath.FromSlash(dir))
+	p.archive = archive
+	p.closers = append(p.closers, func() { a.Close() })
+	return p, nil
+}
@@ -0,0 +1,210 @@
+package chromedb
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"testing/fstest"
+)
+
+// tarball returns a tar archive of the named files, with directories for
+// names ending in a slash.
+func tarball(t *testing.T, files ...[2]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, f := range files {
+		hdr := &tar.Header{Name: f[0], Mode: 0o644, Typeflag: tar.TypeReg, Size: int64(len(f[1]))}
+		if name := f[0]; name[len(name)-1] == '/' {
+			hdr.Mode, hdr.Typeflag, hdr.Size = 0o755, tar.TypeDir, 0
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(f[1])); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestReadTar(t *testing.T) {
+	data := tarball(t,
+		[2]string{"Default/", ""},
+		[2]string{"Default/Preferences", "{}"},
+		[2]string{"/Default/History", "absolute"},
+		[2]string{"./Default/Bookmarks", "dotted"},
+		[2]string{"../escape", "x"},
+		[2]string{"Default/../../escape", "x"},
+		[2]string{"//etc/passwd", "x"},
+		[2]string{"/", ""},
+	)
+	files, err := readTar(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("readTar: %v", err)
+	}
+
+	var names []string
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	want := []string{"Default", "Default/Bookmarks", "Default/History", "Default/Preferences"}
+	if len(names) != len(want) {
+		t.Fatalf("readTar names = %q, want %q", names, want)
+	}
+	for i := range want {
+		if names[i] != want[i] {
+			t.Fatalf("readTar names = %q, want %q", names, want)
+		}
+	}
+	if !files["Default"].Mode.IsDir() {
+		t.Error("Default is not a directory")
+	}
+	if got := string(files["Default/History"].Data); got != "absolute" {
+		t.Errorf("Default/History = %q, want %q", got, "absolute")
+	}
+
+	if _, err := readTar(bytes.NewReader(data[:700])); err == nil {
+		t.Error("readTar of a truncated archive succeeded, want error")
+	}
+}
+
+func TestOpenArchive(t *testing.T) {
+	var zipped bytes.Buffer
+	zw := zip.NewWriter(&zipped)
+	w, err := zw.Create("Default/Preferences")
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write([]byte("{}"))
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	tarred := tarball(t, [2]string{"Default/Preferences", "{}"})
+	var gzipped bytes.Buffer
+	gw := gzip.NewWriter(&gzipped)
+	gw.Write(tarred)
+	if err := gw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The format is told from the contents, not the extension.
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"profile.zip", zipped.Bytes()},
+		{"profile.tar", tarred},
+		{"profile.tar.gz", gzipped.Bytes()},
+		{"profile.tgz", gzipped.Bytes()},
+		{"gzipped.zip", gzipped.Bytes()},
+	}
+	dir := t.TempDir()
+	for _, tt := range tests {
+		name := filepath.Join(dir, tt.name)
+		if err := os.WriteFile(name, tt.data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		a, err := OpenArchive(name)
+		if err != nil {
+			t.Errorf("OpenArchive(%s): %v", tt.name, err)
+			continue
+		}
+		if data, err := fs.ReadFile(a, "Default/Preferences"); err != nil || string(data) != "{}" {
+			t.Errorf("OpenArchive(%s): Default/Preferences = %q, %v", tt.name, data, err)
+		}
+		a.Close()
+	}
+
+	for name, data := range map[string][]byte{
+		"short.zip":     []byte("PK"),
+		"badgzip.tgz":   {0x1f, 0x8b, 0, 0},
+		"truncated.tar": tarred[:100],
+	} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if a, err := OpenArchive(path); err == nil {
+			a.Close()
+			t.Errorf("OpenArchive(%s) succeeded, want error", name)
+		}
+	}
+}
+
+func TestSplitArchivePath(t *testing.T) {
+	dir := t.TempDir()
+	archive := filepath.Join(dir, "profiles.zip")
+	if err := os.WriteFile(archive, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "notes.txt"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(dir, "Default"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path        string
+		wantArchive string
+		wantDir     string
+		wantOK      bool
+	}{
+		{archive, archive, "", true},
+		{filepath.Join(archive, "Default"), archive, "Default", true},
+		{filepath.Join(archive, "User Data", "Profile 1"), archive, "User Data/Profile 1", true},
+		{filepath.Join(dir, "Default"), "", "", false},
+		{filepath.Join(dir, "Default", "missing"), "", "", false},
+		{filepath.Join(dir, "notes.txt", "Default"), "", "", false},
+		{filepath.Join(dir, "missing.zip", "Default"), "", "", false},
+	}
+	for _, tt := range tests {
+		archive, dir, ok := SplitArchivePath(tt.path)
+		if archive != tt.wantArchive || dir != tt.wantDir || ok != tt.wantOK {
+			t.Errorf("SplitArchivePath(%q) = %q, %q, %v; want %q, %q, %v", tt.path, archive, dir, ok, tt.wantArchive, tt.wantDir, tt.wantOK)
+		}
+	}
+}
+
+func TestFindProfileDir(t *testing.T) {
+	tests := []struct {
+		name string
+		fsys fstest.MapFS
+		want string
+	}{
+		{"profile at the root", fstest.MapFS{"Preferences": {}}, "."},
+		{"user data directory", fstest.MapFS{
+			"Local State":               {},
+			"Default/Preferences":       {},
+			"Profile 1/Network/Cookies": {},
+		}, "Default"},
+		{"store without Preferences", fstest.MapFS{"home/chrome/Profile 2/History": {}}, "home/chrome/Profile 2"},
+		{"shallowest wins", fstest.MapFS{
+			"a/b/c/Preferences": {},
+			"z/Default/History": {},
+		}, "z/Default"},
+		{"nested profile ignored", fstest.MapFS{
+			"Default/Preferences":                   {},
+			"Default/Extensions/x/History":          {},
+			"Default/Local Storage/leveldb/CURRENT": {},
+		}, "Default"},
+		{"no profile", fstest.MapFS{"notes.txt": {}}, "."},
+	}
+	for _, tt := range tests {
+		if got := findProfileDir(tt.fsys); got != tt.want {
+			t.Errorf("%s: findProfileDir = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package chromedb
+
+import (
+	"encoding/json"
+	"io/fs"
+	"strconv"
+	"time"
+)
+
+type Bookmark struct {
+	ID           string    `json:"id"`
+	GUID         string    `json:"guid"`
+	Path         string    `json:"path"`
+	Name         string    `json:"name"`
+	URL          string    `json:"url"`
+	DateAdded    time.Time `json:"date_added"`
+	DateLastUsed time.Time `json:"date_last_used"`
+}
+
+type bookmarkNode struct {
+	ID           string         `json:"id"`
+	GUID         string         `json:"guid"`
+	Name         string         `json:"name"`
+	Type         string         `json:"type"`
+	URL          string         `json:"url"`
+	DateAdded    string         `json:"date_added"`
+	DateLastUsed string         `json:"date_last_used"`
+	Children     []bookmarkNode `json:"children"`
+}
+
+type bookmarksFile struct {
+	Roots map[string]json.RawMessage `json:"roots"`
+}
+
+// chromeTimeString converts a Chromium timestamp stored as a decimal string,
+// as the profile's JSON files do.
+func chromeTimeString(s string) time.Time {
+	microseconds, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return time.Time{}
+	}
+	return chromeTime(microseconds)
+}
+
+// GetBookmarks reads the bookmark tree from a profile's Bookmarks file and
+// flattens it, giving each bookmark the path of the folders containing it.
+func GetBookmarks(bookmarksPath string) ([]Bookmark, error) {
+	return GetBookmarksFS(osFS(bookmarksPath))
+}
+
+// GetBookmarksFS is GetBookmarks for a Bookmarks file in fsys.
+func GetBookmarksFS(fsys fs.FS, name string) ([]Bookmark, error) {
+	data, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return nil, err
+	}
+
+	var f bookmarksFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, err
+	}
+
+	var bookmarks []Bookmark
+	for _, root := range []string{"bookmark_bar", "other", "synced"} {
+		raw, ok := f.Roots[root]
+		if !ok {
+			continue
+		}
+		var node bookmarkNode
+		if err := json.Unmarshal(raw, &node); err != nil {
+			return nil, err
+		}
+		bookmarks = flattenBookmarks(bookmarks, node, "")
+	}
+	return bookmarks, nil
+}
+
+func flattenBookmarks(bookmarks []Bookmark, node bookmarkNode, parent string) []Bookmark {
+	if node.Type == "url" {
+		return append(bookmarks, Bookmark{
+			ID:           node.ID,
+			GUID:         node.GUID,
+			Path:         parent,
+			Name:         node.Name,
+			URL:          node.URL,
+			DateAdded:    chromeTimeString(node.DateAdded),
+			DateLastUsed: chromeTimeString(node.DateLastUsed),
+		})
+	}
+
+	folder := node.Name
+	if parent != "" {
+		folder = parent + "/" + node.Name
+	}
+	for _, child := range node.Children {
+		bookmarks = flattenBookmarks(bookmarks, child, folder)
+	}
+	return bookmarks
+}
@@ -0,0 +1,362 @@
+package chromedb
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/syndtr/goleveldb/leveldb/util"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+const (
+	serviceWorkerRegistrationKeyPrefix = "REG:"
+	serviceWorkerResourceKeyPrefix     = "RES:"
+)
+
+type CacheStorageRecord struct {
+	Origin          string            `json:"origin"`
+	CacheName       string            `json:"cache_name"`
+	URL             string            `json:"url"`
+	Method          string            `json:"method"`
+	RequestHeaders  map[string]string `json:"request_headers"`
+	Status          int               `json:"status"`
+	StatusText      string            `json:"status_text"`
+	ResponseHeaders map[string]string `json:"response_headers"`
+	ResponseTime    time.Time         `json:"response_time"`
+	DecodeError     string            `json:"decode_error,omitempty"`
+	Decoded         string            `json:"-"`
+	MIME            string            `json:"mime"`
+	Conversions     []string          `json:"conversions"`
+	JsonType        string            `json:"-"`
+	Value           json.RawMessage   `json:"value"`
+}
+
+type CacheStoreDb struct {
+	Records []CacheStorageRecord `json:"records"`
+}
+
+type cacheStorageIndex struct {
+	origin string
+	caches map[string]string // cache directory to cache name
+}
+
+// readCacheStorageIndex parses an origin's index.txt, a CacheStorageIndex
+// protobuf listing its caches and the directories holding them.
+func readCacheStorageIndex(fsys fs.FS, name string) (*cacheStorageIndex, error) {
+	data, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return nil, err
+	}
+	fields, err := parseProtoFields(data)
+	if err != nil {
+		return nil, err
+	}
+
+	index := &cacheStorageIndex{caches: map[string]string{}}
+	for _, f := range fields {
+		switch f.Number {
+		case 1: // cache
+			cache, err := parseProtoFields(f.Bytes)
+			if err != nil {
+				return nil, err
+			}
+			var name, dir string
+			for _, cf := range cache {
+				switch cf.Number {
+				case 1:
+					name = string(cf.Bytes)
+				case 2:
+					dir = string(cf.Bytes)
+				}
+			}
+			index.caches[dir] = name
+		case 2, 3: // origin, superseded by storage key
+			if len(f.Bytes) > 0 {
+				index.origin = string(f.Bytes)
+			}
+		}
+	}
+	return index, nil
+}
+
+// parseCacheHeaders decodes repeated CacheHeaderMap name/value messages,
+// joining repeated headers.
+func parseCacheHeaders(headers map[string]string, b []byte) error {
+	fields, err := parseProtoFields(b)
+	if err != nil {
+		return err
+	}
+	var name, value string
+	for _, f := range fields {
+		switch f.Number {
+		case 1:
+			name = string(f.Bytes)
+		case 2:
+			value = string(f.Bytes)
+		}
+	}
+	if prev, ok := headers[name]; ok {
+		value = prev + ", " + value
+	}
+	headers[name] = value
+	return nil
+}
+
+// parseCacheMetadata decodes the CacheMetadata protobuf that Cache Storage
+// keeps in stream 0 of each entry in place of the HTTP cache's metadata.
+func parseCacheMetadata(r *CacheStorageRecord, b []byte) error {
+	fields, err := parseProtoFields(b)
+	if err != nil {
+		return err
+	}
+
+	r.RequestHeaders = map[string]string{}
+	r.ResponseHeaders = map[string]string{}
+	for _, f := range fields {
+		if f.Type != protowire.BytesType || (f.Number != 1 && f.Number != 2) {
+			continue
+		}
+		sub, err := parseProtoFields(f.Bytes)
+		if err != nil {
+			return err
+		}
+
+		switch f.Number {
+		case 1: // request
+			for _, rf := range sub {
+				switch rf.Number {
+				case 1:
+					r.Method = string(rf.Bytes)
+				case 2:
+					if err := parseCacheHeaders(r.RequestHeaders, rf.Bytes); err != nil {
+						return err
+					}
+				}
+			}
+		case 2: // response
+			var urls []string
+			for _, rf := range sub {
+				switch rf.Number {
+				case 1:
+					r.Status = int(int32(rf.Uint))
+				case 2:
+					r.StatusText = string(rf.Bytes)
+				case 4:
+					if err := parseCacheHeaders(r.ResponseHeaders, rf.Bytes); err != nil {
+						return err
+					}
+				case 6:
+					r.ResponseTime = chromeTime(int64(rf.Uint))
+				case 8:
+					urls = append(urls, string(rf.Bytes))
+				}
+			}
+			// The response URL list ends with the final URL after redirects.
+			if len(urls) > 0 && r.URL == "" {
+				r.URL = urls[len(urls)-1]
+			}
+		}
+	}
+	return nil
+}
+
+// LoadCacheStorage reads the Cache Storage API caches in dir, a profile's
+// Service Worker/CacheStorage directory. Each origin's directory holds an
+// index naming its caches, and each cache is a simple cache whose entries
+// are keyed by request URL.
+func LoadCacheStorage(dir string) (*CacheStoreDb, error) {
+	return LoadCacheStorageFS(osFS(dir))
+}
+
+// LoadCacheStorageFS is LoadCacheStorage for a CacheStorage directory in
+// fsys.
+func LoadCacheStorageFS(fsys fs.FS, dir string) (*CacheStoreDb, error) {
+	origins, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	csd := &CacheStoreDb{}
+	for _, o := range origins {
+		if !o.IsDir() {
+			continue
+		}
+		originDir := path.Join(dir, o.Name())
+
+		index, err := readCacheStorageIndex(fsys, path.Join(originDir, "index.txt"))
+		if err != nil {
+			// Without an index the caches can still be read, just not named.
+			index = &cacheStorageIndex{caches: map[string]string{}}
+		}
+
+		caches, err := fs.ReadDir(fsys, originDir)
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range caches {
+			if !c.IsDir() {
+				continue
+			}
+			entries, err := readSimpleCacheDir(fsys, path.Join(originDir, c.Name()))
+			if err != nil {
+				return nil, err
+			}
+
+			for _, e := range entries {
+				r := CacheStorageRecord{
+					Origin:    index.origin,
+					CacheName: index.caches[c.Name()],
+					URL:       simpleCacheKeyURL(e.Key),
+					Decoded:   string(e.Stream1),
+				}
+				// An entry whose metadata can't be parsed keeps its URL
+				// and body.
+				if err := parseCacheMetadata(&r, e.Stream0); err != nil {
+					r.DecodeError = fmt.Sprintf("failed to parse metadata: %v", err)
+				}
+				csd.Records = append(csd.Records, r)
+			}
+		}
+	}
+
+	return csd, nil
+}
+
+func CacheStorageRecordToJson(r CacheStorageRecord) (string, error) {
+	v, err := classifyValue(r.Decoded)
+	if err != nil {
+		return "", err
+	}
+
+	r.MIME = v.MIME
+	r.Conversions = v.Conversions
+	r.Value = v.Value
+	r.JsonType = v.JsonType
+	recordJson, err := json.Marshal(r)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal record to JSON: %w", err)
+	}
+
+	return string(recordJson), nil
+}
+
+type ServiceWorkerRegistration struct {
+	Origin          string    `json:"origin"`
+	RegistrationID  int64     `json:"registration_id"`
+	Scope           string    `json:"scope"`
+	ScriptURL       string    `json:"script_url"`
+	VersionID       int64     `json:"version_id"`
+	IsActive        bool      `json:"is_active"`
+	HasFetchHandler bool      `json:"has_fetch_handler"`
+	LastUpdateCheck time.Time `json:"last_update_check"`
+	Resources       []string  `json:"resources"`
+	Script          string    `json:"script,omitempty"`
+}
+
+type serviceWorkerResource struct {
+	id  int64
+	url string
+}
+
+// GetServiceWorkers lists the service workers registered in dir, a profile's
+// Service Worker directory, from the REG: records of its Database LevelDB.
+// Each worker's main script is read from the ScriptCache alongside it.
+func GetServiceWorkers(dir string) ([]ServiceWorkerRegistration, error) {
+	return GetServiceWorkersFS(osFS(dir))
+}
+
+// GetServiceWorkersFS is GetServiceWorkers for a Service Worker directory in
+// fsys.
+func GetServiceWorkersFS(fsys fs.FS, dir string) ([]ServiceWorkerRegistration, error) {
+	db, err := openLevelDB(fsys, path.Join(dir, "Database"))
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	// Resources are keyed by version ID.
+	resources := map[int64][]serviceWorkerResource{}
+	iter := db.NewIterator(util.BytesPrefix([]byte(serviceWorkerResourceKeyPrefix)), nil)
+	for iter.Next() {
+		version, _, _ := strings.Cut(strings.TrimPrefix(string(iter.Key()), serviceWorkerResourceKeyPrefix), "\x00")
+		versionID, err := strconv.ParseInt(version, 10, 64)
+		if err != nil {
+			continue
+		}
+		fields, err := parseProtoFields(iter.Value())
+		if err != nil {
+			continue
+		}
+		var res serviceWorkerResource
+		for _, f := range fields {
+			switch f.Number {
+			case 1:
+				res.id = int64(f.Uint)
+			case 2:
+				res.url = string(f.Bytes)
+			}
+		}
+		resources[versionID] = append(resources[versionID], res)
+	}
+	iter.Release()
+	if err := iter.Error(); err != nil {
+		return nil, err
+	}
+
+	scripts := map[string][]byte{}
+	if entries, err := readSimpleCacheDir(fsys, path.Join(dir, "ScriptCache")); err == nil {
+		for _, e := range entries {
+			scripts[e.Key] = e.Stream1
+		}
+	}
+
+	var regs []ServiceWorkerRegistration
+	iter = db.NewIterator(util.BytesPrefix([]byte(serviceWorkerRegistrationKeyPrefix)), nil)
+	for iter.Next() {
+		origin, _, _ := strings.Cut(strings.TrimPrefix(string(iter.Key()), serviceWorkerRegistrationKeyPrefix), "\x00")
+		fields, err := parseProtoFields(iter.Value())
+		if err != nil {
+			continue
+		}
+
+		reg := ServiceWorkerRegistration{Origin: origin, Resources: []string{}}
+		for _, f := range fields {
+			switch f.Number {
+			case 1:
+				reg.RegistrationID = int64(f.Uint)
+			case 2:
+				reg.Scope = string(f.Bytes)
+			case 3:
+				reg.ScriptURL = string(f.Bytes)
+			case 4:
+				reg.VersionID = int64(f.Uint)
+			case 5:
+				reg.IsActive = f.Uint != 0
+			case 6:
+				reg.HasFetchHandler = f.Uint != 0
+			case 7:
+				reg.LastUpdateCheck = chromeTime(int64(f.Uint))
+			}
+		}
+		for _, res := range resources[reg.VersionID] {
+			reg.Resources = append(reg.Resources, res.url)
+			if res.url == reg.ScriptURL {
+				reg.Script = string(scripts[strconv.FormatInt(res.id, 10)])
+			}
+		}
+		sort.Strings(reg.Resources)
+		regs = append(regs, reg)
+	}
+	iter.Release()
+	if err := iter.Error(); err != nil {
+		return nil, err
+	}
+
+	return regs, nil
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/noperator/chromedb"
+)
+
+// emitFunc outputs one record's JSON.
+type emitFunc func(j []byte)
+
+func printJSON(j []byte) {
+	fmt.Println(string(j))
+}
+
+// dumpCookies outputs the profile's cookies, then those set by extensions.
+// Each cookie's file field says which of the two it came from.
+func dumpCookies(profile *chromedb.Profile, emit emitFunc) error {
+	cookies, err := profile.Cookies()
+	if err != nil {
+		return err
+	}
+
+	for _, c := range cookies {
+		j, err := json.Marshal(c)
+		if err != nil {
+			return fmt.Errorf("failed to convert cookie to JSON: %w", err)
+		}
+		emit(j)
+	}
+	return nil
+}
+
+func dumpLocalStorage(profile *chromedb.Profile, emit emitFunc) error {
+	lsd, err := profile.LocalStorage()
+	if err != nil {
+		return err
+	}
+
+	for _, r := range lsd.Records {
+		j, err := chromedb.LocalStorageRecordToJson(r)
+		if err != nil {
+			return fmt.Errorf("failed to convert record to JSON: %w", err)
+		}
+		emit([]byte(j))
+	}
+	return nil
+}
+
+func dumpSessionStorage(profile *chromedb.Profile, emit emitFunc) error {
+	ssd, err := profile.SessionStorage()
+	if err != nil {
+		return err
+	}
+
+	for _, r := range ssd.Records {
+		j, err := chromedb.SessionStorageRecordToJson(r)
+		if err != nil {
+			return fmt.Errorf("failed to convert record to JSON: %w", err)
+		}
+		emit([]byte(j))
+	}
+	return nil
+}
+
+// keyCache fetches each browser's key at most once, since its profiles
+// share it.
+type keyCache map[string]*cachedKey
+
+type cachedKey struct {
+	key []byte
+	err error
+}
+
+// get is a ProfileOptions.KeySource.
+func (c keyCache) get(p chromedb.BrowserProfile) ([]byte, error) {
+	id := p.Browser
+	if id == "" {
+		id = p.UserDataDir
+	}
+	k, ok := c[id]
+	if !ok {
+		key, err := chromedb.GetBrowserKey(p)
+		k = &cachedKey{key: key, err: err}
+		c[id] = k
+	}
+	return k.key, k.err
+}
+
+// tagJSON adds the browser and profile a record came from to the front of
+// its JSON object.
+func tagJSON(j []byte, p chromedb.BrowserProfile) []byte {
+	browser := p.Browser
+	if browser == "" {
+		browser = p.BrowserName
+	}
+	tag, _ := json.Marshal(struct {
+		Browser     string `json:"browser"`
+		Profile     string `json:"profile"`
+		ProfilePath string `json:"profile_path"`
+	}{browser, p.Name, p.Path})
+
+	if len(j) < 2 || j[0] != '{' {
+		return j
+	}
+	if string(j) == "{}" {
+		return tag
+	}
+	return append(append(tag[:len(tag)-1], ','), j[1:]...)
+}
+
+// runAllProfiles dumps cookies, local storage or session storage from each
+// profile in turn. Profiles without the store are skipped; failures are
+// reported on stderr and the remaining profiles still read.
+func runAllProfiles(profiles []chromedb.BrowserProfile, cookies, localStorage, sessionStorage, raw bool) {
+	keys := keyCache{}
+	failed := false
+	for _, p := range profiles {
+		p := p
+		emit := func(j []byte) { printJSON(tagJSON(j, p)) }
+
+		var (
+			store chromedb.Store
+			dump  func(*chromedb.Profile, emitFunc) error
+		)
+		switch {
+		case cookies:
+			store, dump = chromedb.StoreCookies, dumpCookies
+		case localStorage:
+			store, dump = chromedb.StoreLocalStorage, dumpLocalStorage
+		case sessionStorage:
+			store, dump = chromedb.StoreSessionStorage, dumpSessionStorage
+		}
+
+		profile, err := chromedb.OpenProfile(p.Path, profileOptions(&chromedb.ProfileOptions{KeySource: keys.get, Raw: raw}))
+		if err == nil {
+			if !profile.Has(store) {
+				profile.Close()
+				continue
+			}
+			err = dump(profile, emit)
+			profile.Close()
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading %s profile %q (%s): %v\n", p.BrowserName, p.Name, p.Path, err)
+			failed = true
+		}
+	}
+
+	if failed {
+		os.Exit(1)
+	}
+}
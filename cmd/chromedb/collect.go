@@ -0,0 +1,124 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/noperator/chromedb"
+)
+
+func runCollect(args []string) {
+	fs := flag.NewFlagSet("collect", flag.ExitOnError)
+	selection := profileFlags(fs)
+	out := fs.String("o", "", "zip file to write the snapshot to (required)")
+	skipCache := fs.Bool("nocache", false, "leave out the HTTP cache")
+	saveKey := fs.Bool("savekey", false, "record the profile's key in the manifest, so cookies and passwords can be decrypted on another machine")
+	verify := fs.String("verify", "", "check the files of this snapshot against the hashes in its manifest instead of collecting one")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: chromedb collect (-p <profile> | -browser <browser> [-profile <name>]) -o <snapshot.zip>")
+		fmt.Fprintln(fs.Output(), "       chromedb collect -verify <snapshot.zip>")
+		fs.PrintDefaults()
+	}
+
+	fs.Parse(args)
+
+	if *verify != "" {
+		verifySnapshot(*verify)
+		return
+	}
+
+	profilePath := selection.resolve()
+	if *out == "" {
+		fmt.Println("Error: -o flag is required")
+		fs.Usage()
+		os.Exit(1)
+	}
+	if _, _, ok := chromedb.SplitArchivePath(profilePath); ok {
+		fmt.Println("Error: can't collect a profile inside an archive")
+		os.Exit(1)
+	}
+	if inside(profilePath, *out) {
+		fmt.Println("Error: -o can't be inside the profile")
+		os.Exit(1)
+	}
+
+	opts := &chromedb.CollectOptions{SkipCache: *skipCache}
+	if *saveKey {
+		key, err := chromedb.GetProfileKey(profilePath)
+		if err != nil {
+			fmt.Println("Error getting key:", err)
+			os.Exit(1)
+		}
+		opts.Key = key
+	}
+
+	f, err := os.Create(*out)
+	if err != nil {
+		fmt.Println("Error creating snapshot:", err)
+		os.Exit(1)
+	}
+	m, err := chromedb.CollectProfile(profilePath, f, opts)
+	if err == nil {
+		err = f.Close()
+	} else {
+		f.Close()
+	}
+	if err != nil {
+		os.Remove(*out)
+		fmt.Println("Error collecting profile:", err)
+		os.Exit(1)
+	}
+
+	var size int64
+	for _, file := range m.Files {
+		size += file.Size
+	}
+	for _, s := range m.Skipped {
+		fmt.Fprintf(os.Stderr, "Skipped %s: %s\n", s.Name, s.Error)
+	}
+	fmt.Fprintf(os.Stderr, "Collected %d files (%d bytes) from %s into %s\n", len(m.Files), size, profilePath, *out)
+}
+
+// verifySnapshot reports the files of a snapshot that don't match its
+// manifest, exiting with an error if there are any.
+func verifySnapshot(name string) {
+	a, err := chromedb.OpenArchive(name)
+	if err != nil {
+		fmt.Println("Error opening snapshot:", err)
+		os.Exit(1)
+	}
+	defer a.Close()
+
+	m, err := chromedb.ReadSnapshotManifest(a)
+	if err != nil {
+		fmt.Println("Error reading manifest:", err)
+		os.Exit(1)
+	}
+	bad, err := chromedb.VerifySnapshot(a, m)
+	if err != nil {
+		fmt.Println("Error verifying snapshot:", err)
+		os.Exit(1)
+	}
+	for _, b := range bad {
+		fmt.Println("Mismatch", b)
+	}
+	if len(bad) > 0 {
+		a.Close()
+		os.Exit(1)
+	}
+	fmt.Fprintf(os.Stderr, "All %d files match\n", len(m.Files))
+}
+
+// inside reports whether p is within dir.
+func inside(dir, p string) bool {
+	dir, err1 := filepath.Abs(dir)
+	p, err2 := filepath.Abs(p)
+	if err1 != nil || err2 != nil {
+		return false
+	}
+	rel, err := filepath.Rel(dir, p)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
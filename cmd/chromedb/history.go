@@ -0,0 +1,48 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/noperator/chromedb"
+)
+
+func runHistory(args []string) {
+	fs := flag.NewFlagSet("history", flag.ExitOnError)
+	selection := profileFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: chromedb history (-p <profile> | -browser <browser> [-profile <name>])")
+		fs.PrintDefaults()
+	}
+
+	fs.Parse(args)
+
+	profile := openProfile(selection.resolve(), &chromedb.ProfileOptions{})
+	defer profile.Close()
+
+	printHistory(profile)
+}
+
+// printHistory prints the profile's visits, downloads, search terms and
+// segments, each tagged with its table.
+func printHistory(profile *chromedb.Profile) {
+	h, err := profile.History()
+	if err != nil {
+		fmt.Println("Error opening History database:", err)
+		os.Exit(1)
+	}
+
+	for _, v := range h.Visits {
+		printTableRecord("visits", v)
+	}
+	for _, d := range h.Downloads {
+		printTableRecord("downloads", d)
+	}
+	for _, t := range h.SearchTerms {
+		printTableRecord("keyword_search_terms", t)
+	}
+	for _, s := range h.Segments {
+		printTableRecord("segments", s)
+	}
+}
@@ -5,90 +5,297 @@ import (
 	"flag"
 	"fmt"
 	"os"
-	"path/filepath"
+	"regexp"
+	"strings"
 
 	"github.com/noperator/chromedb"
 )
 
 func main() {
 
-	browserPath := flag.String("p", "", "path to browser profile directory (required)")
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "set", "delete":
+			runWrite(os.Args[1], os.Args[2:])
+			return
+		case "history":
+			runHistory(os.Args[2:])
+			return
+		case "visited":
+			runVisited(os.Args[2:])
+			return
+		case "profiles":
+			runProfiles(os.Args[2:])
+			return
+		case "collect":
+			runCollect(os.Args[2:])
+			return
+		}
+	}
+
+	selection := profileFlags(flag.CommandLine)
+	allProfiles := flag.Bool("all-profiles", false, "with -c, -ls or -ss, read every profile found (or every profile in the user data directory given with -p, or of the browser given with -browser)")
 	cookies := flag.Bool("c", false, "cookies")
 	localStorage := flag.Bool("ls", false, "local storage")
 	sessionStorage := flag.Bool("ss", false, "session storage")
+	indexedDb := flag.Bool("idb", false, "IndexedDB")
+	// -h takes the place of the flag package's help flag, so help is -help.
+	history := flag.Bool("h", false, "history, as chromedb history prints (this replaces -h as the help flag; use -help)")
+	logins := flag.Bool("l", false, "saved logins")
+	webData := flag.Bool("w", false, "web data (autofill, addresses, payment cards, search engines, OAuth tokens)")
+	bookmarks := flag.Bool("bm", false, "bookmarks")
+	accounts := flag.Bool("acct", false, "signed-in accounts")
+	contentSettings := flag.Bool("cs", false, "per-site content settings")
+	extensions := flag.Bool("ext", false, "installed extensions")
+	localState := flag.Bool("state", false, "browser and profile info from Local State")
+	extStorage := flag.Bool("es", false, "extension storage (chrome.storage.local and chrome.storage.sync)")
+	cacheStorage := flag.Bool("cst", false, "Cache Storage API caches")
+	serviceWorkers := flag.Bool("sw", false, "registered service workers")
+	httpCache := flag.Bool("cache", false, "HTTP disk cache")
+	webSQL := flag.Bool("wsql", false, "Web SQL databases")
+	hsts := flag.Bool("hsts", false, "HSTS and Expect-CT entries from TransportSecurity")
+	hosts := flag.String("host", "", "with -hsts, comma-separated hostnames to look up among the hashed entries")
+	networkState := flag.Bool("nps", false, "servers and alternative services from Network Persistent State")
+	shortcuts := flag.Bool("sc", false, "omnibox shortcuts (typed text and the suggestion picked)")
+	topSites := flag.Bool("top", false, "most visited sites from Top Sites")
+	predictor := flag.Bool("nap", false, "typed text and resulting URLs from Network Action Predictor")
+	favicons := flag.Bool("fav", false, "favicons and the pages that use them")
+	faviconDir := flag.String("favdir", "", "with -fav, write favicon bitmaps to this directory")
+	syncData := flag.Bool("sync", false, "entities stored by the sync engine")
+	syncDevices := flag.Bool("dev", false, "devices registered to the synced account")
+	urlFilter := flag.String("url", "", "with -cache, only read entries whose URL matches this regular expression")
+	raw := flag.Bool("raw", false, "with -ls or -ss, read raw LevelDB files and include deleted and overwritten records")
 
 	flag.Parse()
 
-	if *browserPath == "" {
-		fmt.Println("Error: -p flag (path to browser profile directory) is required")
+	// Check for mutually exclusive flags.
+	flagCount := 0
+	for _, mode := range []*bool{
+		cookies, localStorage, sessionStorage, indexedDb, history, logins, webData,
+		bookmarks, accounts, contentSettings, extensions, localState, extStorage,
+		cacheStorage, serviceWorkers, httpCache, webSQL, hsts, networkState,
+		shortcuts, topSites, predictor, favicons, syncData, syncDevices,
+	} {
+		if *mode {
+			flagCount++
+		}
+	}
+
+	if flagCount != 1 {
+		fmt.Println("Error: Please specify exactly one of -c, -ls, -ss, -idb, -h, -l, -w, -bm, -acct, -cs, -ext, -state, -es, -cst, -sw, -cache, -wsql, -hsts, -nps, -sc, -top, -nap, -fav, -sync, or -dev")
 		flag.Usage()
 		os.Exit(1)
 	}
 
-	// Check for mutually exclusive flags.
-	flagCount := 0
+	if *allProfiles {
+		if !*cookies && !*localStorage && !*sessionStorage {
+			fmt.Println("Error: -all-profiles only works with -c, -ls or -ss")
+			flag.Usage()
+			os.Exit(1)
+		}
+		runAllProfiles(selection.all(), *cookies, *localStorage, *sessionStorage, *raw)
+		return
+	}
+
+	profile := openProfile(selection.resolve(), &chromedb.ProfileOptions{Raw: *raw})
+	defer profile.Close()
+
 	if *cookies {
-		flagCount++
+		if err := dumpCookies(profile, printJSON); err != nil {
+			fmt.Println("Error:", err)
+			os.Exit(1)
+		}
 	}
+
 	if *localStorage {
-		flagCount++
+		if err := dumpLocalStorage(profile, printJSON); err != nil {
+			fmt.Println("Error:", err)
+			os.Exit(1)
+		}
 	}
+
 	if *sessionStorage {
-		flagCount++
+		if err := dumpSessionStorage(profile, printJSON); err != nil {
+			fmt.Println("Error:", err)
+			os.Exit(1)
+		}
 	}
 
-	if flagCount != 1 {
-		fmt.Println("Error: Please specify exactly one of -c, -ls, or -ss")
-		flag.Usage()
-		os.Exit(1)
+	if *indexedDb {
+		dbs, err := profile.IndexedDB()
+		if err != nil {
+			fmt.Println("Error:", err)
+			os.Exit(1)
+		}
+
+		for _, isd := range dbs {
+			for _, r := range isd.Records {
+				j, err := chromedb.IndexedDbRecordToJson(r)
+				if err != nil {
+					fmt.Println("Error converting record to JSON:", err)
+					os.Exit(1)
+				}
+
+				fmt.Println(j)
+			}
+		}
 	}
 
-	if *cookies {
+	if *history {
+		printHistory(profile)
+	}
 
-		cookiesPath := filepath.Join(*browserPath, "Cookies")
-		cookies, err := chromedb.GetCookies(cookiesPath)
+	if *logins {
+		logins, err := profile.Logins()
 		if err != nil {
-			fmt.Println("Error opening Cookies database:", err)
+			fmt.Println("Error:", err)
 			os.Exit(1)
 		}
 
-		key, err := chromedb.GetKey()
+		for _, l := range logins {
+			printRecord(l)
+		}
+	}
+
+	if *webData {
+		wd, err := profile.WebData()
 		if err != nil {
-			fmt.Println("Error getting key:", err)
+			fmt.Println("Error:", err)
 			os.Exit(1)
 		}
 
-		for _, c := range cookies {
-			if len(c.EncryptedValue) > 0 {
-				value, err := chromedb.DecryptValue(c.EncryptedValue, key, c.Domain)
-				if err != nil {
-					fmt.Printf("Failed to decrypt cookie %s: %v\n", c.Name, err)
-				}
-				c.Value = value
+		for _, a := range wd.Autofill {
+			printTableRecord("autofill", a)
+		}
+		for _, a := range wd.Addresses {
+			printTableRecord("addresses", a)
+		}
+		for _, c := range wd.CreditCards {
+			printTableRecord("credit_cards", c)
+		}
+		for _, k := range wd.Keywords {
+			printTableRecord("keywords", k)
+		}
+		for _, t := range wd.Tokens {
+			printTableRecord("token_service", t)
+		}
+	}
+
+	if *bookmarks {
+		bookmarks, err := profile.Bookmarks()
+		if err != nil {
+			fmt.Println("Error reading Bookmarks:", err)
+			os.Exit(1)
+		}
+
+		for _, b := range bookmarks {
+			printRecord(b)
+		}
+	}
+
+	if *accounts || *contentSettings || *extensions {
+		prefs, err := profile.Preferences()
+		if err != nil {
+			fmt.Println("Error reading Preferences:", err)
+			os.Exit(1)
+		}
+
+		if *accounts {
+			for _, a := range prefs.Accounts {
+				printRecord(a)
+			}
+		}
+		if *contentSettings {
+			for _, cs := range prefs.ContentSettings {
+				printRecord(cs)
+			}
+		}
+		if *extensions {
+			for _, e := range prefs.Extensions {
+				printRecord(e)
 			}
+		}
+	}
+
+	if *localState {
+		ls, err := profile.LocalState()
+		if err != nil {
+			fmt.Println("Error reading Local State:", err)
+			os.Exit(1)
+		}
+
+		printRecord(ls)
+	}
+
+	if *extStorage {
+		esd, err := profile.ExtensionStorage()
+		if err != nil {
+			fmt.Println("Error:", err)
+			os.Exit(1)
+		}
 
-			j, err := json.Marshal(c)
+		for _, r := range esd.Records {
+			j, err := chromedb.ExtensionStorageRecordToJson(r)
 			if err != nil {
-				fmt.Println("Error converting cookie to JSON:", err)
+				fmt.Println("Error converting record to JSON:", err)
 				os.Exit(1)
 			}
 
-			fmt.Println(string(j))
+			fmt.Println(j)
 		}
 	}
 
-	if *localStorage {
-		localStoragePath := filepath.Join(*browserPath, "Local Storage/leveldb")
+	if *cacheStorage {
+		dbs, err := profile.CacheStorage()
+		if err != nil {
+			fmt.Println("Error:", err)
+			os.Exit(1)
+		}
+
+		for _, csd := range dbs {
+			for _, r := range csd.Records {
+				j, err := chromedb.CacheStorageRecordToJson(r)
+				if err != nil {
+					fmt.Println("Error converting record to JSON:", err)
+					os.Exit(1)
+				}
 
-		lsd, err := chromedb.LoadLocalStorage(localStoragePath)
+				fmt.Println(j)
+			}
+		}
+	}
+
+	if *serviceWorkers {
+		regs, err := profile.ServiceWorkers()
 		if err != nil {
 			fmt.Println("Error opening LevelDB:", err)
 			os.Exit(1)
 		}
-		defer lsd.Close()
 
-		for _, r := range lsd.Records {
-			j, err := chromedb.LocalStorageRecordToJson(r)
+		for _, r := range regs {
+			printRecord(r)
+		}
+	}
+
+	if *httpCache {
+		var filter *regexp.Regexp
+		if *urlFilter != "" {
+			var err error
+			filter, err = regexp.Compile(*urlFilter)
+			if err != nil {
+				fmt.Println("Error parsing -url:", err)
+				os.Exit(1)
+			}
+		}
+
+		hcd, err := profile.HTTPCache(filter)
+		if err != nil {
+			fmt.Println("Error reading HTTP cache:", err)
+			os.Exit(1)
+		}
+
+		for _, r := range hcd.Records {
+			j, err := chromedb.HTTPCacheRecordToJson(r)
 			if err != nil {
 				fmt.Println("Error converting record to JSON:", err)
 				os.Exit(1)
@@ -98,18 +305,15 @@ func main() {
 		}
 	}
 
-	if *sessionStorage {
-		sessionStoragePath := filepath.Join(*browserPath, "Session Storage")
-
-		ssd, err := chromedb.LoadSessionStorage(sessionStoragePath)
+	if *webSQL {
+		wsd, err := profile.WebSQL()
 		if err != nil {
-			fmt.Println("Error opening LevelDB:", err)
+			fmt.Println("Error reading Web SQL databases:", err)
 			os.Exit(1)
 		}
-		defer ssd.Close()
 
-		for _, r := range ssd.Records {
-			j, err := chromedb.SessionStorageRecordToJson(r)
+		for _, r := range wsd.Records {
+			j, err := chromedb.WebSQLRecordToJson(r)
 			if err != nil {
 				fmt.Println("Error converting record to JSON:", err)
 				os.Exit(1)
@@ -118,4 +322,177 @@ func main() {
 			fmt.Println(j)
 		}
 	}
+
+	if *hsts {
+		ts, err := profile.TransportSecurity()
+		if err != nil {
+			fmt.Println("Error reading TransportSecurity:", err)
+			os.Exit(1)
+		}
+
+		if *hosts == "" {
+			for _, e := range ts.Entries {
+				printTableRecord("hsts", e)
+			}
+			for _, e := range ts.ExpectCT {
+				printTableRecord("expect_ct", e)
+			}
+		}
+
+		for _, host := range strings.Split(*hosts, ",") {
+			if host = strings.TrimSpace(host); host == "" {
+				continue
+			}
+			e, found := ts.LookupHost(host)
+			lookup := struct {
+				Host  string              `json:"host"`
+				Found bool                `json:"found"`
+				Entry *chromedb.HSTSEntry `json:"entry,omitempty"`
+			}{Host: host, Found: found}
+			if found {
+				lookup.Entry = &e
+			}
+			printRecord(lookup)
+		}
+	}
+
+	if *networkState {
+		ns, err := profile.NetworkState()
+		if err != nil {
+			fmt.Println("Error reading Network Persistent State:", err)
+			os.Exit(1)
+		}
+
+		printRecord(ns)
+	}
+
+	if *shortcuts {
+		sc, err := profile.Shortcuts()
+		if err != nil {
+			fmt.Println("Error opening Shortcuts database:", err)
+			os.Exit(1)
+		}
+
+		for _, s := range sc {
+			printRecord(s)
+		}
+	}
+
+	if *topSites {
+		sites, err := profile.TopSites()
+		if err != nil {
+			fmt.Println("Error opening Top Sites database:", err)
+			os.Exit(1)
+		}
+
+		for _, s := range sites {
+			printRecord(s)
+		}
+	}
+
+	if *predictor {
+		entries, err := profile.NetworkActionPredictor()
+		if err != nil {
+			fmt.Println("Error opening Network Action Predictor database:", err)
+			os.Exit(1)
+		}
+
+		for _, e := range entries {
+			printRecord(e)
+		}
+	}
+
+	if *favicons {
+		icons, err := profile.Favicons()
+		if err != nil {
+			fmt.Println("Error opening Favicons database:", err)
+			os.Exit(1)
+		}
+
+		if *faviconDir != "" {
+			if err := os.MkdirAll(*faviconDir, 0755); err != nil {
+				fmt.Println("Error creating favicon directory:", err)
+				os.Exit(1)
+			}
+		}
+
+		for _, f := range icons {
+			if *faviconDir != "" {
+				if err := chromedb.WriteFavicon(&f, *faviconDir); err != nil {
+					fmt.Println("Error writing favicon:", err)
+					os.Exit(1)
+				}
+			}
+			printRecord(f)
+		}
+	}
+
+	if *syncData || *syncDevices {
+		sd, err := profile.SyncData()
+		if err != nil {
+			fmt.Println("Error:", err)
+			os.Exit(1)
+		}
+
+		if *syncDevices {
+			for _, d := range sd.Devices {
+				printRecord(d)
+			}
+		} else {
+			for _, e := range sd.Entities {
+				printRecord(e)
+			}
+		}
+	}
+}
+
+// openProfile opens the profile at path, exiting on failure. The path may
+// run into a zip or tar archive, as in profiles.zip/Default.
+func openProfile(path string, opts *chromedb.ProfileOptions) *chromedb.Profile {
+	var (
+		profile *chromedb.Profile
+		err     error
+	)
+	if archive, dir, ok := chromedb.SplitArchivePath(path); ok {
+		profile, err = chromedb.OpenProfileArchive(archive, dir, profileOptions(opts))
+	} else {
+		profile, err = chromedb.OpenProfile(path, profileOptions(opts))
+	}
+	if err != nil {
+		fmt.Println("Error opening profile:", err)
+		os.Exit(1)
+	}
+	return profile
+}
+
+// profileOptions has each store reported on stderr as it's read, and values
+// that can't be decrypted reported on stdout.
+func profileOptions(opts *chromedb.ProfileOptions) *chromedb.ProfileOptions {
+	opts.OnStore = func(store chromedb.Store, path string) {
+		fmt.Fprintln(os.Stderr, "Reading", path)
+	}
+	opts.OnDecryptError = func(err error) {
+		fmt.Println("Failed to decrypt", err)
+	}
+	return opts
+}
+
+func printRecord(record interface{}) {
+	j, err := json.Marshal(record)
+	if err != nil {
+		fmt.Println("Error converting record to JSON:", err)
+		os.Exit(1)
+	}
+
+	fmt.Println(string(j))
+}
+
+// tableRecord tags a record from a multi-table database with its table.
+type tableRecord struct {
+	Table  string      `json:"table"`
+	Record interface{} `json:"record"`
+}
+
+func printTableRecord(table string, record interface{}) {
+	printRecord(tableRecord{Table: table, Record: record})
 }
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/noperator/chromedb"
+)
+
+func runProfiles(args []string) {
+	fs := flag.NewFlagSet("profiles", flag.ExitOnError)
+	browser := fs.String("browser", "", "only list profiles of this browser, e.g. chrome or arc")
+	path := fs.String("p", "", "list the profiles in this user data directory, or in this Android /data/data directory, instead of the ones installed")
+
+	fs.Parse(args)
+
+	profiles := chromedb.DiscoverProfiles()
+	if *path != "" {
+		profiles = chromedb.UserDataDirProfiles(*path)
+	}
+
+	for _, p := range profiles {
+		if *browser != "" && !strings.EqualFold(p.Browser, *browser) && !strings.EqualFold(p.BrowserName, *browser) {
+			continue
+		}
+		printRecord(p)
+	}
+}
+
+// profileSelection holds the flags that select which profile to read.
+type profileSelection struct {
+	fs      *flag.FlagSet
+	path    *string
+	browser *string
+	profile *string
+}
+
+func profileFlags(fs *flag.FlagSet) *profileSelection {
+	return &profileSelection{
+		fs:      fs,
+		path:    fs.String("p", "", "path to browser profile directory, or to a zip or tar archive of one, optionally followed by the profile's directory within it (required unless -browser is given)"),
+		browser: fs.String("browser", "", "browser to read, e.g. chrome, edge, brave or arc, in place of -p (see chromedb profiles)"),
+		profile: fs.String("profile", "", "with -browser, profile directory or display name (default: last used)"),
+	}
+}
+
+// resolve returns the profile directory to read: -p if given, otherwise the
+// named profile of the named browser.
+func (s *profileSelection) resolve() string {
+	if *s.path != "" {
+		if *s.browser != "" || *s.profile != "" {
+			s.fail(fmt.Errorf("-p can't be combined with -browser or -profile"))
+		}
+		return *s.path
+	}
+	if *s.browser == "" {
+		s.fail(fmt.Errorf("-p flag (path to browser profile directory) or -browser is required"))
+	}
+
+	p, err := chromedb.FindProfile(*s.browser, *s.profile)
+	if err != nil {
+		s.fail(err)
+	}
+	return p.Path
+}
+
+// all returns every profile to read with -all-profiles: those in the user
+// data directory given with -p, those of the browser given with -browser, or
+// otherwise every profile found on this machine.
+func (s *profileSelection) all() []chromedb.BrowserProfile {
+	if *s.profile != "" {
+		s.fail(fmt.Errorf("-profile can't be combined with -all-profiles"))
+	}
+
+	var profiles []chromedb.BrowserProfile
+	switch {
+	case *s.path != "" && *s.browser != "":
+		s.fail(fmt.Errorf("-p can't be combined with -browser"))
+	case *s.path != "":
+		profiles = chromedb.UserDataDirProfiles(*s.path)
+	case *s.browser != "":
+		var err error
+		if profiles, err = chromedb.BrowserProfiles(*s.browser); err != nil {
+			s.fail(err)
+		}
+	default:
+		profiles = chromedb.DiscoverProfiles()
+	}
+	if len(profiles) == 0 {
+		s.fail(fmt.Errorf("no profiles found"))
+	}
+	return profiles
+}
+
+func (s *profileSelection) fail(err error) {
+	fmt.Println("Error:", err)
+	s.fs.Usage()
+	os.Exit(1)
+}
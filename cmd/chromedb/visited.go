@@ -0,0 +1,43 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/noperator/chromedb"
+)
+
+func runVisited(args []string) {
+	fs := flag.NewFlagSet("visited", flag.ExitOnError)
+	selection := profileFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: chromedb visited (-p <profile> | -browser <browser> [-profile <name>]) <url>...")
+		fs.PrintDefaults()
+	}
+
+	fs.Parse(args)
+
+	browserPath := selection.resolve()
+	if fs.NArg() == 0 {
+		fmt.Println("Error: at least one URL is required")
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	profile := openProfile(browserPath, &chromedb.ProfileOptions{})
+	defer profile.Close()
+
+	vl, err := profile.VisitedLinks()
+	if err != nil {
+		fmt.Println("Error reading Visited Links:", err)
+		os.Exit(1)
+	}
+
+	for _, u := range fs.Args() {
+		printRecord(struct {
+			URL     string `json:"url"`
+			Visited bool   `json:"visited"`
+		}{URL: u, Visited: vl.IsVisited(u)})
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/noperator/chromedb"
+)
+
+// runWrite implements the set and delete subcommands, which modify local or
+// session storage in a profile that isn't in use.
+func runWrite(command string, args []string) {
+	fs := flag.NewFlagSet(command, flag.ExitOnError)
+	selection := profileFlags(fs)
+	localStorage := fs.Bool("ls", false, "local storage")
+	sessionStorage := fs.Bool("ss", false, "session storage")
+	origin := fs.String("origin", "", "with -ls, storage key (origin) to modify, e.g. https://example.com")
+	mapID := fs.Int("map", -1, "with -ss, session storage map ID to modify")
+	key := fs.String("key", "", "key to "+command+" (required)")
+	value := fs.String("value", "", "value to set")
+
+	fs.Parse(args)
+
+	browserPath := selection.resolve()
+	if *key == "" {
+		fmt.Println("Error: -key flag is required")
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	if *localStorage == *sessionStorage {
+		fmt.Println("Error: Please specify exactly one of -ls or -ss")
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	if _, _, ok := chromedb.SplitArchivePath(browserPath); ok {
+		fmt.Printf("Error: can't %s in a profile inside an archive\n", command)
+		os.Exit(1)
+	}
+
+	profile := openProfile(browserPath, &chromedb.ProfileOptions{})
+	defer profile.Close()
+
+	var err error
+	switch {
+	case *localStorage:
+		if *origin == "" {
+			fmt.Println("Error: -origin flag is required with -ls")
+			fs.Usage()
+			os.Exit(1)
+		}
+		var dir string
+		if dir, err = profile.Resolve(chromedb.StoreLocalStorage); err != nil {
+			fmt.Println("Error:", err)
+			os.Exit(1)
+		}
+		if command == "set" {
+			err = chromedb.SetLocalStorage(dir, *origin, *key, *value)
+		} else {
+			err = chromedb.DeleteLocalStorage(dir, *origin, *key)
+		}
+
+	case *sessionStorage:
+		if *mapID < 0 {
+			fmt.Println("Error: -map flag is required with -ss")
+			fs.Usage()
+			os.Exit(1)
+		}
+		var dir string
+		if dir, err = profile.Resolve(chromedb.StoreSessionStorage); err != nil {
+			fmt.Println("Error:", err)
+			os.Exit(1)
+		}
+		if command == "set" {
+			err = chromedb.SetSessionStorage(dir, *mapID, *key, *value)
+		} else {
+			err = chromedb.DeleteSessionStorage(dir, *mapID, *key)
+		}
+	}
+
+	if err != nil {
+		fmt.Printf("Error running %s: %v\n", command, err)
+		os.Exit(1)
+	}
+}
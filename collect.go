@@ -0,0 +1,432 @@
+package chromedb
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+)
+
+// SnapshotManifestName is the name of the manifest at the root of a snapshot
+// written by CollectProfile.
+const SnapshotManifestName = "manifest.json"
+
+// profileExtras are the files and directories in a profile read alongside the
+// stores in storeLocations.
+var profileExtras = []string{
+	"Preferences",
+	"Secure Preferences",
+	"Local State",
+	"Local Extension Settings",
+	"Sync Extension Settings",
+	"WebStorage",
+}
+
+// CollectOptions controls what CollectProfile adds to a snapshot.
+type CollectOptions struct {
+	// SkipCache leaves out the HTTP cache, usually the largest store.
+	SkipCache bool
+
+	// Key, if set, is recorded in the manifest so the snapshot's secrets
+	// can be decrypted on another machine.
+	Key []byte
+}
+
+// SnapshotFile is a file in a snapshot, as it was when collected.
+type SnapshotFile struct {
+	Name    string    `json:"name"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+	SHA256  string    `json:"sha256"`
+}
+
+// SnapshotSkip is a file that couldn't be collected, or the HTTP cache if
+// it wasn't found.
+type SnapshotSkip struct {
+	Name  string `json:"name"`
+	Error string `json:"error"`
+}
+
+// SnapshotManifest describes a snapshot: where and when it was collected,
+// from which browser, and the hash of every file in it.
+type SnapshotManifest struct {
+	Profile        BrowserProfile `json:"profile"`
+	Dir            string         `json:"dir"`
+	BrowserVersion string         `json:"browser_version"`
+	Hostname       string         `json:"hostname"`
+	OS             string         `json:"os"`
+	Started        time.Time      `json:"started"`
+	Finished       time.Time      `json:"finished"`
+	Key            []byte         `json:"key,omitempty"`
+	Files          []SnapshotFile `json:"files"`
+	Skipped        []SnapshotSkip `json:"skipped,omitempty"`
+}
+
+// CollectProfile writes a zip snapshot of the profile at profilePath to w:
+// every store, with the journals of its SQLite databases, along with
+// Preferences, extension manifests and the browser's Local State, under the
+// profile's directory name. The HTTP cache is read from wherever
+// ResolveStore finds it, which may be the OS cache directory, and stored
+// under the profile's directory name too. A manifest at the root records each file's
+// SHA-256 hash. The snapshot can be read with OpenProfileArchive.
+//
+// Files are read without taking the browser's locks, as openLevelDB's
+// snapshot does, so the profile can be in use. LevelDB directories are read
+// into memory first and read again if the browser compacts them meanwhile.
+// Files that still can't be read are listed in the manifest as skipped.
+func CollectProfile(profilePath string, w io.Writer, opts *CollectOptions) (*SnapshotManifest, error) {
+	if opts == nil {
+		opts = &CollectOptions{}
+	}
+	fi, err := os.Stat(profilePath)
+	if err != nil {
+		return nil, err
+	}
+	if !fi.IsDir() {
+		return nil, fmt.Errorf("%s is not a directory", profilePath)
+	}
+
+	fsys, dir := osFS(profilePath)
+	m := &SnapshotManifest{
+		Profile: ProfileForPath(profilePath),
+		Dir:     path.Base(dir),
+		OS:      runtime.GOOS,
+		Started: time.Now().UTC(),
+		Key:     opts.Key,
+	}
+	m.Hostname, _ = os.Hostname()
+
+	c := &collector{fsys: fsys, dir: dir, m: m, zw: zip.NewWriter(w)}
+
+	var names []string
+	for store, locations := range storeLocations {
+		if store == StoreHTTPCache {
+			continue
+		}
+		names = append(names, locations...)
+	}
+	names = append(names, profileExtras...)
+	sort.Strings(names)
+	prev := ""
+	for _, name := range names {
+		// Skip locations within one already collected.
+		if prev != "" && strings.HasPrefix(name, prev+"/") {
+			continue
+		}
+		prev = name
+		if err := c.collect(path.Join(dir, name)); err != nil {
+			return nil, err
+		}
+	}
+	if err := c.collectExtensionManifests(); err != nil {
+		return nil, err
+	}
+	if !opts.SkipCache {
+		if err := c.collectCache(profilePath); err != nil {
+			return nil, err
+		}
+	}
+
+	// Local State and Last Version live in the user data directory, which
+	// goes at the root, unless the profile is the user data directory.
+	userDataDir := path.Dir(dir)
+	localState := path.Join(dir, "Local State")
+	if _, err := fs.Stat(fsys, localState); err != nil {
+		localState = path.Join(userDataDir, "Local State")
+		for _, name := range []string{"Local State", "Last Version"} {
+			if err := c.collectFile(path.Join(userDataDir, name), name); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if ls, err := GetLocalStateFS(fsys, localState); err == nil {
+		m.BrowserVersion = ls.BrowserVersion
+	}
+	if m.BrowserVersion == "" {
+		if data, err := fs.ReadFile(fsys, path.Join(userDataDir, "Last Version")); err == nil {
+			m.BrowserVersion = strings.TrimSpace(string(data))
+		}
+	}
+
+	m.Finished = time.Now().UTC()
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := c.write(SnapshotManifestName, m.Finished, data); err != nil {
+		return nil, err
+	}
+	if err := c.zw.Close(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// collector adds files from a profile in fsys to a snapshot.
+type collector struct {
+	fsys fs.FS
+	dir  string
+	m    *SnapshotManifest
+	zw   *zip.Writer
+}
+
+// archiveName returns the name in the snapshot of a file in the profile.
+func (c *collector) archiveName(name string) string {
+	return path.Join(c.m.Dir, strings.TrimPrefix(strings.TrimPrefix(name, c.dir), "/"))
+}
+
+func (c *collector) skip(name string, err error) {
+	c.m.Skipped = append(c.m.Skipped, SnapshotSkip{Name: name, Error: err.Error()})
+}
+
+// collect adds a file with its SQLite journals, or a directory, if it
+// exists.
+func (c *collector) collect(name string) error {
+	fi, err := fs.Stat(c.fsys, name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		c.skip(c.archiveName(name), err)
+		return nil
+	}
+	if fi.IsDir() {
+		return c.collectDir(name)
+	}
+	for _, suffix := range []string{"", "-wal", "-journal"} {
+		if err := c.collectFile(name+suffix, c.archiveName(name+suffix)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// collectDir adds a directory's files, reading each LevelDB directory within
+// it as a unit.
+func (c *collector) collectDir(dir string) error {
+	return fs.WalkDir(c.fsys, dir, func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			c.skip(c.archiveName(name), err)
+			return nil
+		}
+		if d.IsDir() {
+			if _, err := fs.Stat(c.fsys, path.Join(name, "CURRENT")); err == nil {
+				if err := c.collectLevelDB(name); err != nil {
+					return err
+				}
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		return c.collectFile(name, c.archiveName(name))
+	})
+}
+
+// levelDBFile is a file of a LevelDB directory read into memory.
+type levelDBFile struct {
+	name    string
+	modTime time.Time
+	data    []byte
+}
+
+// collectLevelDB adds a LevelDB directory, skipping the LOCK and logs as
+// newSnapshotStorage does. A file removed by a compaction while the
+// directory is read means the rest may be from after it, so the directory is
+// read again.
+func (c *collector) collectLevelDB(dir string) error {
+	const attempts = 3
+
+	var (
+		files []levelDBFile
+		err   error
+	)
+	for i := 0; i < attempts; i++ {
+		files, err = readLevelDBFiles(c.fsys, dir)
+		if !errors.Is(err, fs.ErrNotExist) {
+			break
+		}
+	}
+	if err != nil {
+		c.skip(c.archiveName(dir), err)
+		return nil
+	}
+
+	for _, f := range files {
+		if err := c.write(c.archiveName(f.name), f.modTime, f.data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func readLevelDBFiles(fsys fs.FS, dir string) ([]levelDBFile, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []levelDBFile
+	for _, e := range entries {
+		name := e.Name()
+		if !e.Type().IsRegular() || name == "LOCK" || strings.HasPrefix(name, "LOG") {
+			continue
+		}
+		fi, err := e.Info()
+		if err != nil {
+			return nil, err
+		}
+		data, err := fs.ReadFile(fsys, path.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, levelDBFile{name: path.Join(dir, name), modTime: fi.ModTime(), data: data})
+	}
+	return files, nil
+}
+
+// collectFile adds a file, if it exists, as archiveName.
+func (c *collector) collectFile(name, archiveName string) error {
+	f, err := c.fsys.Open(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		c.skip(archiveName, err)
+		return nil
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		c.skip(archiveName, err)
+		return nil
+	}
+	if !fi.Mode().IsRegular() {
+		return nil
+	}
+
+	// Read the file before adding its entry, so one that fails part way
+	// through is skipped rather than truncated.
+	var buf bytes.Buffer
+	if _, err := io.Copy(&buf, f); err != nil {
+		c.skip(archiveName, err)
+		return nil
+	}
+	return c.write(archiveName, fi.ModTime(), buf.Bytes())
+}
+
+// collectCache adds the HTTP cache of the profile at profilePath, recording
+// it as skipped if it isn't found.
+func (c *collector) collectCache(profilePath string) error {
+	dir, loc, err := resolveStoreLocation(profilePath, StoreHTTPCache)
+	if err != nil {
+		c.skip(path.Join(c.m.Dir, storeLocations[StoreHTTPCache][0]), err)
+		return nil
+	}
+	// Collect it relative to the directory it was found in, so it has the
+	// same name in the snapshot wherever the browser kept it.
+	_, name := osFS(dir)
+	cache := &collector{fsys: c.fsys, dir: name, m: c.m, zw: c.zw}
+	return cache.collect(path.Join(name, loc))
+}
+
+// collectExtensionManifests adds the manifest and messages of each installed
+// extension, which Preferences reads for extension names, but not the
+// extensions' code.
+func (c *collector) collectExtensionManifests() error {
+	var names []string
+	for _, pattern := range []string{"*/*/manifest.json", "*/*/_locales/*/messages.json"} {
+		matches, err := fs.Glob(c.fsys, path.Join(c.dir, "Extensions", pattern))
+		if err != nil {
+			return err
+		}
+		names = append(names, matches...)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := c.collectFile(name, c.archiveName(name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// write adds a file to the snapshot and records it in the manifest.
+func (c *collector) write(name string, modTime time.Time, data []byte) error {
+	fw, err := c.zw.CreateHeader(&zip.FileHeader{
+		Name:     name,
+		Method:   zip.Deflate,
+		Modified: modTime,
+	})
+	if err != nil {
+		return err
+	}
+	if _, err := fw.Write(data); err != nil {
+		return err
+	}
+
+	if name != SnapshotManifestName {
+		sum := sha256.Sum256(data)
+		c.m.Files = append(c.m.Files, SnapshotFile{
+			Name:    name,
+			Size:    int64(len(data)),
+			ModTime: modTime.UTC(),
+			SHA256:  hex.EncodeToString(sum[:]),
+		})
+	}
+	return nil
+}
+
+// ReadSnapshotManifest reads the manifest of a snapshot written by
+// CollectProfile.
+func ReadSnapshotManifest(fsys fs.FS) (*SnapshotManifest, error) {
+	data, err := fs.ReadFile(fsys, SnapshotManifestName)
+	if err != nil {
+		return nil, err
+	}
+	var m SnapshotManifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", SnapshotManifestName, err)
+	}
+	return &m, nil
+}
+
+// VerifySnapshot checks every file in a snapshot against the hash in its
+// manifest, returning the names of those that don't match or are missing.
+func VerifySnapshot(fsys fs.FS, m *SnapshotManifest) ([]string, error) {
+	var bad []string
+	for _, f := range m.Files {
+		r, err := fsys.Open(f.Name)
+		if errors.Is(err, fs.ErrNotExist) {
+			bad = append(bad, f.Name)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		h := sha256.New()
+		_, err = io.Copy(h, r)
+		r.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", f.Name, err)
+		}
+		if hex.EncodeToString(h.Sum(nil)) != f.SHA256 {
+			bad = append(bad, f.Name)
+		}
+	}
+	return bad, nil
+}
@@ -0,0 +1,140 @@
+package chromedb
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestCollectProfileRoundTrip(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Windows keeps the cache in the profile")
+	}
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, ".config"))
+	t.Setenv("XDG_CACHE_HOME", filepath.Join(home, ".cache"))
+	configDir, _ := os.UserConfigDir()
+	cacheDir, _ := os.UserCacheDir()
+
+	userDataDir := filepath.Join(configDir, "google-chrome")
+	profile := filepath.Join(userDataDir, "Default")
+	transportSecurity, err := os.ReadFile("testdata/TransportSecurity")
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string][]byte{
+		filepath.Join(userDataDir, "Last Version"):                                          []byte("120.0.6099.109\n"),
+		filepath.Join(profile, "Preferences"):                                               []byte(`{}`),
+		filepath.Join(profile, "Network", "TransportSecurity"):                              transportSecurity,
+		filepath.Join(cacheDir, "google-chrome", "Default", "Cache", "Cache_Data", "index"): []byte("cache index"),
+	}
+	for name, data := range files {
+		if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(name, data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	localStorage := filepath.Join(profile, "Local Storage", "leveldb")
+	writeLevelDB(t, localStorage, false)
+	if err := SetLocalStorage(localStorage, "https://example.com", "token", "héllo"); err != nil {
+		t.Fatal(err)
+	}
+
+	snapshot := filepath.Join(t.TempDir(), "snapshot.zip")
+	f, err := os.Create(snapshot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := CollectProfile(profile, f, nil)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		t.Fatalf("CollectProfile: %v", err)
+	}
+	if m.Dir != "Default" || m.BrowserVersion != "120.0.6099.109" {
+		t.Errorf("manifest Dir, BrowserVersion = %q, %q", m.Dir, m.BrowserVersion)
+	}
+	if len(m.Skipped) != 0 {
+		t.Errorf("manifest Skipped = %+v, want none", m.Skipped)
+	}
+	collected := map[string]bool{}
+	for _, f := range m.Files {
+		collected[f.Name] = true
+	}
+	for _, name := range []string{"Default/Cache/Cache_Data/index", "Default/Network/TransportSecurity", "Default/Preferences", "Last Version"} {
+		if !collected[name] {
+			t.Errorf("%s not collected", name)
+		}
+	}
+
+	p, err := OpenProfileArchive(snapshot, "", nil)
+	if err != nil {
+		t.Fatalf("OpenProfileArchive: %v", err)
+	}
+	defer p.Close()
+	if !p.Has(StoreHTTPCache) {
+		t.Error("snapshot has no HTTP cache")
+	}
+	ts, err := p.TransportSecurity()
+	if err != nil || len(ts.Entries) != 2 {
+		t.Errorf("TransportSecurity = %+v, %v; want 2 entries", ts, err)
+	}
+	lsd, err := p.LocalStorage()
+	if err != nil {
+		t.Fatalf("LocalStorage: %v", err)
+	}
+	if len(lsd.Records) != 1 || lsd.Records[0].ScriptKey != "token" || lsd.Records[0].Decoded != "héllo" {
+		t.Errorf("LocalStorage records = %+v", lsd.Records)
+	}
+
+	a, err := OpenArchive(snapshot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+	read, err := ReadSnapshotManifest(a)
+	if err != nil {
+		t.Fatalf("ReadSnapshotManifest: %v", err)
+	}
+	bad, err := VerifySnapshot(a, read)
+	if err != nil || len(bad) != 0 {
+		t.Errorf("VerifySnapshot = %v, %v; want no mismatches", bad, err)
+	}
+	if len(read.Files) != len(m.Files) {
+		t.Errorf("read manifest has %d files, want %d", len(read.Files), len(m.Files))
+	}
+}
+
+func TestCollectProfileMissingCache(t *testing.T) {
+	profile := t.TempDir()
+	if err := os.WriteFile(filepath.Join(profile, "Preferences"), []byte(`{}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		opts        *CollectOptions
+		wantSkipped int
+	}{
+		{nil, 1},
+		{&CollectOptions{SkipCache: true}, 0},
+	}
+	for _, tt := range tests {
+		f, err := os.Create(filepath.Join(t.TempDir(), "snapshot.zip"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		m, err := CollectProfile(profile, f, tt.opts)
+		f.Close()
+		if err != nil {
+			t.Fatalf("CollectProfile: %v", err)
+		}
+		if len(m.Skipped) != tt.wantSkipped {
+			t.Errorf("CollectProfile(%+v) Skipped = %+v, want %d entries", tt.opts, m.Skipped, tt.wantSkipped)
+		}
+	}
+}
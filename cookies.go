@@ -4,18 +4,15 @@ import (
 	"bytes"
 	"crypto/aes"
 	"crypto/cipher"
-	"crypto/sha1"
 	"crypto/sha256"
-	"database/sql"
 	"fmt"
+	"io/fs"
 	"os"
-	"strings"
-
-	_ "github.com/mattn/go-sqlite3"
-	"golang.org/x/crypto/pbkdf2"
+	"path"
 )
 
 type Cookie struct {
+	File           string `json:"file"`
 	Domain         string `json:"domain"`
 	Name           string `json:"name"`
 	EncryptedValue []byte `json:"encrypted_value"`
@@ -23,8 +20,13 @@ type Cookie struct {
 }
 
 func GetCookies(cookiesPath string) ([]Cookie, error) {
+	return GetCookiesFS(osFS(cookiesPath))
+}
 
-	db, err := sql.Open("sqlite3", cookiesPath)
+// GetCookiesFS is GetCookies for a Cookies database in fsys.
+func GetCookiesFS(fsys fs.FS, name string) ([]Cookie, error) {
+
+	db, err := openSQLite(fsys, name)
 	if err != nil {
 		return nil, err
 	}
@@ -57,6 +59,7 @@ func GetCookies(cookiesPath string) ([]Cookie, error) {
 		if err != nil {
 			return nil, err
 		}
+		cookie.File = path.Base(name)
 		cookies = append(cookies, cookie)
 	}
 
@@ -68,74 +71,163 @@ func GetKey() ([]byte, error) {
 	if browserPassword == "" {
 		return []byte{}, fmt.Errorf("BROWSER_PASSWORD environment variable not set")
 	}
-	password := strings.TrimSpace(string(browserPassword))
-	return pbkdf2.Key([]byte(password), []byte("saltysalt"), 1003, 16, sha1.New), nil
+	return deriveKey(browserPassword, 1003), nil
 }
 
 // Package variable to store the current database version
 var currentDBVersion int
 
-func DecryptValue(encryptedValue, key []byte, domain string) (string, error) {
-	block, err := aes.NewCipher(key)
-	if err != nil {
-		return "", err
+// linuxV10Key is the key Chromium on Linux encrypts v10 values with, derived
+// from the fixed password "peanuts"; only v11 values use the keyring's
+// password. A store written before and after a keyring was set up holds both.
+var linuxV10Key = deriveKey("peanuts", 1)
+
+// Decrypt decrypts a value encrypted with the browser's v10 or v11 scheme,
+// as used for cookies, saved passwords and other secrets in the profile. A
+// 16-byte key derived from a password decrypts AES-128-CBC with a constant
+// IV, as on macOS and Linux; the 32-byte key from Local State decrypts
+// AES-256-GCM with the nonce stored ahead of the ciphertext, as on Windows.
+// v11 values are decrypted with key; v10 values with key or, failing that,
+// with the fixed key Chromium on Linux uses for v10. A nil key, as for
+// Android profiles, returns the value as stored.
+func Decrypt(encryptedValue, key []byte) ([]byte, error) {
+	return decrypt(encryptedValue, key, nil)
+}
+
+// decrypt is Decrypt, also rejecting a plaintext that check fails, so that
+// a v10 value decrypted with the wrong key falls through to the next.
+func decrypt(encryptedValue, key []byte, check func([]byte) error) ([]byte, error) {
+	if key == nil {
+		if check != nil {
+			if err := check(encryptedValue); err != nil {
+				return nil, err
+			}
+		}
+		return encryptedValue, nil
 	}
 
-	// The EncryptedValue is prefixed with "v10", remove it
-	// TODO check if prefix is v10
 	if len(encryptedValue) < 3 {
-		return "", fmt.Errorf("encrypted length less than 3")
+		return nil, fmt.Errorf("encrypted length less than 3")
 	}
 	version := string(encryptedValue[0:3])
-	if version != "v10" {
-		return "", fmt.Errorf("unsported encrypted value version: %s", version)
+	if version != "v10" && version != "v11" {
+		return nil, fmt.Errorf("unsported encrypted value version: %s", version)
 	}
 	encryptedValue = encryptedValue[3:]
-	decrypted := make([]byte, len(encryptedValue))
+
+	if len(key) == 32 {
+		decrypted, err := decryptGCM(encryptedValue, key)
+		if err == nil && check != nil {
+			err = check(decrypted)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return decrypted, nil
+	}
+
+	keys := [][]byte{key}
+	if version == "v10" && !bytes.Equal(key, linuxV10Key) {
+		keys = append(keys, linuxV10Key)
+	}
+	var firstErr error
+	for _, k := range keys {
+		decrypted, err := decryptCBC(encryptedValue, k)
+		if err == nil && check != nil {
+			err = check(decrypted)
+		}
+		if err == nil {
+			return decrypted, nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	return nil, firstErr
+}
+
+func decryptGCM(encryptedValue, key []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	if len(encryptedValue) < gcm.NonceSize()+gcm.Overhead() {
+		return nil, fmt.Errorf("encrypted data too short for AES-GCM")
+	}
+	nonce, ciphertext := encryptedValue[:gcm.NonceSize()], encryptedValue[gcm.NonceSize():]
+	return gcm.Open(nil, nonce, ciphertext, nil)
+}
+
+func decryptCBC(encryptedValue, key []byte) ([]byte, error) {
 	const (
-		aescbcSalt            = `saltysalt`
-		aescbcIV              = `                `
-		aescbcIterationsLinux = 1
-		aescbcIterationsMacOS = 1003
-		aescbcLength          = 16
+		aescbcIV     = `                `
+		aescbcLength = 16
 	)
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(encryptedValue)%aescbcLength != 0 {
+		return nil, fmt.Errorf("encrypted data block length is not a multiple of %d", aescbcLength)
+	}
+	decrypted := make([]byte, len(encryptedValue))
 	cbc := cipher.NewCBCDecrypter(block, []byte(aescbcIV))
 	cbc.CryptBlocks(decrypted, encryptedValue)
 
 	if len(decrypted) == 0 {
-		return "", fmt.Errorf("not enough bits")
+		return nil, fmt.Errorf("not enough bits")
 	}
 
-	if len(decrypted)%aescbcLength != 0 {
-		return "", fmt.Errorf("decrypted data block length is not a multiple of %d", aescbcLength)
-	}
+	// Check all of the PKCS#7 padding, which a wrong key rarely produces.
 	paddingLen := int(decrypted[len(decrypted)-1])
-	if paddingLen > 16 {
-		return "", fmt.Errorf("invalid last block padding length: %d", paddingLen)
+	if paddingLen == 0 || paddingLen > aescbcLength || paddingLen > len(decrypted) {
+		return nil, fmt.Errorf("invalid last block padding length: %d", paddingLen)
 	}
+	for _, b := range decrypted[len(decrypted)-paddingLen:] {
+		if int(b) != paddingLen {
+			return nil, fmt.Errorf("invalid last block padding")
+		}
+	}
+
+	return decrypted[:len(decrypted)-paddingLen], nil
+}
 
+func DecryptValue(encryptedValue, key []byte, domain string) (string, error) {
 	// In Chrome database versions ≥ 24, the first 32 bytes contain a SHA256 digest of the host_key (domain)
 	// This was added in Chrome v130 (https://github.com/chromium/chromium/commit/5ea6d65c622a3d5ff75db9dc0257ea3869f31289)
-	if currentDBVersion >= 24 {
-		// Need to verify and skip the first 32 bytes (SHA256 digest of domain)
-		if len(decrypted) <= 32 {
-			return "", fmt.Errorf("decrypted data too short for db version %d, expected more than 32 bytes but got %d", currentDBVersion, len(decrypted))
+	if currentDBVersion < 24 {
+		// For older versions, return the full decrypted value (minus padding)
+		decrypted, err := Decrypt(encryptedValue, key)
+		if err != nil {
+			return "", err
+		}
+		return string(decrypted), nil
+	}
+
+	// Need to verify and skip the first 32 bytes (SHA256 digest of domain)
+	decrypted, err := decrypt(encryptedValue, key, func(decrypted []byte) error {
+		if len(decrypted) < 32 {
+			return fmt.Errorf("decrypted data too short for db version %d, expected at least 32 bytes but got %d", currentDBVersion, len(decrypted))
 		}
-		
+
 		// If domain is provided, verify the SHA256 hash matches
 		if domain != "" {
 			// Calculate SHA256 hash of the domain
 			domainHash := sha256.Sum256([]byte(domain))
-			
+
 			// Check if the hash in the decrypted value matches the calculated hash
 			if !bytes.Equal(domainHash[:], decrypted[:32]) { // SHA256 is 32 bytes
-				return "", fmt.Errorf("domain hash verification failed")
+				return fmt.Errorf("domain hash verification failed")
 			}
 		}
-		
-		return string(decrypted[32:len(decrypted)-paddingLen]), nil
+		return nil
+	})
+	if err != nil {
+		return "", err
 	}
-	
-	// For older versions, return the full decrypted value (minus padding)
-	return string(decrypted[:len(decrypted)-paddingLen]), nil
+	return string(decrypted[32:]), nil
 }
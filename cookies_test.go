@@ -0,0 +1,100 @@
+package chromedb
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha256"
+	"testing"
+)
+
+// encryptCBC encrypts plaintext as the browser does on macOS and Linux.
+func encryptCBC(t *testing.T, version string, key, plaintext []byte) []byte {
+	t.Helper()
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := aes.BlockSize - len(plaintext)%aes.BlockSize
+	padded := append(append([]byte{}, plaintext...), bytes.Repeat([]byte{byte(n)}, n)...)
+	cipher.NewCBCEncrypter(block, bytes.Repeat([]byte{' '}, aes.BlockSize)).CryptBlocks(padded, padded)
+	return append([]byte(version), padded...)
+}
+
+// encryptGCM encrypts plaintext as the browser does on Windows.
+func encryptGCM(t *testing.T, key, plaintext []byte) []byte {
+	t.Helper()
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nonce := []byte("0123456789ab")
+	return gcm.Seal(append([]byte("v10"), nonce...), nonce, plaintext, nil)
+}
+
+func TestDecrypt(t *testing.T) {
+	keyringKey := deriveKey("keyring password", 1)
+	gcmKey := bytes.Repeat([]byte{7}, 32)
+	secret := []byte("secret value")
+
+	tests := []struct {
+		name      string
+		key       []byte
+		encrypted []byte
+		wantErr   bool
+	}{
+		{"v11 with the keyring key", keyringKey, encryptCBC(t, "v11", keyringKey, secret), false},
+		{"v10 with the keyring key", keyringKey, encryptCBC(t, "v10", keyringKey, secret), false},
+		{"v10 with the fixed Linux key", keyringKey, encryptCBC(t, "v10", linuxV10Key, secret), false},
+		{"v10 given the fixed Linux key", linuxV10Key, encryptCBC(t, "v10", linuxV10Key, secret), false},
+		{"v11 doesn't fall back to the fixed Linux key", keyringKey, encryptCBC(t, "v11", linuxV10Key, secret), true},
+		{"AES-GCM", gcmKey, encryptGCM(t, gcmKey, secret), false},
+		{"AES-GCM with the wrong key", bytes.Repeat([]byte{8}, 32), encryptGCM(t, gcmKey, secret), true},
+		{"unencrypted", nil, secret, false},
+		{"unknown version", keyringKey, append([]byte("v20"), make([]byte, 16)...), true},
+		{"too short", keyringKey, []byte("v1"), true},
+		{"partial block", keyringKey, []byte("v10abc"), true},
+		{"no blocks", keyringKey, []byte("v10"), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Decrypt(tt.encrypted, tt.key)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("Decrypt = %q, want error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Decrypt: %v", err)
+			}
+			if !bytes.Equal(got, secret) {
+				t.Errorf("Decrypt = %q, want %q", got, secret)
+			}
+		})
+	}
+}
+
+func TestDecryptValueDomainHash(t *testing.T) {
+	defer func(v int) { currentDBVersion = v }(currentDBVersion)
+	currentDBVersion = 24
+
+	key := deriveKey("keyring password", 1)
+	hash := sha256.Sum256([]byte(".example.com"))
+	encrypted := encryptCBC(t, "v10", linuxV10Key, append(hash[:], "value"...))
+
+	got, err := DecryptValue(encrypted, key, ".example.com")
+	if err != nil || got != "value" {
+		t.Errorf("DecryptValue = %q, %v; want value", got, err)
+	}
+	if got, err := DecryptValue(encrypted, key, ".other.example"); err == nil {
+		t.Errorf("DecryptValue for another domain = %q, want error", got)
+	}
+	if got, err := DecryptValue(encryptCBC(t, "v10", key, []byte("short")), key, ""); err == nil {
+		t.Errorf("DecryptValue without a domain hash = %q, want error", got)
+	}
+}
@@ -0,0 +1,427 @@
+package chromedb
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// browserLocation describes where a Chromium-based browser or Electron app
+// keeps its user data directory on each OS. Paths are relative to the base
+// directory named by their first element: "~" for the home directory,
+// "$XDG_CONFIG_HOME", "$LOCALAPPDATA" or "$APPDATA". They may be globs.
+// safeStorage names the keychain item holding its password on macOS.
+type browserLocation struct {
+	id          string
+	name        string
+	safeStorage string
+	electron    bool
+	android     bool
+	paths       map[string][]string
+}
+
+var browserLocations = []browserLocation{
+	{id: "chrome", name: "Google Chrome", safeStorage: "Chrome", paths: map[string][]string{
+		"darwin":  {"~/Library/Application Support/Google/Chrome"},
+		"linux":   {"$XDG_CONFIG_HOME/google-chrome"},
+		"windows": {"$LOCALAPPDATA/Google/Chrome/User Data"},
+	}},
+	{id: "chrome-beta", name: "Google Chrome Beta", safeStorage: "Chrome", paths: map[string][]string{
+		"darwin":  {"~/Library/Application Support/Google/Chrome Beta"},
+		"linux":   {"$XDG_CONFIG_HOME/google-chrome-beta"},
+		"windows": {"$LOCALAPPDATA/Google/Chrome Beta/User Data"},
+	}},
+	{id: "chromium", name: "Chromium", safeStorage: "Chromium", paths: map[string][]string{
+		"darwin":  {"~/Library/Application Support/Chromium"},
+		"linux":   {"$XDG_CONFIG_HOME/chromium", "~/snap/chromium/common/chromium"},
+		"windows": {"$LOCALAPPDATA/Chromium/User Data"},
+	}},
+	{id: "edge", name: "Microsoft Edge", safeStorage: "Microsoft Edge", paths: map[string][]string{
+		"darwin":  {"~/Library/Application Support/Microsoft Edge"},
+		"linux":   {"$XDG_CONFIG_HOME/microsoft-edge"},
+		"windows": {"$LOCALAPPDATA/Microsoft/Edge/User Data"},
+	}},
+	{id: "brave", name: "Brave", safeStorage: "Brave", paths: map[string][]string{
+		"darwin":  {"~/Library/Application Support/BraveSoftware/Brave-Browser"},
+		"linux":   {"$XDG_CONFIG_HOME/BraveSoftware/Brave-Browser"},
+		"windows": {"$LOCALAPPDATA/BraveSoftware/Brave-Browser/User Data"},
+	}},
+	{id: "vivaldi", name: "Vivaldi", safeStorage: "Vivaldi", paths: map[string][]string{
+		"darwin":  {"~/Library/Application Support/Vivaldi"},
+		"linux":   {"$XDG_CONFIG_HOME/vivaldi"},
+		"windows": {"$LOCALAPPDATA/Vivaldi/User Data"},
+	}},
+	{id: "opera", name: "Opera", safeStorage: "Opera", paths: map[string][]string{
+		"darwin":  {"~/Library/Application Support/com.operasoftware.Opera"},
+		"linux":   {"$XDG_CONFIG_HOME/opera"},
+		"windows": {"$APPDATA/Opera Software/Opera Stable"},
+	}},
+	{id: "opera-gx", name: "Opera GX", safeStorage: "Opera", paths: map[string][]string{
+		"darwin":  {"~/Library/Application Support/com.operasoftware.OperaGX"},
+		"windows": {"$APPDATA/Opera Software/Opera GX Stable"},
+	}},
+	{id: "arc", name: "Arc", safeStorage: "Arc", paths: map[string][]string{
+		"darwin":  {"~/Library/Application Support/Arc/User Data"},
+		"windows": {"$LOCALAPPDATA/Packages/TheBrowserCompany.Arc_*/LocalCache/Local/Arc/User Data"},
+	}},
+	{id: "slack", name: "Slack", safeStorage: "Slack", electron: true, paths: map[string][]string{
+		"darwin": {
+			"~/Library/Application Support/Slack",
+			"~/Library/Containers/com.tinyspeck.slackmacgap/Data/Library/Application Support/Slack",
+		},
+		"linux":   {"$XDG_CONFIG_HOME/Slack"},
+		"windows": {"$APPDATA/Slack"},
+	}},
+	{id: "discord", name: "Discord", safeStorage: "discord", electron: true, paths: map[string][]string{
+		"darwin":  {"~/Library/Application Support/discord"},
+		"linux":   {"$XDG_CONFIG_HOME/discord"},
+		"windows": {"$APPDATA/discord"},
+	}},
+	{id: "teams", name: "Microsoft Teams (classic)", safeStorage: "Microsoft Teams", electron: true, paths: map[string][]string{
+		"darwin":  {"~/Library/Application Support/Microsoft/Teams"},
+		"linux":   {"$XDG_CONFIG_HOME/Microsoft/Microsoft Teams"},
+		"windows": {"$APPDATA/Microsoft/Teams"},
+	}},
+	{id: "vscode", name: "Visual Studio Code", safeStorage: "Code", electron: true, paths: map[string][]string{
+		"darwin":  {"~/Library/Application Support/Code"},
+		"linux":   {"$XDG_CONFIG_HOME/Code"},
+		"windows": {"$APPDATA/Code"},
+	}},
+	{id: "signal", name: "Signal", safeStorage: "Signal", electron: true, paths: map[string][]string{
+		"darwin":  {"~/Library/Application Support/Signal"},
+		"linux":   {"$XDG_CONFIG_HOME/Signal"},
+		"windows": {"$APPDATA/Signal"},
+	}},
+	{id: "notion", name: "Notion", safeStorage: "Notion", electron: true, paths: map[string][]string{
+		"darwin":  {"~/Library/Application Support/Notion"},
+		"linux":   {"$XDG_CONFIG_HOME/Notion"},
+		"windows": {"$APPDATA/Notion"},
+	}},
+	{id: "obsidian", name: "Obsidian", safeStorage: "obsidian", electron: true, paths: map[string][]string{
+		"darwin":  {"~/Library/Application Support/obsidian"},
+		"linux":   {"$XDG_CONFIG_HOME/obsidian"},
+		"windows": {"$APPDATA/obsidian"},
+	}},
+}
+
+// BrowserProfile is a profile found on this machine.
+type BrowserProfile struct {
+	Browser     string `json:"browser"`
+	BrowserName string `json:"browser_name"`
+	UserDataDir string `json:"user_data_dir"`
+	Dir         string `json:"dir"`
+	Path        string `json:"path"`
+	Name        string `json:"name"`
+	UserName    string `json:"user_name,omitempty"`
+	GaiaName    string `json:"gaia_name,omitempty"`
+	LastUsed    bool   `json:"last_used"`
+	Android     bool   `json:"android,omitempty"`
+}
+
+// expandBrowserPath resolves a browserLocation path against the current
+// user's directories, returning "" if its base isn't known.
+func expandBrowserPath(path string) string {
+	base, rest, _ := strings.Cut(path, "/")
+	var dir string
+	switch base {
+	case "~":
+		dir, _ = os.UserHomeDir()
+	case "$XDG_CONFIG_HOME":
+		dir, _ = os.UserConfigDir()
+	case "$LOCALAPPDATA":
+		dir = os.Getenv("LOCALAPPDATA")
+	case "$APPDATA":
+		dir = os.Getenv("APPDATA")
+	}
+	if dir == "" {
+		return ""
+	}
+	return filepath.Join(dir, filepath.FromSlash(rest))
+}
+
+// userDataDirs returns the browser's user data directories that exist on
+// this machine.
+func (b browserLocation) userDataDirs() []string {
+	var dirs []string
+	for _, path := range b.paths[runtime.GOOS] {
+		path = expandBrowserPath(path)
+		if path == "" {
+			continue
+		}
+		matches, _ := filepath.Glob(path)
+		for _, m := range matches {
+			if fi, err := os.Stat(m); err == nil && fi.IsDir() {
+				dirs = append(dirs, m)
+			}
+		}
+	}
+	return dirs
+}
+
+// singleProfile reports whether the user data directory is itself the
+// profile, as with Electron apps and Opera, rather than holding profile
+// directories.
+func singleProfile(userDataDir string) bool {
+	if _, err := os.Stat(filepath.Join(userDataDir, "Default")); err == nil {
+		return false
+	}
+	matches, _ := filepath.Glob(filepath.Join(userDataDir, "Profile *"))
+	return len(matches) == 0
+}
+
+// userDataDirProfiles lists the profiles in a user data directory, named as
+// in Local State's profile.info_cache. Profile directories missing from
+// Local State are still listed, under their directory names.
+func userDataDirProfiles(b browserLocation, userDataDir string) []BrowserProfile {
+	if b.electron || singleProfile(userDataDir) {
+		profiles := []BrowserProfile{{
+			Browser:     b.id,
+			BrowserName: b.name,
+			UserDataDir: userDataDir,
+			Path:        userDataDir,
+			Name:        b.name,
+			LastUsed:    true,
+			Android:     b.android,
+		}}
+
+		// Electron apps keep each session partition in a profile-like
+		// directory of its own.
+		partitions, _ := filepath.Glob(filepath.Join(userDataDir, "Partitions", "*"))
+		for _, path := range partitions {
+			if fi, err := os.Stat(path); err != nil || !fi.IsDir() {
+				continue
+			}
+			profiles = append(profiles, BrowserProfile{
+				Browser:     b.id,
+				BrowserName: b.name,
+				UserDataDir: userDataDir,
+				Dir:         filepath.Join("Partitions", filepath.Base(path)),
+				Path:        path,
+				Name:        b.name + " (" + filepath.Base(path) + ")",
+				Android:     b.android,
+			})
+		}
+		return profiles
+	}
+
+	var profiles []BrowserProfile
+	seen := map[string]bool{}
+	lastUsed := "Default"
+	if ls, err := GetLocalState(filepath.Join(userDataDir, "Local State")); err == nil {
+		if ls.LastUsedProfile != "" {
+			lastUsed = ls.LastUsedProfile
+		}
+		for _, p := range ls.Profiles {
+			path := filepath.Join(userDataDir, p.Dir)
+			if _, err := os.Stat(path); err != nil {
+				continue
+			}
+			seen[p.Dir] = true
+			profiles = append(profiles, BrowserProfile{
+				Browser:     b.id,
+				BrowserName: b.name,
+				UserDataDir: userDataDir,
+				Dir:         p.Dir,
+				Path:        path,
+				Name:        p.Name,
+				UserName:    p.UserName,
+				GaiaName:    p.GaiaName,
+			})
+		}
+	}
+
+	for _, pattern := range []string{"Default", "Profile *"} {
+		matches, _ := filepath.Glob(filepath.Join(userDataDir, pattern))
+		for _, m := range matches {
+			dir := filepath.Base(m)
+			if seen[dir] {
+				continue
+			}
+			if _, err := os.Stat(filepath.Join(m, "Preferences")); err != nil {
+				continue
+			}
+			profiles = append(profiles, BrowserProfile{
+				Browser:     b.id,
+				BrowserName: b.name,
+				UserDataDir: userDataDir,
+				Dir:         dir,
+				Path:        m,
+				Name:        dir,
+			})
+		}
+	}
+
+	for i := range profiles {
+		profiles[i].LastUsed = profiles[i].Dir == lastUsed
+		profiles[i].Android = b.android
+	}
+	sort.SliceStable(profiles, func(i, j int) bool { return profiles[i].Dir < profiles[j].Dir })
+	return profiles
+}
+
+// DiscoverProfiles finds the profiles of the known Chromium-based browsers
+// and Electron apps installed for the current user.
+func DiscoverProfiles() []BrowserProfile {
+	var profiles []BrowserProfile
+	for _, b := range browserLocations {
+		for _, dir := range b.userDataDirs() {
+			profiles = append(profiles, userDataDirProfiles(b, dir)...)
+		}
+	}
+	return profiles
+}
+
+// androidLocation recognises the user data directory of Chrome for Android
+// (<package>/app_chrome, also used by other Chromium-based Android browsers)
+// or of an app's WebView (<package>/app_webview), as found under
+// /data/data on the device or in an image of it.
+func androidLocation(userDataDir string) (browserLocation, bool) {
+	pkg := filepath.Base(filepath.Dir(userDataDir))
+	switch filepath.Base(userDataDir) {
+	case "app_chrome":
+		name := pkg
+		if pkg == "com.android.chrome" {
+			name = "Chrome for Android"
+		}
+		return browserLocation{id: "android-chrome", name: name, android: true}, true
+	case "app_webview":
+		return browserLocation{id: "android-webview", name: "Android WebView (" + pkg + ")", android: true}, true
+	}
+	return browserLocation{}, false
+}
+
+// AndroidProfiles returns the Chrome and WebView profiles of every app in
+// dataDir, an Android /data/data directory or an image of one.
+func AndroidProfiles(dataDir string) []BrowserProfile {
+	var profiles []BrowserProfile
+	for _, pattern := range []string{"*/app_chrome", "*/app_webview"} {
+		dirs, _ := filepath.Glob(filepath.Join(dataDir, pattern))
+		for _, dir := range dirs {
+			if b, ok := androidLocation(dir); ok {
+				profiles = append(profiles, userDataDirProfiles(b, dir)...)
+			}
+		}
+	}
+	sort.SliceStable(profiles, func(i, j int) bool { return profiles[i].Path < profiles[j].Path })
+	return profiles
+}
+
+// BrowserProfiles returns the profiles of the named browser, e.g. "arc" or
+// "Google Chrome", ignoring case.
+func BrowserProfiles(browser string) ([]BrowserProfile, error) {
+	var (
+		found    bool
+		profiles []BrowserProfile
+	)
+	for _, b := range browserLocations {
+		if !strings.EqualFold(browser, b.id) && !strings.EqualFold(browser, b.name) {
+			continue
+		}
+		found = true
+		for _, dir := range b.userDataDirs() {
+			profiles = append(profiles, userDataDirProfiles(b, dir)...)
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("unknown browser %q", browser)
+	}
+	return profiles, nil
+}
+
+// UserDataDirProfiles returns the profiles in a user data directory, which
+// needn't be in a standard location. The browser is identified when it is.
+// An Android data directory yields the profiles of all its apps.
+func UserDataDirProfiles(userDataDir string) []BrowserProfile {
+	userDataDir = filepath.Clean(userDataDir)
+	if b, ok := androidLocation(userDataDir); ok {
+		return userDataDirProfiles(b, userDataDir)
+	}
+	if profiles := AndroidProfiles(userDataDir); len(profiles) > 0 {
+		return profiles
+	}
+	b := browserLocation{name: filepath.Base(userDataDir)}
+	for _, known := range browserLocations {
+		for _, dir := range known.userDataDirs() {
+			if dir == userDataDir {
+				b = known
+			}
+		}
+	}
+	return userDataDirProfiles(b, userDataDir)
+}
+
+// ProfileForPath identifies the browser and user data directory of the
+// profile at path. The path may be a profile directory, a user data
+// directory that is itself the profile, or an Electron session partition.
+// Profiles outside the standard locations are assumed to sit in the
+// directory holding Local State, or else in its parent.
+func ProfileForPath(path string) BrowserProfile {
+	path = filepath.Clean(path)
+	for _, dir := range []string{path, filepath.Dir(path)} {
+		if b, ok := androidLocation(dir); ok {
+			for _, p := range userDataDirProfiles(b, dir) {
+				if p.Path == path {
+					return p
+				}
+			}
+		}
+	}
+	for _, b := range browserLocations {
+		for _, dir := range b.userDataDirs() {
+			for _, candidate := range []string{path, filepath.Dir(path), filepath.Dir(filepath.Dir(path))} {
+				if candidate != dir {
+					continue
+				}
+				for _, p := range userDataDirProfiles(b, dir) {
+					if p.Path == path {
+						return p
+					}
+				}
+				rel, _ := filepath.Rel(dir, path)
+				return BrowserProfile{Browser: b.id, BrowserName: b.name, UserDataDir: dir, Dir: rel, Path: path, Name: rel}
+			}
+		}
+	}
+
+	userDataDir := filepath.Dir(path)
+	if _, err := os.Stat(filepath.Join(path, "Local State")); err == nil {
+		userDataDir = path
+	}
+	return BrowserProfile{
+		BrowserName: filepath.Base(userDataDir),
+		UserDataDir: userDataDir,
+		Path:        path,
+		Name:        filepath.Base(path),
+	}
+}
+
+// FindProfile returns the profile of the named browser whose directory or
+// display name is profile, ignoring case. An empty profile selects the last
+// used one.
+func FindProfile(browser, profile string) (BrowserProfile, error) {
+	profiles, err := BrowserProfiles(browser)
+	if err != nil {
+		return BrowserProfile{}, err
+	}
+
+	var matches []BrowserProfile
+	for _, p := range profiles {
+		if profile == "" && p.LastUsed ||
+			profile != "" && (strings.EqualFold(profile, p.Dir) || strings.EqualFold(profile, p.Name)) {
+			matches = append(matches, p)
+		}
+	}
+
+	switch {
+	case len(matches) == 0 && profile == "":
+		return BrowserProfile{}, fmt.Errorf("no profiles found for %s", browser)
+	case len(matches) == 0:
+		return BrowserProfile{}, fmt.Errorf("no profile %q found for %s", profile, browser)
+	case len(matches) > 1 && profile != "":
+		return BrowserProfile{}, fmt.Errorf("profile %q is ambiguous for %s; pass its directory, e.g. %q", profile, browser, matches[0].Dir)
+	}
+	return matches[0], nil
+}
@@ -0,0 +1,11 @@
+//go:build !windows
+
+package chromedb
+
+import "errors"
+
+// dpapiDecrypt decrypts data protected with the current user's DPAPI
+// credentials, which only Windows can do.
+func dpapiDecrypt(data []byte) ([]byte, error) {
+	return nil, errors.New("DPAPI is only available on Windows")
+}
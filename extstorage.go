@@ -0,0 +1,119 @@
+package chromedb
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+)
+
+// Extension storage areas and the profile directories holding them.
+var extensionStorageAreas = []struct {
+	area string
+	dir  string
+}{
+	{"local", "Local Extension Settings"},
+	{"sync", "Sync Extension Settings"},
+}
+
+type ExtensionStorageRecord struct {
+	ExtensionID   string          `json:"extension_id"`
+	ExtensionName string          `json:"extension_name"`
+	Area          string          `json:"area"`
+	Key           string          `json:"key"`
+	Decoded       string          `json:"-"`
+	MIME          string          `json:"mime"`
+	Conversions   []string        `json:"conversions"`
+	JsonType      string          `json:"-"`
+	Value         json.RawMessage `json:"value"`
+}
+
+type ExtensionStoreDb struct {
+	Records []ExtensionStorageRecord `json:"records"`
+}
+
+// LoadExtensionStorage reads the chrome.storage.local and chrome.storage.sync
+// data of every extension in a profile directory. Each extension keeps its
+// own LevelDB, whose keys are storage keys and whose values are JSON.
+// Extension names are taken from the profile's Preferences when available.
+func LoadExtensionStorage(profileDir string) (*ExtensionStoreDb, error) {
+	return LoadExtensionStorageFS(osFS(profileDir))
+}
+
+// LoadExtensionStorageFS is LoadExtensionStorage for a profile directory in
+// fsys.
+func LoadExtensionStorageFS(fsys fs.FS, profileDir string) (*ExtensionStoreDb, error) {
+	names := map[string]string{}
+	if prefs, err := GetPreferencesFS(fsys, profileDir); err == nil {
+		for _, e := range prefs.Extensions {
+			names[e.ID] = e.Name
+		}
+	}
+
+	esd := &ExtensionStoreDb{}
+	for _, a := range extensionStorageAreas {
+		entries, err := fs.ReadDir(fsys, path.Join(profileDir, a.dir))
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		for _, e := range entries {
+			if !e.IsDir() {
+				continue
+			}
+			id := e.Name()
+			dir := path.Join(profileDir, a.dir, id)
+
+			// Skip directories the browser created but never wrote to.
+			if _, err := fs.Stat(fsys, path.Join(dir, "CURRENT")); err != nil {
+				continue
+			}
+
+			db, err := openLevelDB(fsys, dir)
+			if err != nil {
+				return nil, fmt.Errorf("failed to open %s storage for %s: %w", a.area, id, err)
+			}
+
+			iter := db.NewIterator(nil, nil)
+			for iter.Next() {
+				esd.Records = append(esd.Records, ExtensionStorageRecord{
+					ExtensionID:   id,
+					ExtensionName: names[id],
+					Area:          a.area,
+					Key:           string(iter.Key()),
+					Decoded:       string(iter.Value()),
+				})
+			}
+			iter.Release()
+			err = iter.Error()
+			db.Close()
+			if err != nil {
+				return nil, fmt.Errorf("failed to read %s storage for %s: %w", a.area, id, err)
+			}
+		}
+	}
+
+	return esd, nil
+}
+
+func ExtensionStorageRecordToJson(r ExtensionStorageRecord) (string, error) {
+	v, err := classifyValue(r.Decoded)
+	if err != nil {
+		return "", err
+	}
+
+	r.MIME = v.MIME
+	r.Conversions = v.Conversions
+	r.Value = v.Value
+	r.JsonType = v.JsonType
+	recordJson, err := json.Marshal(r)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal record to JSON: %w", err)
+	}
+
+	return string(recordJson), nil
+}
@@ -0,0 +1,143 @@
+package chromedb
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/h2non/filetype"
+)
+
+// Icon types from the favicons.icon_type column.
+var faviconTypes = map[int64]string{
+	1: "favicon",
+	2: "touch_icon",
+	4: "touch_precomposed_icon",
+	8: "web_manifest_icon",
+}
+
+type Favicon struct {
+	ID            int64     `json:"id"`
+	IconURL       string    `json:"icon_url"`
+	IconType      string    `json:"icon_type"`
+	PageURLs      []string  `json:"page_urls"`
+	Width         int64     `json:"width"`
+	Height        int64     `json:"height"`
+	LastUpdated   time.Time `json:"last_updated"`
+	LastRequested time.Time `json:"last_requested"`
+	MIME          string    `json:"mime"`
+	Size          int       `json:"size"`
+	File          string    `json:"file,omitempty"`
+	Data          []byte    `json:"-"`
+}
+
+// GetFavicons reads every favicon bitmap from a profile's Favicons database,
+// along with the pages that use it.
+func GetFavicons(faviconsPath string) ([]Favicon, error) {
+	return GetFaviconsFS(osFS(faviconsPath))
+}
+
+// GetFaviconsFS is GetFavicons for a Favicons database in fsys.
+func GetFaviconsFS(fsys fs.FS, name string) ([]Favicon, error) {
+	db, err := openSQLite(fsys, name)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	pages := map[int64][]string{}
+	mapping, err := db.Query("SELECT icon_id, page_url FROM icon_mapping ORDER BY page_url")
+	if err != nil {
+		return nil, err
+	}
+	for mapping.Next() {
+		var (
+			iconID  int64
+			pageURL string
+		)
+		if err := mapping.Scan(&iconID, &pageURL); err != nil {
+			mapping.Close()
+			return nil, err
+		}
+		pages[iconID] = append(pages[iconID], pageURL)
+	}
+	mapping.Close()
+	if err := mapping.Err(); err != nil {
+		return nil, err
+	}
+
+	cols, err := sqliteColumns(db, "favicon_bitmaps")
+	if err != nil {
+		return nil, err
+	}
+
+	query := `SELECT b.id, b.icon_id, f.url, f.icon_type, ` +
+		selectColumns(cols, "b.",
+			[2]string{"width", "0"},
+			[2]string{"height", "0"},
+			[2]string{"last_updated", "0"},
+			[2]string{"last_requested", "0"},
+			[2]string{"image_data", "NULL"},
+		) + ` FROM favicon_bitmaps b
+		JOIN favicons f ON f.id = b.icon_id
+		ORDER BY b.id`
+	rows, err := db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var favicons []Favicon
+	for rows.Next() {
+		var (
+			f                          Favicon
+			iconID, iconType           int64
+			lastUpdated, lastRequested int64
+		)
+		err := rows.Scan(&f.ID, &iconID, &f.IconURL, &iconType, &f.Width, &f.Height,
+			&lastUpdated, &lastRequested, &f.Data)
+		if err != nil {
+			return nil, err
+		}
+		f.IconType = faviconTypes[iconType]
+		if f.IconType == "" {
+			f.IconType = "unknown"
+		}
+		f.PageURLs = pages[iconID]
+		if f.PageURLs == nil {
+			f.PageURLs = []string{}
+		}
+		f.LastUpdated = chromeTime(lastUpdated)
+		f.LastRequested = chromeTime(lastRequested)
+		f.Size = len(f.Data)
+		if kind, _ := filetype.Match(f.Data); kind != filetype.Unknown {
+			f.MIME = kind.MIME.Value
+		}
+		favicons = append(favicons, f)
+	}
+	return favicons, rows.Err()
+}
+
+// WriteFavicon saves a favicon's bitmap to dir, naming it after the bitmap
+// ID with an extension for its detected file type, and records the path in
+// f.File.
+func WriteFavicon(f *Favicon, dir string) error {
+	if len(f.Data) == 0 {
+		return nil
+	}
+
+	ext := "bin"
+	if kind, _ := filetype.Match(f.Data); kind != filetype.Unknown {
+		ext = kind.Extension
+	}
+
+	path := filepath.Join(dir, strconv.FormatInt(f.ID, 10)+"."+ext)
+	if err := os.WriteFile(path, f.Data, 0644); err != nil {
+		return fmt.Errorf("failed to write favicon %d: %w", f.ID, err)
+	}
+	f.File = path
+	return nil
+}
@@ -0,0 +1,114 @@
+package chromedb
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/syndtr/goleveldb/leveldb/storage"
+)
+
+// Every reader works on an fs.FS, so profiles can be read from archives,
+// disk images and embedded fixtures as well as from disk. The path-based
+// readers wrap their FS counterparts, reading from the file system rooted at
+// the path's volume so that absolute paths recorded in the profile, such as
+// those of unpacked extensions, still resolve.
+
+// osFS returns the file system holding the OS path p and p's name within it.
+func osFS(p string) (fs.FS, string) {
+	abs, err := filepath.Abs(p)
+	if err != nil {
+		return os.DirFS("."), filepath.ToSlash(filepath.Clean(p))
+	}
+	root := filepath.VolumeName(abs) + string(filepath.Separator)
+	name := filepath.ToSlash(strings.TrimPrefix(abs, root))
+	if name == "" {
+		name = "."
+	}
+	return osDirFS{FS: os.DirFS(root), root: root}, name
+}
+
+// osDirFS is os.DirFS reporting OS paths in its errors.
+type osDirFS struct {
+	fs.FS
+	root string
+}
+
+func (d osDirFS) Open(name string) (fs.File, error) {
+	f, err := d.FS.Open(name)
+	var pe *fs.PathError
+	if errors.As(err, &pe) {
+		pe.Path = filepath.Join(d.root, filepath.FromSlash(pe.Path))
+	}
+	return f, err
+}
+
+// osPath is the inverse of osFS, returning the OS path of a name in the
+// file system osFS returned for p.
+func osPath(p, name string) string {
+	abs, err := filepath.Abs(p)
+	if err != nil {
+		return filepath.FromSlash(name)
+	}
+	return filepath.Join(filepath.VolumeName(abs)+string(filepath.Separator), filepath.FromSlash(name))
+}
+
+// fsName turns a path recorded in a profile into a name in fsys: absolute
+// paths are taken relative to the root, others relative to dir.
+func fsName(dir, p string) string {
+	if filepath.IsAbs(p) || strings.HasPrefix(p, "/") {
+		p = strings.TrimPrefix(p, filepath.VolumeName(p))
+		return strings.TrimLeft(filepath.ToSlash(p), "/")
+	}
+	return path.Join(dir, filepath.ToSlash(p))
+}
+
+// copyFileFS copies a file out of fsys to dst on disk, for databases that
+// can only be opened from an OS path.
+func copyFileFS(fsys fs.FS, name, dst string) error {
+	in, err := fsys.Open(name)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// memReader serves a file read into memory, for files from file systems
+// whose files can't seek, such as archive/zip's.
+type memReader struct {
+	*bytes.Reader
+}
+
+func (memReader) Close() error { return nil }
+
+// openReader opens a file for random access.
+func openReader(fsys fs.FS, name string) (storage.Reader, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	if r, ok := f.(storage.Reader); ok {
+		return r, nil
+	}
+	defer f.Close()
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
+	return memReader{bytes.NewReader(data)}, nil
+}
@@ -3,6 +3,8 @@ module github.com/noperator/chromedb
 go 1.21.5
 
 require (
+	github.com/andybalholm/brotli v1.1.1
+	github.com/golang/snappy v0.0.0-20180518054509-2e65f85255db
 	github.com/h2non/filetype v1.1.3
 	github.com/mattn/go-sqlite3 v1.14.22
 	github.com/syndtr/goleveldb v1.0.0
@@ -10,5 +12,3 @@ require (
 	golang.org/x/text v0.15.0
 	google.golang.org/protobuf v1.34.1
 )
-
-require github.com/golang/snappy v0.0.0-20180518054509-2e65f85255db // indirect
@@ -0,0 +1,281 @@
+package chromedb
+
+import (
+	"database/sql"
+	"io/fs"
+	"time"
+)
+
+// Core page transition types, from the low byte of visits.transition.
+var historyTransitionTypes = []string{
+	"link",
+	"typed",
+	"auto_bookmark",
+	"auto_subframe",
+	"manual_subframe",
+	"generated",
+	"auto_toplevel",
+	"form_submit",
+	"reload",
+	"keyword",
+	"keyword_generated",
+}
+
+// Page transition qualifiers, from the high bits of visits.transition.
+var historyTransitionQualifiers = []struct {
+	bit  int64
+	name string
+}{
+	{0x00800000, "blocked"},
+	{0x01000000, "forward_back"},
+	{0x02000000, "from_address_bar"},
+	{0x04000000, "home_page"},
+	{0x08000000, "from_api"},
+	{0x10000000, "chain_start"},
+	{0x20000000, "chain_end"},
+	{0x40000000, "client_redirect"},
+	{0x80000000, "server_redirect"},
+}
+
+// Download states from the downloads.state column.
+var historyDownloadStates = []string{
+	"in_progress",
+	"complete",
+	"cancelled",
+	"interrupted",
+	"interrupted",
+}
+
+type HistoryVisit struct {
+	ID            int64     `json:"id"`
+	URL           string    `json:"url"`
+	Title         string    `json:"title"`
+	VisitTime     time.Time `json:"visit_time"`
+	VisitDuration float64   `json:"visit_duration_seconds"`
+	Transition    string    `json:"transition"`
+	Qualifiers    []string  `json:"qualifiers"`
+	FromVisit     int64     `json:"from_visit,omitempty"`
+	Referrer      string    `json:"referrer,omitempty"`
+	VisitCount    int64     `json:"visit_count"`
+	TypedCount    int64     `json:"typed_count"`
+}
+
+type HistoryDownload struct {
+	ID              int64     `json:"id"`
+	URL             string    `json:"url"`
+	CurrentPath     string    `json:"current_path"`
+	TargetPath      string    `json:"target_path"`
+	StartTime       time.Time `json:"start_time"`
+	EndTime         time.Time `json:"end_time"`
+	ReceivedBytes   int64     `json:"received_bytes"`
+	TotalBytes      int64     `json:"total_bytes"`
+	State           string    `json:"state"`
+	DangerType      int64     `json:"danger_type"`
+	InterruptReason int64     `json:"interrupt_reason"`
+	Referrer        string    `json:"referrer,omitempty"`
+	TabURL          string    `json:"tab_url,omitempty"`
+	MimeType        string    `json:"mime_type,omitempty"`
+}
+
+type HistorySearchTerm struct {
+	KeywordID int64     `json:"keyword_id"`
+	Term      string    `json:"term"`
+	URL       string    `json:"url"`
+	Title     string    `json:"title"`
+	LastVisit time.Time `json:"last_visit_time"`
+}
+
+type HistorySegment struct {
+	ID         int64  `json:"id"`
+	Name       string `json:"name"`
+	URL        string `json:"url"`
+	VisitCount int64  `json:"visit_count"`
+}
+
+type History struct {
+	Visits      []HistoryVisit
+	Downloads   []HistoryDownload
+	SearchTerms []HistorySearchTerm
+	Segments    []HistorySegment
+}
+
+func historyTransition(t int64) (string, []string) {
+	core := "unknown"
+	if c := int(t & 0xff); c < len(historyTransitionTypes) {
+		core = historyTransitionTypes[c]
+	}
+	qualifiers := []string{}
+	for _, q := range historyTransitionQualifiers {
+		if t&q.bit != 0 {
+			qualifiers = append(qualifiers, q.name)
+		}
+	}
+	return core, qualifiers
+}
+
+// GetHistory reads visits, downloads, search terms and segments from a
+// profile's History database.
+func GetHistory(historyPath string) (*History, error) {
+	return GetHistoryFS(osFS(historyPath))
+}
+
+// GetHistoryFS is GetHistory for a History database in fsys.
+func GetHistoryFS(fsys fs.FS, name string) (*History, error) {
+	db, err := openSQLite(fsys, name)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	h := &History{}
+	if h.Visits, err = getHistoryVisits(db); err != nil {
+		return nil, err
+	}
+	if h.Downloads, err = getHistoryDownloads(db); err != nil {
+		return nil, err
+	}
+	if h.SearchTerms, err = getHistorySearchTerms(db); err != nil {
+		return nil, err
+	}
+	if h.Segments, err = getHistorySegments(db); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+func getHistoryVisits(db *sqliteDB) ([]HistoryVisit, error) {
+	query := `SELECT v.id, u.url, COALESCE(u.title, ''), v.visit_time, v.visit_duration,
+			v.transition, COALESCE(v.from_visit, 0), COALESCE(ru.url, ''), u.visit_count, u.typed_count
+		FROM visits v
+		JOIN urls u ON u.id = v.url
+		LEFT JOIN visits rv ON rv.id = v.from_visit
+		LEFT JOIN urls ru ON ru.id = rv.url
+		ORDER BY v.visit_time`
+	rows, err := db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var visits []HistoryVisit
+	for rows.Next() {
+		var (
+			v                          HistoryVisit
+			visitTime, duration, trans int64
+		)
+		err := rows.Scan(&v.ID, &v.URL, &v.Title, &visitTime, &duration,
+			&trans, &v.FromVisit, &v.Referrer, &v.VisitCount, &v.TypedCount)
+		if err != nil {
+			return nil, err
+		}
+		v.VisitTime = chromeTime(visitTime)
+		v.VisitDuration = (time.Duration(duration) * time.Microsecond).Seconds()
+		v.Transition, v.Qualifiers = historyTransition(trans)
+		visits = append(visits, v)
+	}
+	return visits, rows.Err()
+}
+
+func getHistoryDownloads(db *sqliteDB) ([]HistoryDownload, error) {
+	cols, err := sqliteColumns(db, "downloads")
+	if err != nil || cols == nil {
+		return nil, err
+	}
+
+	// The download's final URL is the last entry in its redirect chain.
+	query := `SELECT d.id, COALESCE((SELECT c.url FROM downloads_url_chains c
+			WHERE c.id = d.id ORDER BY c.chain_index DESC LIMIT 1), ''), ` +
+		selectColumns(cols, "d.",
+			[2]string{"current_path", "''"},
+			[2]string{"target_path", "''"},
+			[2]string{"start_time", "0"},
+			[2]string{"end_time", "0"},
+			[2]string{"received_bytes", "0"},
+			[2]string{"total_bytes", "0"},
+			[2]string{"state", "0"},
+			[2]string{"danger_type", "0"},
+			[2]string{"interrupt_reason", "0"},
+			[2]string{"referrer", "''"},
+			[2]string{"tab_url", "''"},
+			[2]string{"mime_type", "''"},
+		) + ` FROM downloads d ORDER BY d.start_time`
+	rows, err := db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var downloads []HistoryDownload
+	for rows.Next() {
+		var (
+			d                      HistoryDownload
+			start, end, state      int64
+			referrer, tabURL, mime sql.NullString
+		)
+		err := rows.Scan(&d.ID, &d.URL, &d.CurrentPath, &d.TargetPath, &start, &end,
+			&d.ReceivedBytes, &d.TotalBytes, &state, &d.DangerType, &d.InterruptReason,
+			&referrer, &tabURL, &mime)
+		if err != nil {
+			return nil, err
+		}
+		d.StartTime = chromeTime(start)
+		d.EndTime = chromeTime(end)
+		d.State = "unknown"
+		if state >= 0 && int(state) < len(historyDownloadStates) {
+			d.State = historyDownloadStates[state]
+		}
+		d.Referrer, d.TabURL, d.MimeType = referrer.String, tabURL.String, mime.String
+		downloads = append(downloads, d)
+	}
+	return downloads, rows.Err()
+}
+
+func getHistorySearchTerms(db *sqliteDB) ([]HistorySearchTerm, error) {
+	query := `SELECT k.keyword_id, k.term, u.url, COALESCE(u.title, ''), u.last_visit_time
+		FROM keyword_search_terms k
+		JOIN urls u ON u.id = k.url_id
+		ORDER BY u.last_visit_time`
+	rows, err := db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var terms []HistorySearchTerm
+	for rows.Next() {
+		var (
+			t         HistorySearchTerm
+			lastVisit int64
+		)
+		if err := rows.Scan(&t.KeywordID, &t.Term, &t.URL, &t.Title, &lastVisit); err != nil {
+			return nil, err
+		}
+		t.LastVisit = chromeTime(lastVisit)
+		terms = append(terms, t)
+	}
+	return terms, rows.Err()
+}
+
+func getHistorySegments(db *sqliteDB) ([]HistorySegment, error) {
+	query := `SELECT s.id, s.name, COALESCE(u.url, ''), COALESCE(SUM(su.visit_count), 0)
+		FROM segments s
+		LEFT JOIN urls u ON u.id = s.url_id
+		LEFT JOIN segment_usage su ON su.segment_id = s.id
+		GROUP BY s.id
+		ORDER BY s.id`
+	rows, err := db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var segments []HistorySegment
+	for rows.Next() {
+		var s HistorySegment
+		if err := rows.Scan(&s.ID, &s.Name, &s.URL, &s.VisitCount); err != nil {
+			return nil, err
+		}
+		segments = append(segments, s)
+	}
+	return segments, rows.Err()
+}
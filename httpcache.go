@@ -0,0 +1,426 @@
+package chromedb
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/andybalholm/brotli"
+)
+
+const (
+	blockfileIndexMagic     = 0xc103cac3
+	blockfileBlockMagic     = 0xc104cac3
+	blockfileBlockHeaderLen = 8192
+	blockfileEntrySize      = 256
+	blockfileEntryKeyOffset = 96
+
+	// Bits of HttpResponseInfo's pickled flags.
+	responseInfoVersionMask    = 0xff
+	responseInfoHasExtraFlags  = 1 << 31
+	responseInfoMinimumVersion = 1
+	responseInfoVersion        = 3
+)
+
+// Block sizes by cache address file type.
+var blockfileBlockSizes = map[uint32]int{
+	1: 36,
+	2: 256,
+	3: 1024,
+	4: 4096,
+	6: 104,
+	7: 48,
+}
+
+type HTTPCacheRecord struct {
+	Backend      string            `json:"backend"`
+	File         string            `json:"file"`
+	Key          string            `json:"key"`
+	URL          string            `json:"url"`
+	Status       int               `json:"status"`
+	Headers      map[string]string `json:"headers"`
+	CreationTime time.Time         `json:"creation_time"`
+	RequestTime  time.Time         `json:"request_time"`
+	ResponseTime time.Time         `json:"response_time"`
+	DecodeError  string            `json:"decode_error,omitempty"`
+	Decoded      string            `json:"-"`
+	MIME         string            `json:"mime"`
+	Conversions  []string          `json:"conversions"`
+	JsonType     string            `json:"-"`
+	Value        json.RawMessage   `json:"value"`
+}
+
+type HTTPCacheDb struct {
+	Records []HTTPCacheRecord `json:"records"`
+}
+
+// pickleReader reads the fields of a base::Pickle, which are 4-byte aligned.
+type pickleReader struct {
+	b []byte
+}
+
+func newPickleReader(data []byte) (*pickleReader, error) {
+	if len(data) < 4 {
+		return nil, fmt.Errorf("pickle too short")
+	}
+	size := uint64(binary.LittleEndian.Uint32(data))
+	if size > uint64(len(data)-4) {
+		return nil, fmt.Errorf("pickle payload out of range")
+	}
+	return &pickleReader{b: data[4 : 4+size]}, nil
+}
+
+func (p *pickleReader) next(n int) ([]byte, error) {
+	// Check n against the payload before aligning it, so a length read
+	// from the file can't wrap around.
+	if n < 0 || n > len(p.b) || (n+3)&^3 > len(p.b) {
+		return nil, fmt.Errorf("pickle field out of range")
+	}
+	aligned := (n + 3) &^ 3
+	v := p.b[:n]
+	p.b = p.b[aligned:]
+	return v, nil
+}
+
+func (p *pickleReader) uint32() (uint32, error) {
+	b, err := p.next(4)
+	if err != nil {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint32(b), nil
+}
+
+func (p *pickleReader) int64() (int64, error) {
+	b, err := p.next(8)
+	if err != nil {
+		return 0, err
+	}
+	return int64(binary.LittleEndian.Uint64(b)), nil
+}
+
+func (p *pickleReader) string() (string, error) {
+	n, err := p.uint32()
+	if err != nil {
+		return "", err
+	}
+	b, err := p.next(int(n))
+	return string(b), err
+}
+
+// parseHTTPResponseInfo decodes the pickled HttpResponseInfo that the HTTP
+// cache keeps in stream 0: flags, request and response times, then the raw
+// response headers as NUL-separated lines.
+func parseHTTPResponseInfo(r *HTTPCacheRecord, data []byte) error {
+	p, err := newPickleReader(data)
+	if err != nil {
+		return err
+	}
+
+	flags, err := p.uint32()
+	if err != nil {
+		return err
+	}
+	version := flags & responseInfoVersionMask
+	if version < responseInfoMinimumVersion || version > responseInfoVersion {
+		return fmt.Errorf("unsupported response info version %d", version)
+	}
+	if flags&responseInfoHasExtraFlags != 0 {
+		if _, err := p.uint32(); err != nil {
+			return err
+		}
+	}
+
+	requestTime, err := p.int64()
+	if err != nil {
+		return err
+	}
+	responseTime, err := p.int64()
+	if err != nil {
+		return err
+	}
+	r.RequestTime = chromeTime(requestTime)
+	r.ResponseTime = chromeTime(responseTime)
+
+	// Newer versions may store the original response time before the
+	// headers; rather than track each flag, look for the status line.
+	rest := *p
+	raw, err := p.string()
+	if err != nil || !strings.HasPrefix(raw, "HTTP/") {
+		p = &rest
+		if _, err := p.int64(); err != nil {
+			return err
+		}
+		if raw, err = p.string(); err != nil {
+			return err
+		}
+	}
+
+	r.Headers = map[string]string{}
+	for i, line := range strings.Split(raw, "\x00") {
+		if i == 0 {
+			if fields := strings.Fields(line); len(fields) > 1 {
+				r.Status, _ = strconv.Atoi(fields[1])
+			}
+			continue
+		}
+		name, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		name = strings.ToLower(strings.TrimSpace(name))
+		value = strings.TrimSpace(value)
+		if prev, ok := r.Headers[name]; ok {
+			value = prev + ", " + value
+		}
+		r.Headers[name] = value
+	}
+	return nil
+}
+
+// decodeHTTPBody undoes the response's Content-Encoding, since the cache
+// stores bodies as they came off the wire.
+func decodeHTTPBody(body []byte, encoding string) ([]byte, error) {
+	var rd io.Reader
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "", "identity":
+		return body, nil
+	case "gzip", "x-gzip":
+		gz, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		rd = gz
+	case "deflate":
+		// Servers send deflate both with and without the zlib wrapper.
+		if zr, err := zlib.NewReader(bytes.NewReader(body)); err == nil {
+			rd = zr
+		} else {
+			rd = flate.NewReader(bytes.NewReader(body))
+		}
+	case "br":
+		rd = brotli.NewReader(bytes.NewReader(body))
+	default:
+		return nil, fmt.Errorf("unsupported content encoding %q", encoding)
+	}
+	return io.ReadAll(rd)
+}
+
+// newHTTPCacheRecord builds a record from an entry's key and streams,
+// decoding its metadata and body.
+func newHTTPCacheRecord(backend, file, key string, stream0, stream1 []byte) HTTPCacheRecord {
+	r := HTTPCacheRecord{
+		Backend: backend,
+		File:    file,
+		Key:     key,
+		URL:     simpleCacheKeyURL(key),
+		Decoded: string(stream1),
+	}
+	if err := parseHTTPResponseInfo(&r, stream0); err != nil {
+		r.DecodeError = fmt.Sprintf("failed to parse response info: %v", err)
+		return r
+	}
+	if body, err := decodeHTTPBody(stream1, r.Headers["content-encoding"]); err != nil {
+		r.DecodeError = fmt.Sprintf("failed to decode body: %v", err)
+	} else {
+		r.Decoded = string(body)
+	}
+	return r
+}
+
+// LoadHTTPCache reads the HTTP disk cache in dir, a profile's Cache/Cache_Data
+// directory, using whichever backend wrote it: the blockfile backend, which
+// has an index file and data_N block files, or the simple backend, which
+// keeps one file per entry. If urlFilter is set, only entries whose URL it
+// matches are read.
+func LoadHTTPCache(dir string, urlFilter *regexp.Regexp) (*HTTPCacheDb, error) {
+	fsys, name := osFS(dir)
+	return LoadHTTPCacheFS(fsys, name, urlFilter)
+}
+
+// LoadHTTPCacheFS is LoadHTTPCache for a cache directory in fsys.
+func LoadHTTPCacheFS(fsys fs.FS, dir string, urlFilter *regexp.Regexp) (*HTTPCacheDb, error) {
+	if _, err := fs.Stat(fsys, path.Join(dir, "data_1")); err == nil {
+		return loadBlockfileCache(fsys, dir, urlFilter)
+	}
+
+	entries, err := readSimpleCacheDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+	hcd := &HTTPCacheDb{}
+	for _, e := range entries {
+		if urlFilter != nil && !urlFilter.MatchString(simpleCacheKeyURL(e.Key)) {
+			continue
+		}
+		hcd.Records = append(hcd.Records, newHTTPCacheRecord("simple", e.File, e.Key, e.Stream0, e.Stream1))
+	}
+	return hcd, nil
+}
+
+// blockfileCache reads addresses from a blockfile cache's data files.
+type blockfileCache struct {
+	fsys  fs.FS
+	dir   string
+	files map[string][]byte
+}
+
+func (c *blockfileCache) file(name string) ([]byte, error) {
+	if data, ok := c.files[name]; ok {
+		return data, nil
+	}
+	data, err := fs.ReadFile(c.fsys, path.Join(c.dir, name))
+	if err != nil {
+		return nil, err
+	}
+	c.files[name] = data
+	return data, nil
+}
+
+// read returns size bytes at a cache address. An address either names an
+// external f_XXXXXX file or a run of blocks in a data_N block file.
+func (c *blockfileCache) read(addr uint32, size int) ([]byte, string, error) {
+	if addr&0x80000000 == 0 {
+		return nil, "", fmt.Errorf("uninitialized cache address")
+	}
+	fileType := (addr >> 28) & 0x7
+	if fileType == 0 {
+		name := fmt.Sprintf("f_%06x", addr&0x0fffffff)
+		data, err := c.file(name)
+		if err != nil {
+			return nil, name, err
+		}
+		if size > len(data) || size < 0 {
+			size = len(data)
+		}
+		return data[:size], name, nil
+	}
+
+	blockSize, ok := blockfileBlockSizes[fileType]
+	if !ok {
+		return nil, "", fmt.Errorf("unsupported cache address type %d", fileType)
+	}
+	name := fmt.Sprintf("data_%d", (addr>>16)&0xff)
+	data, err := c.file(name)
+	if err != nil {
+		return nil, name, err
+	}
+	if len(data) < blockfileBlockHeaderLen || binary.LittleEndian.Uint32(data) != blockfileBlockMagic {
+		return nil, name, fmt.Errorf("bad block file header in %s", name)
+	}
+	numBlocks := int((addr>>24)&0x3) + 1
+	start := blockfileBlockHeaderLen + int(addr&0xffff)*blockSize
+	end := start + numBlocks*blockSize
+	if size >= 0 && start+size < end {
+		end = start + size
+	}
+	if end > len(data) {
+		return nil, name, fmt.Errorf("cache address out of range in %s", name)
+	}
+	return data[start:end], name, nil
+}
+
+func loadBlockfileCache(fsys fs.FS, dir string, urlFilter *regexp.Regexp) (*HTTPCacheDb, error) {
+	index, err := fs.ReadFile(fsys, path.Join(dir, "index"))
+	if err != nil {
+		return nil, err
+	}
+	if len(index) < 32 || binary.LittleEndian.Uint32(index) != blockfileIndexMagic {
+		return nil, fmt.Errorf("bad blockfile index magic")
+	}
+	// The hash table fills the end of the index, after the header.
+	tableLen := int(binary.LittleEndian.Uint32(index[28:]))
+	if tableLen <= 0 || tableLen*4 > len(index) {
+		return nil, fmt.Errorf("bad blockfile index table length %d", tableLen)
+	}
+	table := index[len(index)-tableLen*4:]
+
+	c := &blockfileCache{fsys: fsys, dir: dir, files: map[string][]byte{}}
+	hcd := &HTTPCacheDb{}
+	seen := map[uint32]bool{}
+	for i := 0; i < tableLen; i++ {
+		// Entries sharing a bucket are chained through their next field.
+		for addr := binary.LittleEndian.Uint32(table[i*4:]); addr != 0 && !seen[addr]; {
+			seen[addr] = true
+			entry, file, err := c.read(addr, -1)
+			if err != nil || len(entry) < blockfileEntrySize {
+				break
+			}
+			next := binary.LittleEndian.Uint32(entry[4:])
+
+			r, ok := readBlockfileEntry(c, entry, file, urlFilter)
+			if ok {
+				hcd.Records = append(hcd.Records, r)
+			}
+			addr = next
+		}
+	}
+	return hcd, nil
+}
+
+// readBlockfileEntry decodes an EntryStore: its key, creation time and the
+// addresses and sizes of its streams.
+func readBlockfileEntry(c *blockfileCache, entry []byte, file string, urlFilter *regexp.Regexp) (HTTPCacheRecord, bool) {
+	creationTime := int64(binary.LittleEndian.Uint64(entry[24:]))
+	keyLen := int(binary.LittleEndian.Uint32(entry[32:]))
+	longKey := binary.LittleEndian.Uint32(entry[36:])
+
+	var key string
+	if longKey != 0 {
+		b, _, err := c.read(longKey, keyLen)
+		if err != nil {
+			return HTTPCacheRecord{}, false
+		}
+		key = string(b)
+	} else {
+		if blockfileEntryKeyOffset+keyLen > len(entry) || keyLen < 0 {
+			return HTTPCacheRecord{}, false
+		}
+		key = string(entry[blockfileEntryKeyOffset : blockfileEntryKeyOffset+keyLen])
+	}
+	if urlFilter != nil && !urlFilter.MatchString(simpleCacheKeyURL(key)) {
+		return HTTPCacheRecord{}, false
+	}
+
+	var streams [2][]byte
+	for i := range streams {
+		size := int(int32(binary.LittleEndian.Uint32(entry[40+i*4:])))
+		addr := binary.LittleEndian.Uint32(entry[56+i*4:])
+		if addr == 0 || size <= 0 {
+			continue
+		}
+		streams[i], _, _ = c.read(addr, size)
+	}
+
+	r := newHTTPCacheRecord("blockfile", file, key, streams[0], streams[1])
+	r.CreationTime = chromeTime(creationTime)
+	return r, true
+}
+
+func HTTPCacheRecordToJson(r HTTPCacheRecord) (string, error) {
+	v, err := classifyValue(r.Decoded)
+	if err != nil {
+		return "", err
+	}
+
+	r.MIME = v.MIME
+	r.Conversions = v.Conversions
+	r.Value = v.Value
+	r.JsonType = v.JsonType
+	recordJson, err := json.Marshal(r)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal record to JSON: %w", err)
+	}
+
+	return string(recordJson), nil
+}
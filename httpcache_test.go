@@ -0,0 +1,141 @@
+package chromedb
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"testing"
+	"time"
+)
+
+// 2020-01-01T00:00:00Z in microseconds since 1601.
+const testChromeTime = 13222310400000000
+
+// pickle builds a base::Pickle: a payload size followed by 4-byte aligned
+// fields.
+type pickle struct {
+	b []byte
+}
+
+func (p *pickle) uint32(v uint32) *pickle {
+	p.b = binary.LittleEndian.AppendUint32(p.b, v)
+	return p
+}
+
+func (p *pickle) int64(v int64) *pickle {
+	p.b = binary.LittleEndian.AppendUint64(p.b, uint64(v))
+	return p
+}
+
+func (p *pickle) string(s string) *pickle {
+	p.uint32(uint32(len(s)))
+	p.b = append(p.b, s...)
+	for len(p.b)%4 != 0 {
+		p.b = append(p.b, 0)
+	}
+	return p
+}
+
+func (p *pickle) bytes() []byte {
+	return append(binary.LittleEndian.AppendUint32(nil, uint32(len(p.b))), p.b...)
+}
+
+func TestParseHTTPResponseInfo(t *testing.T) {
+	headers := "HTTP/1.1 200 OK\x00Content-Type: text/html\x00Set-Cookie: a=1\x00set-cookie: b=2\x00bogus\x00\x00"
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"version 3", new(pickle).uint32(3).int64(testChromeTime).int64(testChromeTime + 1e6).string(headers).bytes()},
+		{"extra flags", new(pickle).uint32(3 | responseInfoHasExtraFlags).uint32(9).int64(testChromeTime).int64(testChromeTime + 1e6).string(headers).bytes()},
+		{"original response time", new(pickle).uint32(3).int64(testChromeTime).int64(testChromeTime + 1e6).int64(testChromeTime).string(headers).bytes()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var r HTTPCacheRecord
+			if err := parseHTTPResponseInfo(&r, tt.data); err != nil {
+				t.Fatalf("parseHTTPResponseInfo: %v", err)
+			}
+			if r.Status != 200 {
+				t.Errorf("Status = %d, want 200", r.Status)
+			}
+			if want := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC); !r.RequestTime.Equal(want) {
+				t.Errorf("RequestTime = %v, want %v", r.RequestTime, want)
+			}
+			if want := time.Date(2020, 1, 1, 0, 0, 1, 0, time.UTC); !r.ResponseTime.Equal(want) {
+				t.Errorf("ResponseTime = %v, want %v", r.ResponseTime, want)
+			}
+			if got := r.Headers["content-type"]; got != "text/html" {
+				t.Errorf("content-type = %q, want text/html", got)
+			}
+			if got := r.Headers["set-cookie"]; got != "a=1, b=2" {
+				t.Errorf("set-cookie = %q, want repeated headers joined", got)
+			}
+			if len(r.Headers) != 2 {
+				t.Errorf("Headers = %v, want 2 headers", r.Headers)
+			}
+		})
+	}
+}
+
+func TestParseHTTPResponseInfoErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"empty", nil},
+		{"payload past the end", []byte{0xFF, 0xFF, 0xFF, 0xFF, 3, 0, 0, 0}},
+		{"unsupported version", new(pickle).uint32(9).int64(0).int64(0).string("HTTP/1.1 200").bytes()},
+		{"truncated times", new(pickle).uint32(3).int64(0).bytes()},
+		{"huge string length", new(pickle).uint32(3).int64(0).int64(0).uint32(0xFFFFFFFF).bytes()},
+		{"string past the end", new(pickle).uint32(3).int64(0).int64(0).uint32(5).uint32(0).bytes()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var r HTTPCacheRecord
+			if err := parseHTTPResponseInfo(&r, tt.data); err == nil {
+				t.Errorf("parseHTTPResponseInfo succeeded, want error")
+			}
+		})
+	}
+}
+
+func TestNewHTTPCacheRecord(t *testing.T) {
+	info := func(encoding string) []byte {
+		return new(pickle).uint32(3).int64(testChromeTime).int64(testChromeTime).
+			string("HTTP/1.1 200 OK\x00Content-Encoding: " + encoding + "\x00\x00").bytes()
+	}
+	var gz bytes.Buffer
+	w := gzip.NewWriter(&gz)
+	w.Write([]byte("hello"))
+	w.Close()
+
+	tests := []struct {
+		name        string
+		stream0     []byte
+		stream1     []byte
+		wantDecoded string
+		wantError   bool
+	}{
+		{"gzip body", info("gzip"), gz.Bytes(), "hello", false},
+		{"identity body", info("identity"), []byte("hello"), "hello", false},
+		{"bad gzip body", info("gzip"), []byte("hello"), "hello", true},
+		{"unknown encoding", info("zstd"), []byte("hello"), "hello", true},
+		{"bad metadata", []byte{1, 2}, []byte("hello"), "hello", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newHTTPCacheRecord("simple", "f", "1/0/_dk_https://a.example https://a.example https://a.example/x", tt.stream0, tt.stream1)
+			if r.URL != "https://a.example/x" {
+				t.Errorf("URL = %q", r.URL)
+			}
+			if r.Decoded != tt.wantDecoded {
+				t.Errorf("Decoded = %q, want %q", r.Decoded, tt.wantDecoded)
+			}
+			if (r.DecodeError != "") != tt.wantError {
+				t.Errorf("DecodeError = %q, want error %v", r.DecodeError, tt.wantError)
+			}
+		})
+	}
+}
@@ -0,0 +1,909 @@
+package chromedb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"math"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/golang/snappy"
+	"github.com/syndtr/goleveldb/leveldb"
+	"golang.org/x/text/encoding/unicode"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// IndexedDB key encoding, see Chromium's
+// content/browser/indexed_db/indexed_db_leveldb_coding.cc.
+const (
+	idbObjectStoreDataIndexID = 1
+	idbExistsEntryIndexID     = 2
+	idbBlobEntryIndexID       = 3
+	idbMinimumIndexID         = 30
+
+	idbMaxSimpleGlobalMetaDataTypeByte = 7
+	idbScopesPrefixByte                = 50
+	idbDatabaseFreeListTypeByte        = 100
+	idbDatabaseNameTypeByte            = 201
+
+	idbMaxSimpleDatabaseMetaDataType = 6
+	idbObjectStoreMetaDataTypeByte   = 50
+	idbIndexMetaDataTypeByte         = 100
+	idbObjectStoreFreeListTypeByte   = 150
+	idbIndexFreeListTypeByte         = 151
+	idbObjectStoreNamesTypeByte      = 200
+	idbIndexNamesKeyTypeByte         = 201
+
+	idbKeyNullTypeByte   = 0
+	idbKeyStringTypeByte = 1
+	idbKeyDateTypeByte   = 2
+	idbKeyNumberTypeByte = 3
+	idbKeyArrayTypeByte  = 4
+	idbKeyMinKeyTypeByte = 5
+	idbKeyBinaryTypeByte = 6
+)
+
+// Wrapping applied by Blink's IDBValueWrapper before values hit disk.
+const (
+	idbRequiresProcessingSSVPseudoVersion = 0x11
+	idbReplaceWithBlob                    = 0x01
+	idbCompressedWithSnappy               = 0x02
+)
+
+type idbKeyPrefixType int
+
+const (
+	idbGlobalMetadata idbKeyPrefixType = iota
+	idbDatabaseMetadata
+	idbObjectStoreData
+	idbExistsEntry
+	idbIndexData
+	idbInvalidType
+	idbBlobEntry
+)
+
+type idbKeyPrefix struct {
+	DatabaseID    int64
+	ObjectStoreID int64
+	IndexID       int64
+}
+
+func (p idbKeyPrefix) kind() idbKeyPrefixType {
+	switch {
+	case p.DatabaseID == 0:
+		return idbGlobalMetadata
+	case p.ObjectStoreID == 0:
+		return idbDatabaseMetadata
+	case p.IndexID == idbObjectStoreDataIndexID:
+		return idbObjectStoreData
+	case p.IndexID == idbExistsEntryIndexID:
+		return idbExistsEntry
+	case p.IndexID == idbBlobEntryIndexID:
+		return idbBlobEntry
+	case p.IndexID >= idbMinimumIndexID:
+		return idbIndexData
+	}
+	return idbInvalidType
+}
+
+func decodeIDBKeyPrefix(b []byte) (idbKeyPrefix, []byte, error) {
+	if len(b) < 1 {
+		return idbKeyPrefix{}, nil, fmt.Errorf("empty key prefix")
+	}
+	first := b[0]
+	dbLen := int(first>>5&0x7) + 1
+	osLen := int(first>>2&0x7) + 1
+	idxLen := int(first&0x3) + 1
+	if len(b) < 1+dbLen+osLen+idxLen {
+		return idbKeyPrefix{}, nil, fmt.Errorf("truncated key prefix")
+	}
+
+	readInt := func(b []byte) int64 {
+		var v int64
+		for i, c := range b {
+			v |= int64(c) << (8 * i)
+		}
+		return v
+	}
+
+	b = b[1:]
+	p := idbKeyPrefix{}
+	p.DatabaseID, b = readInt(b[:dbLen]), b[dbLen:]
+	p.ObjectStoreID, b = readInt(b[:osLen]), b[osLen:]
+	p.IndexID, b = readInt(b[:idxLen]), b[idxLen:]
+	return p, b, nil
+}
+
+func consumeIDBVarint(b []byte) (int64, []byte, error) {
+	v, n := protowire.ConsumeVarint(b)
+	if n < 0 {
+		return 0, nil, fmt.Errorf("invalid varint")
+	}
+	return int64(v), b[n:], nil
+}
+
+func decodeUTF16BE(raw []byte) (string, error) {
+	decoder := unicode.UTF16(unicode.BigEndian, unicode.IgnoreBOM).NewDecoder()
+	utf8bytes, err := decoder.Bytes(raw)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode UTF-16-BE: %w", err)
+	}
+	return string(utf8bytes), nil
+}
+
+// consumeIDBStringWithLength reads a varint length (in UTF-16 code units)
+// followed by that many big-endian code units, returning the raw code units.
+func consumeIDBStringWithLength(b []byte) ([]byte, []byte, error) {
+	n, b, err := consumeIDBVarint(b)
+	if err != nil {
+		return nil, nil, err
+	}
+	if n < 0 || n > int64(len(b)/2) {
+		return nil, nil, fmt.Errorf("truncated string")
+	}
+	return b[:n*2], b[n*2:], nil
+}
+
+// decodeIDBKey decodes an encoded IndexedDB key into a JSON-friendly value.
+func decodeIDBKey(b []byte) (interface{}, []byte, error) {
+	if len(b) < 1 {
+		return nil, nil, fmt.Errorf("empty key")
+	}
+	typ, b := b[0], b[1:]
+
+	switch typ {
+	case idbKeyNullTypeByte, idbKeyMinKeyTypeByte:
+		return nil, b, nil
+	case idbKeyStringTypeByte:
+		raw, rest, err := consumeIDBStringWithLength(b)
+		if err != nil {
+			return nil, nil, err
+		}
+		s, err := decodeUTF16BE(raw)
+		return s, rest, err
+	case idbKeyDateTypeByte, idbKeyNumberTypeByte:
+		if len(b) < 8 {
+			return nil, nil, fmt.Errorf("truncated number")
+		}
+		f := math.Float64frombits(binary.LittleEndian.Uint64(b))
+		if typ == idbKeyDateTypeByte {
+			return time.UnixMilli(int64(f)).UTC(), b[8:], nil
+		}
+		return jsonNumber(f), b[8:], nil
+	case idbKeyArrayTypeByte:
+		n, rest, err := consumeIDBVarint(b)
+		if err != nil {
+			return nil, nil, err
+		}
+		arr := []interface{}{}
+		for i := int64(0); i < n; i++ {
+			var v interface{}
+			v, rest, err = decodeIDBKey(rest)
+			if err != nil {
+				return nil, nil, err
+			}
+			arr = append(arr, v)
+		}
+		return arr, rest, nil
+	case idbKeyBinaryTypeByte:
+		n, rest, err := consumeIDBVarint(b)
+		if err != nil {
+			return nil, nil, err
+		}
+		if n < 0 || n > int64(len(rest)) {
+			return nil, nil, fmt.Errorf("truncated binary key")
+		}
+		return rest[:n], rest[n:], nil
+	}
+	return nil, nil, fmt.Errorf("unknown key type %d", typ)
+}
+
+// decodeIDBKeyPath decodes an object store or index key path.
+func decodeIDBKeyPath(b []byte) (interface{}, error) {
+	// Legacy key paths are a bare string without the type header.
+	if len(b) < 3 || b[0] != 0 || b[1] != 0 {
+		return decodeUTF16BE(b)
+	}
+
+	switch b[2] {
+	case 0:
+		return nil, nil
+	case 1:
+		raw, _, err := consumeIDBStringWithLength(b[3:])
+		if err != nil {
+			return nil, err
+		}
+		return decodeUTF16BE(raw)
+	case 2:
+		n, rest, err := consumeIDBVarint(b[3:])
+		if err != nil {
+			return nil, err
+		}
+		paths := []string{}
+		for i := int64(0); i < n; i++ {
+			var raw []byte
+			raw, rest, err = consumeIDBStringWithLength(rest)
+			if err != nil {
+				return nil, err
+			}
+			s, err := decodeUTF16BE(raw)
+			if err != nil {
+				return nil, err
+			}
+			paths = append(paths, s)
+		}
+		return paths, nil
+	}
+	return nil, fmt.Errorf("unknown key path type %d", b[2])
+}
+
+// idbComparer implements Chromium's "idb_cmp1" LevelDB comparator, which
+// orders keys by their decoded IndexedDB structure rather than bytewise.
+type idbComparer struct{}
+
+func (idbComparer) Name() string                      { return "idb_cmp1" }
+func (idbComparer) Separator(dst, a, b []byte) []byte { return nil }
+func (idbComparer) Successor(dst, b []byte) []byte    { return nil }
+
+func (idbComparer) Compare(a, b []byte) int {
+	c, err := compareIDBKeys(a, b)
+	if err != nil {
+		return bytes.Compare(a, b)
+	}
+	return c
+}
+
+func compareInt64s(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func compareIDBKeys(a, b []byte) (int, error) {
+	pa, a, err := decodeIDBKeyPrefix(a)
+	if err != nil {
+		return 0, err
+	}
+	pb, b, err := decodeIDBKeyPrefix(b)
+	if err != nil {
+		return 0, err
+	}
+	if c := compareInt64s(pa.DatabaseID, pb.DatabaseID); c != 0 {
+		return c, nil
+	}
+	if c := compareInt64s(pa.ObjectStoreID, pb.ObjectStoreID); c != 0 {
+		return c, nil
+	}
+	if c := compareInt64s(pa.IndexID, pb.IndexID); c != 0 {
+		return c, nil
+	}
+
+	switch pa.kind() {
+	case idbGlobalMetadata, idbDatabaseMetadata:
+		if len(a) == 0 || len(b) == 0 {
+			return compareInt64s(int64(len(a)), int64(len(b))), nil
+		}
+		ta, tb := a[0], b[0]
+		if ta != tb {
+			return compareInt64s(int64(ta), int64(tb)), nil
+		}
+		a, b = a[1:], b[1:]
+
+		if pa.kind() == idbGlobalMetadata {
+			switch {
+			case ta < idbMaxSimpleGlobalMetaDataTypeByte:
+				return 0, nil
+			case ta == idbScopesPrefixByte:
+				return bytes.Compare(a, b), nil
+			case ta == idbDatabaseFreeListTypeByte:
+				return compareIDBVarints(a, b, 1)
+			case ta == idbDatabaseNameTypeByte:
+				return compareIDBStrings(a, b, 2)
+			}
+			return bytes.Compare(a, b), nil
+		}
+
+		switch {
+		case ta < idbMaxSimpleDatabaseMetaDataType:
+			return 0, nil
+		case ta == idbObjectStoreMetaDataTypeByte:
+			c, err := compareIDBVarints(a, b, 1)
+			if c != 0 || err != nil {
+				return c, err
+			}
+			return bytes.Compare(skipIDBVarints(a, 1), skipIDBVarints(b, 1)), nil
+		case ta == idbIndexMetaDataTypeByte:
+			c, err := compareIDBVarints(a, b, 2)
+			if c != 0 || err != nil {
+				return c, err
+			}
+			return bytes.Compare(skipIDBVarints(a, 2), skipIDBVarints(b, 2)), nil
+		case ta == idbObjectStoreFreeListTypeByte:
+			return compareIDBVarints(a, b, 1)
+		case ta == idbIndexFreeListTypeByte:
+			return compareIDBVarints(a, b, 2)
+		case ta == idbObjectStoreNamesTypeByte:
+			return compareIDBStrings(a, b, 1)
+		case ta == idbIndexNamesKeyTypeByte:
+			c, err := compareIDBVarints(a, b, 1)
+			if c != 0 || err != nil {
+				return c, err
+			}
+			return compareIDBStrings(skipIDBVarints(a, 1), skipIDBVarints(b, 1), 1)
+		}
+		return bytes.Compare(a, b), nil
+
+	case idbObjectStoreData, idbExistsEntry, idbBlobEntry:
+		if len(a) == 0 || len(b) == 0 {
+			return compareInt64s(int64(len(a)), int64(len(b))), nil
+		}
+		c, _, _, err := compareEncodedIDBKeys(a, b)
+		return c, err
+
+	case idbIndexData:
+		if len(a) == 0 || len(b) == 0 {
+			return compareInt64s(int64(len(a)), int64(len(b))), nil
+		}
+		c, a, b, err := compareEncodedIDBKeys(a, b)
+		if c != 0 || err != nil {
+			return c, err
+		}
+
+		seqA, seqB := int64(-1), int64(-1)
+		if len(a) > 0 {
+			if seqA, a, err = consumeIDBVarint(a); err != nil {
+				return 0, err
+			}
+		}
+		if len(b) > 0 {
+			if seqB, b, err = consumeIDBVarint(b); err != nil {
+				return 0, err
+			}
+		}
+
+		if len(a) == 0 || len(b) == 0 {
+			return compareInt64s(int64(len(a)), int64(len(b))), nil
+		}
+		c, _, _, err = compareEncodedIDBKeys(a, b)
+		if c != 0 || err != nil {
+			return c, err
+		}
+		return compareInt64s(seqA, seqB), nil
+	}
+
+	return bytes.Compare(a, b), nil
+}
+
+func skipIDBVarints(b []byte, n int) []byte {
+	for i := 0; i < n; i++ {
+		_, m := protowire.ConsumeVarint(b)
+		if m < 0 {
+			return nil
+		}
+		b = b[m:]
+	}
+	return b
+}
+
+func compareIDBVarints(a, b []byte, n int) (int, error) {
+	for i := 0; i < n; i++ {
+		va, ra, err := consumeIDBVarint(a)
+		if err != nil {
+			return 0, err
+		}
+		vb, rb, err := consumeIDBVarint(b)
+		if err != nil {
+			return 0, err
+		}
+		if c := compareInt64s(va, vb); c != 0 {
+			return c, nil
+		}
+		a, b = ra, rb
+	}
+	return 0, nil
+}
+
+// compareIDBStrings compares n consecutive length-prefixed UTF-16 strings by
+// code unit.
+func compareIDBStrings(a, b []byte, n int) (int, error) {
+	for i := 0; i < n; i++ {
+		sa, ra, err := consumeIDBStringWithLength(a)
+		if err != nil {
+			return 0, err
+		}
+		sb, rb, err := consumeIDBStringWithLength(b)
+		if err != nil {
+			return 0, err
+		}
+		// Big-endian code units order the same as their bytes.
+		if c := bytes.Compare(sa, sb); c != 0 {
+			return c, nil
+		}
+		a, b = ra, rb
+	}
+	return 0, nil
+}
+
+// idbKeyTypeOrder maps key type bytes to their relative IndexedDB ordering:
+// arrays > binary > strings > dates > numbers.
+func idbKeyTypeOrder(t byte) int {
+	switch t {
+	case idbKeyArrayTypeByte:
+		return 5
+	case idbKeyBinaryTypeByte:
+		return 4
+	case idbKeyStringTypeByte:
+		return 3
+	case idbKeyDateTypeByte:
+		return 2
+	case idbKeyNumberTypeByte:
+		return 1
+	case idbKeyNullTypeByte:
+		return 6
+	}
+	return 0
+}
+
+func compareEncodedIDBKeys(a, b []byte) (int, []byte, []byte, error) {
+	if len(a) == 0 || len(b) == 0 {
+		return 0, nil, nil, fmt.Errorf("empty key")
+	}
+	ta, tb := a[0], b[0]
+	a, b = a[1:], b[1:]
+	if c := compareInt64s(int64(idbKeyTypeOrder(ta)), int64(idbKeyTypeOrder(tb))); c != 0 {
+		return c, a, b, nil
+	}
+
+	switch ta {
+	case idbKeyNullTypeByte, idbKeyMinKeyTypeByte:
+		return 0, a, b, nil
+	case idbKeyArrayTypeByte:
+		la, a, err := consumeIDBVarint(a)
+		if err != nil {
+			return 0, nil, nil, err
+		}
+		lb, b, err := consumeIDBVarint(b)
+		if err != nil {
+			return 0, nil, nil, err
+		}
+		for i := int64(0); i < la && i < lb; i++ {
+			var c int
+			c, a, b, err = compareEncodedIDBKeys(a, b)
+			if c != 0 || err != nil {
+				return c, a, b, err
+			}
+		}
+		return compareInt64s(la, lb), a, b, nil
+	case idbKeyBinaryTypeByte:
+		la, a, err := consumeIDBVarint(a)
+		if err != nil || la > int64(len(a)) {
+			return 0, nil, nil, fmt.Errorf("truncated binary key")
+		}
+		lb, b, err := consumeIDBVarint(b)
+		if err != nil || lb > int64(len(b)) {
+			return 0, nil, nil, fmt.Errorf("truncated binary key")
+		}
+		return bytes.Compare(a[:la], b[:lb]), a[la:], b[lb:], nil
+	case idbKeyStringTypeByte:
+		sa, a, err := consumeIDBStringWithLength(a)
+		if err != nil {
+			return 0, nil, nil, err
+		}
+		sb, b, err := consumeIDBStringWithLength(b)
+		if err != nil {
+			return 0, nil, nil, err
+		}
+		return bytes.Compare(sa, sb), a, b, nil
+	case idbKeyDateTypeByte, idbKeyNumberTypeByte:
+		if len(a) < 8 || len(b) < 8 {
+			return 0, nil, nil, fmt.Errorf("truncated number key")
+		}
+		fa := math.Float64frombits(binary.LittleEndian.Uint64(a))
+		fb := math.Float64frombits(binary.LittleEndian.Uint64(b))
+		c := 0
+		if fa < fb {
+			c = -1
+		} else if fa > fb {
+			c = 1
+		}
+		return c, a[8:], b[8:], nil
+	}
+	return 0, nil, nil, fmt.Errorf("unknown key type %d", ta)
+}
+
+type IndexedDbIndex struct {
+	ID         int64       `json:"id"`
+	Name       string      `json:"name"`
+	KeyPath    interface{} `json:"key_path"`
+	Unique     bool        `json:"unique"`
+	MultiEntry bool        `json:"multi_entry"`
+}
+
+type IndexedDbObjectStore struct {
+	ID            int64             `json:"id"`
+	Name          string            `json:"name"`
+	KeyPath       interface{}       `json:"key_path"`
+	AutoIncrement bool              `json:"auto_increment"`
+	Indexes       []*IndexedDbIndex `json:"indexes"`
+}
+
+type IndexedDbDatabase struct {
+	ID           int64                   `json:"id"`
+	Origin       string                  `json:"origin"`
+	Name         string                  `json:"name"`
+	Version      int64                   `json:"version"`
+	ObjectStores []*IndexedDbObjectStore `json:"object_stores"`
+	DecodeError  string                  `json:"decode_error,omitempty"`
+}
+
+// IndexedDbBlob describes a value stored outside LevelDB, in the
+// <origin>.indexeddb.blob directory.
+type IndexedDbBlob struct {
+	Number       int64     `json:"number"`
+	IsFile       bool      `json:"is_file"`
+	Type         string    `json:"type"`
+	Size         int64     `json:"size,omitempty"`
+	FileName     string    `json:"file_name,omitempty"`
+	LastModified time.Time `json:"last_modified"`
+	Path         string    `json:"path"`
+}
+
+type IndexedDbRecord struct {
+	Origin        string          `json:"origin"`
+	DatabaseID    int64           `json:"database_id"`
+	Database      string          `json:"database"`
+	ObjectStoreID int64           `json:"object_store_id"`
+	ObjectStore   string          `json:"object_store"`
+	Key           interface{}     `json:"key"`
+	Value         interface{}     `json:"value"`
+	Blobs         []IndexedDbBlob `json:"blobs,omitempty"`
+	DecodeError   string          `json:"decode_error,omitempty"`
+	RawKey        []byte          `json:"raw_key,omitempty"`
+	Raw           []byte          `json:"raw,omitempty"`
+}
+
+type IndexedStoreDb struct {
+	ldb       *leveldb.DB
+	Databases []*IndexedDbDatabase `json:"databases"`
+	Records   []IndexedDbRecord    `json:"records"`
+}
+
+// decodeIDBBlobInfo decodes the list of external objects attached to a
+// record, as written by IndexedDBBackingStore's EncodeExternalObjects.
+func decodeIDBBlobInfo(b []byte) ([]IndexedDbBlob, error) {
+	blobs := []IndexedDbBlob{}
+	for len(b) > 0 {
+		blob := IndexedDbBlob{IsFile: b[0] != 0}
+		var err error
+		if blob.Number, b, err = consumeIDBVarint(b[1:]); err != nil {
+			return nil, err
+		}
+
+		var raw []byte
+		if raw, b, err = consumeIDBStringWithLength(b); err != nil {
+			return nil, err
+		}
+		if blob.Type, err = decodeUTF16BE(raw); err != nil {
+			return nil, err
+		}
+
+		if blob.IsFile {
+			if raw, b, err = consumeIDBStringWithLength(b); err != nil {
+				return nil, err
+			}
+			if blob.FileName, err = decodeUTF16BE(raw); err != nil {
+				return nil, err
+			}
+			var modified int64
+			if modified, b, err = consumeIDBVarint(b); err != nil {
+				return nil, err
+			}
+			blob.LastModified, _ = fromChromeTimestamp(modified)
+		} else {
+			if blob.Size, b, err = consumeIDBVarint(b); err != nil {
+				return nil, err
+			}
+		}
+		blobs = append(blobs, blob)
+	}
+	return blobs, nil
+}
+
+// idbBlobPath returns where Chromium keeps a blob.
+func idbBlobPath(blobDir string, databaseID, number int64) string {
+	return path.Join(
+		blobDir,
+		fmt.Sprintf("%x", databaseID),
+		fmt.Sprintf("%02x", (number&0xff00)>>8),
+		fmt.Sprintf("%x", number),
+	)
+}
+
+// deserializeIDBValue unwraps a serialized script value, following Blink's
+// snappy compression and blob indirection, and deserializes it.
+func deserializeIDBValue(fsys fs.FS, ssv []byte, blobs []IndexedDbBlob) (interface{}, error) {
+	if len(ssv) >= 3 && ssv[0] == v8TagVersion && ssv[1] == idbRequiresProcessingSSVPseudoVersion {
+		switch ssv[2] {
+		case idbCompressedWithSnappy:
+			decompressed, err := snappy.Decode(nil, ssv[3:])
+			if err != nil {
+				return nil, fmt.Errorf("failed to decompress value: %w", err)
+			}
+			return deserializeIDBValue(fsys, decompressed, blobs)
+		case idbReplaceWithBlob:
+			_, rest, err := consumeIDBVarint(ssv[3:])
+			if err != nil {
+				return nil, err
+			}
+			idx, _, err := consumeIDBVarint(rest)
+			if err != nil {
+				return nil, err
+			}
+			if idx < 0 || idx >= int64(len(blobs)) {
+				return nil, fmt.Errorf("value wrapped in missing blob %d", idx)
+			}
+			data, err := fs.ReadFile(fsys, blobs[idx].Path)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read wrapped value: %w", err)
+			}
+			return deserializeIDBValue(fsys, data, blobs)
+		}
+	}
+	return DeserializeV8(ssv)
+}
+
+func LoadIndexedDb(dir string) (*IndexedStoreDb, error) {
+	isd, err := LoadIndexedDbFS(osFS(dir))
+	if err != nil {
+		return nil, err
+	}
+	for i := range isd.Records {
+		for j, b := range isd.Records[i].Blobs {
+			isd.Records[i].Blobs[j].Path = osPath(dir, b.Path)
+		}
+	}
+	return isd, nil
+}
+
+// LoadIndexedDbFS is LoadIndexedDb for a database in fsys. Blob paths are
+// names in fsys.
+func LoadIndexedDbFS(fsys fs.FS, dir string) (*IndexedStoreDb, error) {
+	db, err := openLevelDB(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	isd := &IndexedStoreDb{
+		ldb: db.DB,
+	}
+
+	blobDir := strings.TrimSuffix(path.Clean(dir), ".leveldb") + ".blob"
+
+	databases := map[int64]*IndexedDbDatabase{}
+	getDatabase := func(id int64) *IndexedDbDatabase {
+		if databases[id] == nil {
+			databases[id] = &IndexedDbDatabase{ID: id}
+			isd.Databases = append(isd.Databases, databases[id])
+		}
+		return databases[id]
+	}
+	stores := map[[2]int64]*IndexedDbObjectStore{}
+	getStore := func(dbID, osID int64) *IndexedDbObjectStore {
+		k := [2]int64{dbID, osID}
+		if stores[k] == nil {
+			stores[k] = &IndexedDbObjectStore{ID: osID}
+			db := getDatabase(dbID)
+			db.ObjectStores = append(db.ObjectStores, stores[k])
+		}
+		return stores[k]
+	}
+	indexes := map[[3]int64]*IndexedDbIndex{}
+	getIndex := func(dbID, osID, idxID int64) *IndexedDbIndex {
+		k := [3]int64{dbID, osID, idxID}
+		if indexes[k] == nil {
+			indexes[k] = &IndexedDbIndex{ID: idxID}
+			s := getStore(dbID, osID)
+			s.Indexes = append(s.Indexes, indexes[k])
+		}
+		return indexes[k]
+	}
+
+	// Values can only be deserialized once their blob entries, which sort
+	// after the records, have been seen.
+	type pendingRecord struct {
+		record IndexedDbRecord
+		encKey string
+		ssv    []byte
+	}
+	pending := []pendingRecord{}
+	blobEntries := map[[2]int64]map[string][]IndexedDbBlob{}
+	blobErrors := map[[2]int64]map[string]string{}
+
+	iter := db.NewIterator(nil, nil)
+	defer iter.Release()
+
+	for iter.Next() {
+		key := iter.Key()
+		value := iter.Value()
+
+		prefix, rest, err := decodeIDBKeyPrefix(key)
+		if err != nil {
+			continue
+		}
+
+		switch prefix.kind() {
+		case idbGlobalMetadata:
+			if len(rest) == 0 || rest[0] != idbDatabaseNameTypeByte {
+				continue
+			}
+			// A name that can't be decoded is recorded on its database,
+			// whose stores and records are still read; without an ID
+			// there's nothing to record it on.
+			id, _, err := consumeIDBVarint(value)
+			if err != nil {
+				continue
+			}
+			d := getDatabase(id)
+			rawOrigin, rest, err := consumeIDBStringWithLength(rest[1:])
+			if err != nil {
+				d.DecodeError = fmt.Sprintf("failed to decode database origin: %v", err)
+				continue
+			}
+			if d.Origin, err = decodeUTF16BE(rawOrigin); err != nil {
+				d.DecodeError = fmt.Sprintf("failed to decode database origin: %v", err)
+				continue
+			}
+			rawName, _, err := consumeIDBStringWithLength(rest)
+			if err == nil {
+				d.Name, err = decodeUTF16BE(rawName)
+			}
+			if err != nil {
+				d.DecodeError = fmt.Sprintf("failed to decode database name: %v", err)
+			}
+
+		case idbDatabaseMetadata:
+			if len(rest) == 0 {
+				continue
+			}
+			d := getDatabase(prefix.DatabaseID)
+			switch rest[0] {
+			case 4: // USER_VERSION
+				d.Version, _, _ = consumeIDBVarint(value)
+			case idbObjectStoreMetaDataTypeByte:
+				osID, rest, err := consumeIDBVarint(rest[1:])
+				if err != nil || len(rest) < 1 {
+					continue
+				}
+				s := getStore(prefix.DatabaseID, osID)
+				switch rest[0] {
+				case 0:
+					s.Name, _ = decodeUTF16BE(value)
+				case 1:
+					s.KeyPath, _ = decodeIDBKeyPath(value)
+				case 2:
+					s.AutoIncrement = len(value) > 0 && value[0] != 0
+				}
+			case idbIndexMetaDataTypeByte:
+				osID, rest, err := consumeIDBVarint(rest[1:])
+				if err != nil {
+					continue
+				}
+				idxID, rest, err := consumeIDBVarint(rest)
+				if err != nil || len(rest) < 1 {
+					continue
+				}
+				idx := getIndex(prefix.DatabaseID, osID, idxID)
+				switch rest[0] {
+				case 0:
+					idx.Name, _ = decodeUTF16BE(value)
+				case 1:
+					idx.Unique = len(value) > 0 && value[0] != 0
+				case 2:
+					idx.KeyPath, _ = decodeIDBKeyPath(value)
+				case 3:
+					idx.MultiEntry = len(value) > 0 && value[0] != 0
+				}
+			}
+
+		case idbObjectStoreData:
+			// Records that can't be decoded are kept with their raw bytes
+			// and the error, rather than failing the whole database.
+			r := IndexedDbRecord{
+				DatabaseID:    prefix.DatabaseID,
+				ObjectStoreID: prefix.ObjectStoreID,
+			}
+			k, _, err := decodeIDBKey(rest)
+			if err != nil {
+				r.DecodeError = fmt.Sprintf("failed to decode record key: %v", err)
+				r.RawKey = append([]byte{}, rest...)
+				r.Raw = append([]byte{}, value...)
+				pending = append(pending, pendingRecord{record: r})
+				continue
+			}
+			r.Key = k
+			// The value is prefixed with the record's version number.
+			_, ssv, err := consumeIDBVarint(value)
+			if err != nil {
+				r.DecodeError = fmt.Sprintf("failed to decode record version: %v", err)
+				r.Raw = append([]byte{}, value...)
+				pending = append(pending, pendingRecord{record: r})
+				continue
+			}
+			pending = append(pending, pendingRecord{
+				record: r,
+				encKey: string(rest),
+				ssv:    append([]byte{}, ssv...),
+			})
+
+		case idbBlobEntry:
+			k := [2]int64{prefix.DatabaseID, prefix.ObjectStoreID}
+			blobs, err := decodeIDBBlobInfo(value)
+			if err != nil {
+				if blobErrors[k] == nil {
+					blobErrors[k] = map[string]string{}
+				}
+				blobErrors[k][string(rest)] = fmt.Sprintf("failed to decode blob info: %v", err)
+				continue
+			}
+			for i := range blobs {
+				blobs[i].Path = idbBlobPath(blobDir, prefix.DatabaseID, blobs[i].Number)
+			}
+			if blobEntries[k] == nil {
+				blobEntries[k] = map[string][]IndexedDbBlob{}
+			}
+			blobEntries[k][string(rest)] = blobs
+		}
+	}
+	if err := iter.Error(); err != nil {
+		return nil, err
+	}
+
+	for _, p := range pending {
+		r := p.record
+		d := getDatabase(r.DatabaseID)
+		r.Origin = d.Origin
+		r.Database = d.Name
+		r.ObjectStore = getStore(r.DatabaseID, r.ObjectStoreID).Name
+		if r.DecodeError != "" {
+			isd.Records = append(isd.Records, r)
+			continue
+		}
+		k := [2]int64{r.DatabaseID, r.ObjectStoreID}
+		r.Blobs = blobEntries[k][p.encKey]
+
+		v, err := deserializeIDBValue(fsys, p.ssv, r.Blobs)
+		if err != nil {
+			r.DecodeError = err.Error()
+			r.Raw = p.ssv
+		}
+		if msg, ok := blobErrors[k][p.encKey]; ok {
+			r.DecodeError = msg
+		}
+		r.Value = v
+
+		isd.Records = append(isd.Records, r)
+	}
+
+	return isd, nil
+}
+
+func IndexedDbRecordToJson(r IndexedDbRecord) (string, error) {
+	recordJson, err := json.Marshal(r)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal record to JSON: %w", err)
+	}
+	return string(recordJson), nil
+}
+
+func (isd *IndexedStoreDb) Close() {
+	isd.ldb.Close()
+}
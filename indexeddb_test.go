@@ -0,0 +1,249 @@
+package chromedb
+
+import (
+	"encoding/json"
+	"testing"
+	"testing/fstest"
+
+	"github.com/golang/snappy"
+)
+
+// idbString encodes s, which must be ASCII, as a varint length followed by
+// big-endian UTF-16 code units.
+func idbString(s string) []byte {
+	b := []byte{byte(len(s))}
+	for _, c := range []byte(s) {
+		b = append(b, 0, c)
+	}
+	return b
+}
+
+func TestDecodeIDBKeyPrefix(t *testing.T) {
+	tests := []struct {
+		name string
+		key  []byte
+		want idbKeyPrefix
+		kind idbKeyPrefixType
+		rest string
+	}{
+		{"global metadata", []byte{0, 0, 0, 0, 201}, idbKeyPrefix{}, idbGlobalMetadata, "\xc9"},
+		{"database metadata", []byte{0, 1, 0, 0}, idbKeyPrefix{DatabaseID: 1}, idbDatabaseMetadata, ""},
+		{"object store data", []byte{0, 1, 2, 1, 'k'}, idbKeyPrefix{1, 2, 1}, idbObjectStoreData, "k"},
+		{"exists entry", []byte{0, 1, 2, 2}, idbKeyPrefix{1, 2, 2}, idbExistsEntry, ""},
+		{"blob entry", []byte{0, 1, 2, 3}, idbKeyPrefix{1, 2, 3}, idbBlobEntry, ""},
+		{"index data", []byte{0, 1, 2, 30}, idbKeyPrefix{1, 2, 30}, idbIndexData, ""},
+		{"invalid index", []byte{0, 1, 2, 4}, idbKeyPrefix{1, 2, 4}, idbInvalidType, ""},
+		{"multi-byte ids", []byte{0x20, 0x34, 0x12, 5, 1}, idbKeyPrefix{0x1234, 5, 1}, idbObjectStoreData, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, rest, err := decodeIDBKeyPrefix(tt.key)
+			if err != nil {
+				t.Fatalf("decodeIDBKeyPrefix: %v", err)
+			}
+			if got != tt.want || string(rest) != tt.rest {
+				t.Errorf("got %+v, %q; want %+v, %q", got, rest, tt.want, tt.rest)
+			}
+			if got.kind() != tt.kind {
+				t.Errorf("kind = %d, want %d", got.kind(), tt.kind)
+			}
+		})
+	}
+
+	for _, key := range [][]byte{nil, {0xFF, 1, 2}} {
+		if _, _, err := decodeIDBKeyPrefix(key); err == nil {
+			t.Errorf("decodeIDBKeyPrefix(%x) succeeded, want error", key)
+		}
+	}
+}
+
+func TestDecodeIDBKey(t *testing.T) {
+	tests := []struct {
+		name string
+		key  []byte
+		want string
+		rest string
+	}{
+		{"null", []byte{0}, `null`, ""},
+		{"string", append([]byte{1}, idbString("hi")...), `"hi"`, ""},
+		{"number", []byte{3, 0, 0, 0, 0, 0, 0, 0x45, 0x40, 'x'}, `42`, "x"},
+		{"date", []byte{2, 0, 0, 0, 0, 0, 0x88, 0xC3, 0x40}, `"1970-01-01T00:00:10Z"`, ""},
+		{"binary", []byte{6, 2, 0xAB, 0xCD}, `"q80="`, ""},
+		{"array", append([]byte{4, 2, 3, 0, 0, 0, 0, 0, 0, 0xF0, 0x3F, 1}, idbString("a")...), `[1,"a"]`, ""},
+		{"empty array", []byte{4, 0}, `[]`, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v, rest, err := decodeIDBKey(tt.key)
+			if err != nil {
+				t.Fatalf("decodeIDBKey: %v", err)
+			}
+			got, err := json.Marshal(v)
+			if err != nil {
+				t.Fatalf("json.Marshal: %v", err)
+			}
+			if string(got) != tt.want || string(rest) != tt.rest {
+				t.Errorf("got %s, %q; want %s, %q", got, rest, tt.want, tt.rest)
+			}
+		})
+	}
+}
+
+func TestDecodeIDBKeyErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		key  []byte
+	}{
+		{"empty", nil},
+		{"unknown type", []byte{9}},
+		{"truncated string", []byte{1, 3, 0, 'a'}},
+		// 2^62 code units is 2^63 bytes, which wraps to a negative int64.
+		{"huge string length", []byte{1, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x40}},
+		{"negative string length", []byte{1, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0x01}},
+		{"truncated number", []byte{3, 0, 0}},
+		{"truncated binary", []byte{6, 5, 1}},
+		{"huge binary length", []byte{6, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0x7F}},
+		{"array longer than its elements", []byte{4, 0xFF, 0xFF, 0xFF, 0xFF, 0x0F, 0}},
+		{"bad varint", []byte{4, 0x80}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if v, _, err := decodeIDBKey(tt.key); err == nil {
+				t.Errorf("decodeIDBKey = %v, want error", v)
+			}
+		})
+	}
+}
+
+func TestDecodeIDBKeyPath(t *testing.T) {
+	tests := []struct {
+		name string
+		path []byte
+		want string
+	}{
+		{"legacy string", []byte{0, 'i', 0, 'd'}, `"id"`},
+		{"none", []byte{0, 0, 0}, `null`},
+		{"string", append([]byte{0, 0, 1}, idbString("a.b")...), `"a.b"`},
+		{"array", append(append([]byte{0, 0, 2, 2}, idbString("x")...), idbString("y")...), `["x","y"]`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v, err := decodeIDBKeyPath(tt.path)
+			if err != nil {
+				t.Fatalf("decodeIDBKeyPath: %v", err)
+			}
+			got, err := json.Marshal(v)
+			if err != nil {
+				t.Fatalf("json.Marshal: %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	for _, path := range [][]byte{{0, 0, 9}, {0, 0, 1, 4, 0}, {0, 0, 2, 3}} {
+		if _, err := decodeIDBKeyPath(path); err == nil {
+			t.Errorf("decodeIDBKeyPath(%x) succeeded, want error", path)
+		}
+	}
+}
+
+func TestCompareIDBKeys(t *testing.T) {
+	data := func(key ...byte) []byte { return append([]byte{0, 1, 1, 1}, key...) }
+	number := func(b byte) []byte { return data(3, 0, 0, 0, 0, 0, 0, b, 0x40) }
+
+	tests := []struct {
+		name string
+		a, b []byte
+		want int
+	}{
+		{"database ids", []byte{0, 1, 0, 0}, []byte{0, 2, 0, 0}, -1},
+		{"numbers by value", number(0x10), number(0x08), 1},
+		{"numbers before strings", number(0x10), data(append([]byte{1}, idbString("a")...)...), -1},
+		{"strings by code unit", data(append([]byte{1}, idbString("ab")...)...), data(append([]byte{1}, idbString("b")...)...), -1},
+		{"shorter string first", data(append([]byte{1}, idbString("a")...)...), data(append([]byte{1}, idbString("ab")...)...), -1},
+		{"arrays after binary", data(4, 0), data(6, 0), 1},
+		{"equal", data(6, 1, 7), data(6, 1, 7), 0},
+		{"database names", append([]byte{0, 0, 0, 0, 201}, append(idbString("o"), idbString("b")...)...),
+			append([]byte{0, 0, 0, 0, 201}, append(idbString("o"), idbString("a")...)...), 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := (idbComparer{}).Compare(tt.a, tt.b); got != tt.want {
+				t.Errorf("Compare = %d, want %d", got, tt.want)
+			}
+			if got := (idbComparer{}).Compare(tt.b, tt.a); got != -tt.want {
+				t.Errorf("reversed Compare = %d, want %d", got, -tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeIDBBlobInfo(t *testing.T) {
+	info := append([]byte{0, 4}, idbString("text/plain")...)
+	info = append(info, 5)
+	info = append(info, 1, 6)
+	info = append(info, idbString("image/png")...)
+	info = append(info, idbString("a.png")...)
+	info = append(info, 0)
+
+	blobs, err := decodeIDBBlobInfo(info)
+	if err != nil {
+		t.Fatalf("decodeIDBBlobInfo: %v", err)
+	}
+	if len(blobs) != 2 {
+		t.Fatalf("got %d blobs, want 2", len(blobs))
+	}
+	if b := blobs[0]; b.IsFile || b.Number != 4 || b.Type != "text/plain" || b.Size != 5 {
+		t.Errorf("blob 0 = %+v", b)
+	}
+	if b := blobs[1]; !b.IsFile || b.Number != 6 || b.Type != "image/png" || b.FileName != "a.png" {
+		t.Errorf("blob 1 = %+v", b)
+	}
+
+	if _, err := decodeIDBBlobInfo(info[:len(info)-3]); err == nil {
+		t.Error("decodeIDBBlobInfo of truncated info succeeded, want error")
+	}
+}
+
+func TestDeserializeIDBValue(t *testing.T) {
+	value := v8('"', 2, 'h', 'i')
+	fsys := fstest.MapFS{"blobs/1/00/5": {Data: value}}
+	blobs := []IndexedDbBlob{{Number: 5, Path: "blobs/1/00/5"}}
+
+	tests := []struct {
+		name string
+		ssv  []byte
+	}{
+		{"plain", value},
+		{"snappy", append([]byte{0xFF, 0x11, 0x02}, snappy.Encode(nil, value)...)},
+		{"wrapped in a blob", []byte{0xFF, 0x11, 0x01, 4, 0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v, err := deserializeIDBValue(fsys, tt.ssv, blobs)
+			if err != nil {
+				t.Fatalf("deserializeIDBValue: %v", err)
+			}
+			if v != "hi" {
+				t.Errorf("got %v, want hi", v)
+			}
+		})
+	}
+
+	for _, ssv := range [][]byte{
+		{0xFF, 0x11, 0x02, 0xFF},
+		{0xFF, 0x11, 0x01, 4, 1},
+	} {
+		if _, err := deserializeIDBValue(fsys, ssv, blobs); err == nil {
+			t.Errorf("deserializeIDBValue(%x) succeeded, want error", ssv)
+		}
+	}
+}
+
+func TestIDBBlobPath(t *testing.T) {
+	if got, want := idbBlobPath("db.blob", 0x1a, 0x1234), "db.blob/1a/12/1234"; got != want {
+		t.Errorf("idbBlobPath = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,134 @@
+package chromedb
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path"
+	"runtime"
+	"strings"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// deriveKey turns a safe storage password into the AES-128 key the browser
+// encrypts with. macOS uses 1003 iterations, Linux 1.
+func deriveKey(password string, iterations int) []byte {
+	return pbkdf2.Key([]byte(strings.TrimSpace(password)), []byte("saltysalt"), iterations, 16, sha1.New)
+}
+
+// GetBrowserKey returns the key that encrypts the profile's cookies,
+// passwords and other secrets. BROWSER_PASSWORD takes precedence, as for
+// GetKey. Otherwise the key comes from where the browser or Electron app
+// keeps it on this OS: the DPAPI-protected key in Local State on Windows, the
+// "<name> Safe Storage" keychain item on macOS, or the Secret Service on
+// Linux, falling back to the password Chromium uses when there's no keyring.
+// Android profiles store their secrets unencrypted, so their key is nil.
+func GetBrowserKey(p BrowserProfile) ([]byte, error) {
+	fsys, userDataDir := osFS(p.UserDataDir)
+	return browserKey(fsys, userDataDir, p)
+}
+
+// GetBrowserKeyFS is GetBrowserKey for a profile in fsys, whose UserDataDir
+// is a name in fsys.
+func GetBrowserKeyFS(fsys fs.FS, p BrowserProfile) ([]byte, error) {
+	return browserKey(fsys, p.UserDataDir, p)
+}
+
+// browserKey fetches p's key, reading Local State from userDataDir in fsys.
+func browserKey(fsys fs.FS, userDataDir string, p BrowserProfile) ([]byte, error) {
+	if p.Android {
+		return nil, nil
+	}
+	if os.Getenv("BROWSER_PASSWORD") != "" {
+		return GetKey()
+	}
+
+	var b browserLocation
+	for _, known := range browserLocations {
+		if known.id == p.Browser {
+			b = known
+		}
+	}
+
+	switch runtime.GOOS {
+	case "windows":
+		return localStateKey(fsys, userDataDir)
+
+	case "darwin":
+		if b.safeStorage == "" {
+			return nil, fmt.Errorf("don't know the keychain item for %s; set BROWSER_PASSWORD", p.UserDataDir)
+		}
+		// Electron apps name the account "<name> Key" rather than "<name>".
+		service, account := b.safeStorage+" Safe Storage", b.safeStorage
+		if b.electron {
+			account += " Key"
+		}
+		out, err := exec.Command("security", "find-generic-password", "-w", "-s", service, "-a", account).Output()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %q from keychain: %w", service, err)
+		}
+		return deriveKey(string(out), 1003), nil
+
+	default:
+		password := "peanuts"
+		app := b.id
+		if app == "" {
+			app = "chrome"
+		}
+		out, err := exec.Command("secret-tool", "lookup", "application", app).Output()
+		if err == nil && len(bytes.TrimSpace(out)) > 0 {
+			password = string(out)
+		}
+		return deriveKey(password, 1), nil
+	}
+}
+
+// GetProfileKey returns the key for the profile at profilePath; see
+// ProfileForPath and GetBrowserKey.
+func GetProfileKey(profilePath string) ([]byte, error) {
+	return GetBrowserKey(ProfileForPath(profilePath))
+}
+
+// localStateKey reads the AES-256 key stored in Local State, which Windows
+// protects with DPAPI.
+func localStateKey(fsys fs.FS, userDataDir string) ([]byte, error) {
+	data, err := fs.ReadFile(fsys, path.Join(userDataDir, "Local State"))
+	if err != nil {
+		return nil, err
+	}
+
+	var f struct {
+		OSCrypt struct {
+			EncryptedKey string `json:"encrypted_key"`
+		} `json:"os_crypt"`
+	}
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("failed to parse Local State: %w", err)
+	}
+	if f.OSCrypt.EncryptedKey == "" {
+		return nil, fmt.Errorf("no os_crypt.encrypted_key in Local State")
+	}
+
+	enc, err := base64.StdEncoding.DecodeString(f.OSCrypt.EncryptedKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode os_crypt.encrypted_key: %w", err)
+	}
+	if !bytes.HasPrefix(enc, []byte("DPAPI")) {
+		return nil, fmt.Errorf("os_crypt.encrypted_key isn't DPAPI-protected")
+	}
+
+	key, err := dpapiDecrypt(enc[len("DPAPI"):])
+	if err != nil {
+		return nil, err
+	}
+	if len(key) != 32 {
+		return nil, fmt.Errorf("unexpected Local State key length %d", len(key))
+	}
+	return key, nil
+}
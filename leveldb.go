@@ -0,0 +1,197 @@
+package chromedb
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/comparer"
+	"github.com/syndtr/goleveldb/leveldb/journal"
+	"github.com/syndtr/goleveldb/leveldb/opt"
+	"github.com/syndtr/goleveldb/leveldb/storage"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Name of LevelDB's default comparator, which goleveldb also uses.
+const bytewiseComparerName = "leveldb.BytewiseComparator"
+
+var (
+	comparersMu sync.RWMutex
+	comparers   = map[string]comparer.Comparer{
+		idbComparer{}.Name(): idbComparer{},
+	}
+)
+
+// RegisterComparer makes a comparator available to every LevelDB opened by
+// this package. Stores whose MANIFEST names the comparator are opened with
+// it instead of being rejected by goleveldb.
+func RegisterComparer(c comparer.Comparer) {
+	comparersMu.Lock()
+	defer comparersMu.Unlock()
+	comparers[c.Name()] = c
+}
+
+// passthroughComparer orders keys bytewise under a foreign comparator's
+// name. It lets goleveldb open a store whose comparator we don't implement;
+// reads are complete, but iteration order and point lookups may not match
+// the original ordering.
+type passthroughComparer struct {
+	name string
+}
+
+func (c passthroughComparer) Name() string { return c.name }
+
+func (passthroughComparer) Compare(a, b []byte) int {
+	return bytes.Compare(a, b)
+}
+
+func (passthroughComparer) Separator(dst, a, b []byte) []byte { return nil }
+func (passthroughComparer) Successor(dst, b []byte) []byte    { return nil }
+
+// comparerFor returns the comparator to open a store with, or nil for the
+// default bytewise comparator.
+func comparerFor(name string) comparer.Comparer {
+	if name == "" || name == bytewiseComparerName {
+		return nil
+	}
+
+	comparersMu.RLock()
+	defer comparersMu.RUnlock()
+	if c, ok := comparers[name]; ok {
+		return c
+	}
+	return passthroughComparer{name: name}
+}
+
+// manifestComparer returns the comparator name recorded in the MANIFEST
+// that the store's CURRENT file points to.
+func manifestComparer(fsys fs.FS, dir string) (string, error) {
+	current, err := fs.ReadFile(fsys, path.Join(dir, "CURRENT"))
+	if err != nil {
+		return "", err
+	}
+	manifest := strings.TrimSpace(string(current))
+	if !strings.HasPrefix(manifest, "MANIFEST-") || strings.ContainsAny(manifest, `/\`) {
+		return "", fmt.Errorf("invalid CURRENT file: %q", manifest)
+	}
+
+	f, err := fsys.Open(path.Join(dir, manifest))
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	jr := journal.NewReader(bufio.NewReader(f), nil, false, true)
+	for {
+		r, err := jr.Next()
+		if err == io.EOF {
+			return "", nil
+		}
+		if err != nil {
+			return "", err
+		}
+		rec, err := io.ReadAll(r)
+		if err != nil {
+			continue
+		}
+
+		// LevelDB writes the comparator as the first field of a version
+		// edit: tag 1 followed by a length-prefixed name.
+		tag, n := protowire.ConsumeVarint(rec)
+		if n < 0 || tag != 1 {
+			continue
+		}
+		name, m := protowire.ConsumeBytes(rec[n:])
+		if m < 0 {
+			continue
+		}
+		return string(name), nil
+	}
+}
+
+// levelDB is an open store together with the storage serving it, which is
+// released when the store is closed.
+type levelDB struct {
+	*leveldb.DB
+	stor storage.Storage
+}
+
+func (db *levelDB) Close() error {
+	err := db.DB.Close()
+	db.stor.Close()
+	return err
+}
+
+// openLevelDB opens a Chromium LevelDB store in fsys read-only, using
+// whichever comparator its MANIFEST asks for. Files are served straight from
+// fsys, ignoring the browser's LOCK; if that fails, for example because the
+// browser compacted the store while we were opening it, a temporary snapshot
+// of the directory is read instead.
+func openLevelDB(fsys fs.FS, dir string) (*levelDB, error) {
+	name, err := manifestComparer(fsys, dir)
+	if err != nil {
+		name = ""
+	}
+	o := &opt.Options{
+		Comparer: comparerFor(name),
+		ReadOnly: true,
+	}
+
+	stor := newReadOnlyStorage(fsys, dir)
+	db, err := leveldb.Open(stor, o)
+	if err != nil {
+		stor, err = newSnapshotStorage(fsys, dir)
+		if err != nil {
+			return nil, fmt.Errorf("failed to snapshot LevelDB: %w", err)
+		}
+		db, err = leveldb.Open(stor, o)
+		if err != nil {
+			stor.Close()
+			return nil, fmt.Errorf("failed to open LevelDB: %w", err)
+		}
+	}
+
+	return &levelDB{DB: db, stor: stor}, nil
+}
+
+// ErrProfileLocked is returned when asked to modify a store that a running
+// browser has open.
+var ErrProfileLocked = errors.New("profile is in use by a running browser")
+
+// openLevelDBForWrite opens an existing store for modification. It refuses
+// while the browser holds the store, since writing underneath it would
+// corrupt the profile, and for comparators we can only read with.
+func openLevelDBForWrite(dir string) (*leveldb.DB, error) {
+	locked, err := levelDBLocked(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check LevelDB lock: %w", err)
+	}
+	if locked {
+		return nil, ErrProfileLocked
+	}
+
+	name, err := manifestComparer(osFS(dir))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read MANIFEST: %w", err)
+	}
+	cmp := comparerFor(name)
+	if _, ok := cmp.(passthroughComparer); ok {
+		return nil, fmt.Errorf("can't write to LevelDB with unsupported comparator %q", name)
+	}
+
+	db, err := leveldb.OpenFile(dir, &opt.Options{
+		Comparer:       cmp,
+		ErrorIfMissing: true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open LevelDB for writing: %w", err)
+	}
+	return db, nil
+}
@@ -0,0 +1,217 @@
+package chromedb
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/syndtr/goleveldb/leveldb/storage"
+)
+
+var errReadOnlyStorage = errors.New("LevelDB storage is read-only")
+
+var (
+	levelDBFileName     = regexp.MustCompile(`^(\d+)\.(log|ldb|sst|tmp)$`)
+	levelDBManifestName = regexp.MustCompile(`^MANIFEST-(\d+)$`)
+)
+
+// readOnlyStorage is a storage.Storage that serves a LevelDB directory
+// straight from its file system without taking its LOCK, so a store can be
+// read while the browser has it open. Every method that would modify the
+// store fails.
+type readOnlyStorage struct {
+	fsys fs.FS
+	dir  string
+
+	// tmp is set when the store is served from a temporary copy that Close
+	// removes.
+	tmp string
+
+	mu     sync.Mutex
+	closed bool
+}
+
+type noopLocker struct{}
+
+func (noopLocker) Unlock() {}
+
+func newReadOnlyStorage(fsys fs.FS, dir string) *readOnlyStorage {
+	return &readOnlyStorage{fsys: fsys, dir: dir}
+}
+
+// newSnapshotStorage copies the store's files to a temporary directory and
+// serves them from there. This keeps a consistent view when the browser is
+// actively compacting the store underneath us.
+func newSnapshotStorage(fsys fs.FS, dir string) (*readOnlyStorage, error) {
+	tmp, err := os.MkdirTemp("", "chromedb-leveldb-")
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		os.RemoveAll(tmp)
+		return nil, err
+	}
+	for _, e := range entries {
+		name := e.Name()
+		if !e.Type().IsRegular() || name == "LOCK" || strings.HasPrefix(name, "LOG") {
+			continue
+		}
+		if err := copyFileFS(fsys, path.Join(dir, name), filepath.Join(tmp, name)); err != nil {
+			os.RemoveAll(tmp)
+			return nil, err
+		}
+	}
+
+	return &readOnlyStorage{fsys: os.DirFS(tmp), dir: ".", tmp: tmp}, nil
+}
+
+// parseLevelDBFileName maps a file name to its descriptor. Anything that
+// isn't a journal, table, temp file or manifest, such as LOCK, LOG, LOG.old
+// or CURRENT, is not part of the store's data.
+func parseLevelDBFileName(name string) (storage.FileDesc, bool) {
+	if m := levelDBManifestName.FindStringSubmatch(name); m != nil {
+		num, err := strconv.ParseInt(m[1], 10, 64)
+		return storage.FileDesc{Type: storage.TypeManifest, Num: num}, err == nil
+	}
+
+	m := levelDBFileName.FindStringSubmatch(name)
+	if m == nil {
+		return storage.FileDesc{}, false
+	}
+	num, err := strconv.ParseInt(m[1], 10, 64)
+	if err != nil {
+		return storage.FileDesc{}, false
+	}
+	switch m[2] {
+	case "log":
+		return storage.FileDesc{Type: storage.TypeJournal, Num: num}, true
+	case "ldb", "sst":
+		return storage.FileDesc{Type: storage.TypeTable, Num: num}, true
+	case "tmp":
+		return storage.FileDesc{Type: storage.TypeTemp, Num: num}, true
+	}
+	return storage.FileDesc{}, false
+}
+
+func (s *readOnlyStorage) checkClosed() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return storage.ErrClosed
+	}
+	return nil
+}
+
+func (s *readOnlyStorage) Lock() (storage.Locker, error) {
+	if err := s.checkClosed(); err != nil {
+		return nil, err
+	}
+	return noopLocker{}, nil
+}
+
+func (s *readOnlyStorage) Log(str string) {}
+
+// GetMeta returns the manifest named by CURRENT, falling back to the newest
+// manifest on disk if CURRENT is missing or damaged.
+func (s *readOnlyStorage) GetMeta() (storage.FileDesc, error) {
+	if err := s.checkClosed(); err != nil {
+		return storage.FileDesc{}, err
+	}
+
+	current, err := fs.ReadFile(s.fsys, path.Join(s.dir, "CURRENT"))
+	if err == nil {
+		fd, ok := parseLevelDBFileName(strings.TrimSpace(string(current)))
+		if ok && fd.Type == storage.TypeManifest {
+			if _, err := fs.Stat(s.fsys, path.Join(s.dir, fd.String())); err == nil {
+				return fd, nil
+			}
+		}
+	}
+
+	manifests, err := s.List(storage.TypeManifest)
+	if err != nil {
+		return storage.FileDesc{}, err
+	}
+	if len(manifests) == 0 {
+		return storage.FileDesc{}, fs.ErrNotExist
+	}
+	newest := manifests[0]
+	for _, fd := range manifests[1:] {
+		if fd.Num > newest.Num {
+			newest = fd
+		}
+	}
+	return newest, nil
+}
+
+func (s *readOnlyStorage) List(ft storage.FileType) ([]storage.FileDesc, error) {
+	if err := s.checkClosed(); err != nil {
+		return nil, err
+	}
+
+	entries, err := fs.ReadDir(s.fsys, s.dir)
+	if err != nil {
+		return nil, err
+	}
+	var fds []storage.FileDesc
+	for _, e := range entries {
+		if fd, ok := parseLevelDBFileName(e.Name()); ok && fd.Type&ft != 0 {
+			fds = append(fds, fd)
+		}
+	}
+	return fds, nil
+}
+
+func (s *readOnlyStorage) Open(fd storage.FileDesc) (storage.Reader, error) {
+	if err := s.checkClosed(); err != nil {
+		return nil, err
+	}
+
+	f, err := openReader(s.fsys, path.Join(s.dir, fd.String()))
+	if errors.Is(err, fs.ErrNotExist) && fd.Type == storage.TypeTable {
+		// Older stores use the .sst extension.
+		f, err = openReader(s.fsys, path.Join(s.dir, fmt.Sprintf("%06d.sst", fd.Num)))
+	}
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (s *readOnlyStorage) SetMeta(fd storage.FileDesc) error {
+	return errReadOnlyStorage
+}
+
+func (s *readOnlyStorage) Create(fd storage.FileDesc) (storage.Writer, error) {
+	return nil, errReadOnlyStorage
+}
+
+func (s *readOnlyStorage) Remove(fd storage.FileDesc) error {
+	return errReadOnlyStorage
+}
+
+func (s *readOnlyStorage) Rename(oldfd, newfd storage.FileDesc) error {
+	return errReadOnlyStorage
+}
+
+func (s *readOnlyStorage) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	if s.tmp != "" {
+		return os.RemoveAll(s.tmp)
+	}
+	return nil
+}
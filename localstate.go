@@ -0,0 +1,98 @@
+package chromedb
+
+import (
+	"encoding/json"
+	"io/fs"
+	"sort"
+	"strings"
+	"time"
+)
+
+type ProfileInfo struct {
+	Dir                string    `json:"dir"`
+	Name               string    `json:"name"`
+	UserName           string    `json:"user_name"`
+	GaiaName           string    `json:"gaia_name"`
+	GaiaID             string    `json:"gaia_id"`
+	HostedDomain       string    `json:"hosted_domain"`
+	AvatarIcon         string    `json:"avatar_icon"`
+	IsUsingDefaultName bool      `json:"is_using_default_name"`
+	ActiveTime         time.Time `json:"active_time"`
+}
+
+type LocalState struct {
+	BrowserVersion     string        `json:"browser_version"`
+	LastUsedProfile    string        `json:"last_used_profile"`
+	LastActiveProfiles []string      `json:"last_active_profiles"`
+	Profiles           []ProfileInfo `json:"profiles"`
+}
+
+type localStateProfile struct {
+	Name               string  `json:"name"`
+	UserName           string  `json:"user_name"`
+	GaiaName           string  `json:"gaia_name"`
+	GaiaID             string  `json:"gaia_id"`
+	HostedDomain       string  `json:"hosted_domain"`
+	AvatarIcon         string  `json:"avatar_icon"`
+	IsUsingDefaultName bool    `json:"is_using_default_name"`
+	ActiveTime         float64 `json:"active_time"`
+}
+
+type localStateFile struct {
+	Profile struct {
+		InfoCache          map[string]localStateProfile `json:"info_cache"`
+		LastUsed           string                       `json:"last_used"`
+		LastActiveProfiles []string                     `json:"last_active_profiles"`
+	} `json:"profile"`
+	UserExperienceMetrics struct {
+		Stability struct {
+			StatsVersion string `json:"stats_version"`
+		} `json:"stability"`
+	} `json:"user_experience_metrics"`
+}
+
+// GetLocalState reads the browser version and the profiles known to the
+// browser from the Local State file in the user data directory, the parent
+// of the profile directories.
+func GetLocalState(localStatePath string) (*LocalState, error) {
+	return GetLocalStateFS(osFS(localStatePath))
+}
+
+// GetLocalStateFS is GetLocalState for a Local State file in fsys.
+func GetLocalStateFS(fsys fs.FS, name string) (*LocalState, error) {
+	data, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return nil, err
+	}
+
+	var f localStateFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, err
+	}
+
+	// The stats version carries an architecture suffix, e.g. "120.0.6099.109-64".
+	version, _, _ := strings.Cut(f.UserExperienceMetrics.Stability.StatsVersion, "-")
+
+	ls := &LocalState{
+		BrowserVersion:     version,
+		LastUsedProfile:    f.Profile.LastUsed,
+		LastActiveProfiles: f.Profile.LastActiveProfiles,
+	}
+	for dir, p := range f.Profile.InfoCache {
+		info := ProfileInfo{
+			Dir:                dir,
+			Name:               p.Name,
+			UserName:           p.UserName,
+			GaiaName:           p.GaiaName,
+			GaiaID:             p.GaiaID,
+			HostedDomain:       p.HostedDomain,
+			AvatarIcon:         p.AvatarIcon,
+			IsUsingDefaultName: p.IsUsingDefaultName,
+			ActiveTime:         unixTimeFloat(p.ActiveTime),
+		}
+		ls.Profiles = append(ls.Profiles, info)
+	}
+	sort.Slice(ls.Profiles, func(i, j int) bool { return ls.Profiles[i].Dir < ls.Profiles[j].Dir })
+
+	return ls, nil
+}
@@ -2,22 +2,13 @@ package chromedb
 
 import (
 	"bytes"
-	"encoding/base64"
 	"encoding/json"
 	"fmt"
-	"io"
-	"net/http"
-	"os"
-	"path/filepath"
-	"regexp"
-	"strconv"
-	"strings"
+	"io/fs"
 	"time"
 
-	"github.com/h2non/filetype"
 	"github.com/syndtr/goleveldb/leveldb"
-	"github.com/syndtr/goleveldb/leveldb/opt"
-	"github.com/syndtr/goleveldb/leveldb/storage"
+	"github.com/syndtr/goleveldb/leveldb/util"
 	"golang.org/x/text/encoding/unicode"
 	"google.golang.org/protobuf/encoding/protowire"
 )
@@ -29,6 +20,11 @@ func fromChromeTimestamp(microseconds int64) (time.Time, error) {
 	return timestamp, nil
 }
 
+func toChromeTimestamp(t time.Time) int64 {
+	chromiumEpoch := time.Date(1601, 1, 1, 0, 0, 0, 0, time.UTC).UnixMicro()
+	return t.UnixMicro() - chromiumEpoch
+}
+
 func decodeString(raw []byte) (string, string, error) {
 	prefix := raw[0]
 	if prefix == 0 {
@@ -39,17 +35,41 @@ func decodeString(raw []byte) (string, string, error) {
 		}
 		return string(utf8bytes), "UTF-16-LE", nil
 	} else if prefix == 1 {
-		return string(raw[1:]), "ISO-8859-1", nil
+		// Each byte is a code point; copying the bytes as they are would
+		// produce invalid UTF-8 for anything past ASCII.
+		runes := make([]rune, len(raw)-1)
+		for i, c := range raw[1:] {
+			runes[i] = rune(c)
+		}
+		return string(runes), "ISO-8859-1", nil
 	}
 	return "", "", fmt.Errorf("unknown string encoding prefix: %d", prefix)
 }
 
+// encodeString is the inverse of decodeString. Strings that fit in Latin-1
+// are stored with the 0x01 prefix, anything else as UTF-16-LE with 0x00.
+func encodeString(s string) []byte {
+	latin1 := []byte{1}
+	for _, r := range s {
+		if r > 0xff {
+			return append([]byte{0}, encodeUTF16LE(s)...)
+		}
+		latin1 = append(latin1, byte(r))
+	}
+	return latin1
+}
+
 type StorageMetadata struct {
 	StorageKey string    `json:"storage_key"`
 	Timestamp  time.Time `json:"timestamp"`
 	Size       int       `json:"size"`
 }
 
+const (
+	localStorageMetaKeyPrefix   = "META:"
+	localStorageRecordKeyPrefix = "_"
+)
+
 type LocalStorageRecord struct {
 	StorageKey  string          `json:"storage_key"`
 	ScriptKey   string          `json:"script_key"`
@@ -59,12 +79,17 @@ type LocalStorageRecord struct {
 	Conversions []string        `json:"conversions"`
 	JsonType    string          `json:"-"`
 	Value       json.RawMessage `json:"value"`
+	Seq         uint64          `json:"seq,omitempty"`
+	State       string          `json:"state,omitempty"`
+	DecodeError string          `json:"decode_error,omitempty"`
+	RawKey      []byte          `json:"raw_key,omitempty"`
+	RawValue    []byte          `json:"raw_value,omitempty"`
 }
 
 type LocalStoreDb struct {
 	ldb      *leveldb.DB
 	Records  []LocalStorageRecord `json:"records"`
-	metadata []StorageMetadata    `json:"metadata"`
+	metadata []StorageMetadata
 }
 
 func StorageMetadataFromProtobuff(sm *StorageMetadata, data []byte) error {
@@ -78,11 +103,13 @@ func StorageMetadataFromProtobuff(sm *StorageMetadata, data []byte) error {
 		return fmt.Errorf("Failed to decode timestamp")
 	}
 
-	fieldNum, wireType, n = protowire.ConsumeTag(data[n+m:])
+	data = data[n+m:]
+
+	fieldNum, wireType, n = protowire.ConsumeTag(data)
 	if fieldNum != 2 || wireType != protowire.VarintType {
 		return fmt.Errorf("Expected field number 2 with varint type, got field number %d with wire type %d", fieldNum, wireType)
 	}
-	size, m := protowire.ConsumeVarint(data[n+m:])
+	size, m := protowire.ConsumeVarint(data[n:])
 	if m < 0 {
 		return fmt.Errorf("Failed to decode size")
 	}
@@ -98,123 +125,30 @@ func StorageMetadataFromProtobuff(sm *StorageMetadata, data []byte) error {
 	return nil
 }
 
-func LoadLocalStorage(dir string) (*LocalStoreDb, error) {
-	db := &leveldb.DB{}
-	db, err := leveldb.OpenFile(dir, &opt.Options{
-		ReadOnly: true,
-	})
-
-	// We try the ReadOnly option above, but it weirdly doesn't work when the
-	// db is locked. When this happens, we simply copy the db to memory and
-	// read from there.
-	if err != nil {
-
-		srcDir := dir
-
-		memStorage := storage.NewMemStorage()
-
-		// Copy the LevelDB directory contents into the memory storage
-		err := filepath.Walk(srcDir, func(path string, info os.FileInfo, err error) error {
-
-			if err != nil {
-				return err
-			}
-
-			relPath, err := filepath.Rel(srcDir, path)
-			if err != nil {
-				return err
-			}
-
-			// Skip directories, we only need files
-			if info.IsDir() {
-				return nil
-			}
-
-			srcFile, err := os.Open(path)
-			if err != nil {
-				return err
-			}
-			defer srcFile.Close()
-
-			data, err := io.ReadAll(srcFile)
-			if err != nil {
-				return err
-			}
-
-			var num int64
-			num = 0
-			re := regexp.MustCompile(`\d+`)
-			match := re.FindString(relPath)
-			if match != "" {
-
-				matchInt, err := strconv.Atoi(match)
-				if err == nil {
-					num = int64(matchInt)
-				}
-			}
-
-			// Determine the file descriptor type
-			var fileType storage.FileType
-			switch {
-			case strings.HasSuffix(relPath, ".ldb"):
-				fileType = storage.TypeTable
-			case strings.HasPrefix(relPath, "MANIFEST"):
-				fileType = storage.TypeManifest
-			case strings.HasSuffix(relPath, ".log"):
-				fileType = storage.TypeJournal
-			case strings.HasSuffix(relPath, ".tmp"):
-				fileType = storage.TypeTemp
-			default:
-				return nil
-			}
-
-			// Create the file in the memory storage
-			fd := storage.FileDesc{Type: fileType, Num: num}
-			if fd.Type == storage.TypeManifest {
-				err = memStorage.SetMeta(fd)
-				if err != nil {
-					return err
-				}
-			}
-			writer, err := memStorage.Create(fd)
-			if err != nil {
-				return err
-			}
-
-			// Write the contents to the memory storage
-			_, err = writer.Write(data)
-			if err != nil {
-				writer.Close()
-				return err
-			}
-
-			// Close the writer
-			err = writer.Close()
-			if err != nil {
-				return err
-			}
-
-			return nil
-
-		})
-
-		if err != nil {
-			fmt.Println("Error copying directory:", err)
-			return nil, err
-		}
+func StorageMetadataToProtobuff(sm StorageMetadata) []byte {
+	var data []byte
+	data = protowire.AppendTag(data, 1, protowire.VarintType)
+	data = protowire.AppendVarint(data, uint64(toChromeTimestamp(sm.Timestamp)))
+	data = protowire.AppendTag(data, 2, protowire.VarintType)
+	data = protowire.AppendVarint(data, uint64(sm.Size))
+	return data
+}
 
-		// Open the LevelDB using the memory storage
-		db, err = leveldb.Open(memStorage, nil)
-		if err != nil {
-			fmt.Println("Error opening LevelDB:", err)
-			return nil, err
-		}
+func LoadLocalStorage(dir string) (*LocalStoreDb, error) {
+	return LoadLocalStorageFS(osFS(dir))
+}
 
+// LoadLocalStorageFS is LoadLocalStorage for a Local Storage/leveldb
+// directory in fsys.
+func LoadLocalStorageFS(fsys fs.FS, dir string) (*LocalStoreDb, error) {
+	db, err := openLevelDB(fsys, dir)
+	if err != nil {
+		return nil, err
 	}
 	defer db.Close()
 
 	lsd := &LocalStoreDb{
-		ldb: db,
+		ldb: db.DB,
 	}
 
 	iter := db.NewIterator(nil, nil)
@@ -224,14 +158,9 @@ func LoadLocalStorage(dir string) (*LocalStoreDb, error) {
 		key := iter.Key()
 		value := iter.Value()
 
-		const (
-			MetaKeyPrefix   = "META:"
-			RecordKeyPrefix = "_"
-		)
-
 		// metadata
-		if bytes.HasPrefix(key, []byte(MetaKeyPrefix)) {
-			storageKey := string(bytes.TrimPrefix(key, []byte(MetaKeyPrefix)))
+		if bytes.HasPrefix(key, []byte(localStorageMetaKeyPrefix)) {
+			storageKey := string(bytes.TrimPrefix(key, []byte(localStorageMetaKeyPrefix)))
 
 			metadata := StorageMetadata{
 				StorageKey: storageKey,
@@ -246,118 +175,179 @@ func LoadLocalStorage(dir string) (*LocalStoreDb, error) {
 			lsd.metadata = append(lsd.metadata, metadata)
 
 			// record
-		} else if bytes.HasPrefix(key, []byte(RecordKeyPrefix)) {
-			parts := bytes.SplitN(bytes.TrimPrefix(key, []byte(RecordKeyPrefix)), []byte{0}, 2)
-			if len(parts) != 2 {
-				continue
-			}
-
-			record := LocalStorageRecord{}
-
-			storageKey := string(parts[0])
-			record.StorageKey = storageKey
-			sk, _, err := decodeString(parts[1])
+		} else {
+			record, ok, err := localStorageRecordFromEntry(key, value)
 			if err != nil {
-				return nil, fmt.Errorf("failed to decode script key: %w", err)
+				return nil, err
 			}
-			record.ScriptKey = sk
-
-			val, valEnc, err := decodeString(value)
-			if err != nil {
-				return nil, fmt.Errorf("failed to decode value: %w", err)
+			if ok {
+				lsd.Records = append(lsd.Records, record)
 			}
-			record.Decoded = val
-			record.Charset = valEnc
-
-			lsd.Records = append(lsd.Records, record)
 		}
 	}
 
 	return lsd, nil
 }
 
-func LocalStorageRecordToJson(r LocalStorageRecord) (string, error) {
+// localStorageRecordFromEntry decodes a "_<storage key>\x00<script key>"
+// entry. It returns false for keys that aren't records. A nil value, as for
+// a deleted entry, leaves the value and charset empty.
+func localStorageRecordFromEntry(key, value []byte) (LocalStorageRecord, bool, error) {
+	record := LocalStorageRecord{}
 
-	mime := "application/octet-stream"
-	xfer := []string{}
-	b := []byte(r.Decoded)
-	validJson := json.Valid(b)
-	jsonType := ""
-	out := []byte{}
-
-	if validJson {
-		// Use a custom decoder to handle large numbers as strings
-		d := json.NewDecoder(bytes.NewReader(b))
-		d.UseNumber() // This makes the decoder use json.Number for numbers
-		var v interface{}
-		err := d.Decode(&v)
+	if !bytes.HasPrefix(key, []byte(localStorageRecordKeyPrefix)) {
+		return record, false, nil
+	}
+	parts := bytes.SplitN(bytes.TrimPrefix(key, []byte(localStorageRecordKeyPrefix)), []byte{0}, 2)
+	if len(parts) != 2 || len(parts[1]) == 0 {
+		return record, false, nil
+	}
+
+	record.StorageKey = string(parts[0])
+	sk, _, err := decodeString(parts[1])
+	if err != nil {
+		return record, false, fmt.Errorf("failed to decode script key: %w", err)
+	}
+	record.ScriptKey = sk
+
+	if len(value) == 0 {
+		return record, true, nil
+	}
+	val, valEnc, err := decodeString(value)
+	if err != nil {
+		return record, false, fmt.Errorf("failed to decode value: %w", err)
+	}
+	record.Decoded = val
+	record.Charset = valEnc
+
+	return record, true, nil
+}
+
+// LoadLocalStorageRaw reads local storage from the raw journal and table
+// files, returning every version of every record with its sequence number
+// and state (live, deleted or overwritten). It recovers values the site has
+// since cleared and works even when the MANIFEST is unreadable. Records that
+// can't be decoded are returned with their raw key and value and a
+// DecodeError.
+func LoadLocalStorageRaw(dir string) (*LocalStoreDb, error) {
+	return LoadLocalStorageRawFS(osFS(dir))
+}
+
+// LoadLocalStorageRawFS is LoadLocalStorageRaw for a Local Storage/leveldb
+// directory in fsys.
+func LoadLocalStorageRawFS(fsys fs.FS, dir string) (*LocalStoreDb, error) {
+	raw, err := ReadRawLevelDBFS(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	lsd := &LocalStoreDb{}
+	for _, r := range raw {
+		// Partially written or damaged records are kept with their raw
+		// bytes rather than failing the whole recovery; only metadata
+		// entries are left out.
+		record, ok, err := localStorageRecordFromEntry(r.Key, r.Value)
+		if err == nil && !ok {
+			if !bytes.HasPrefix(r.Key, []byte(localStorageRecordKeyPrefix)) {
+				continue
+			}
+			err = fmt.Errorf("malformed record key")
+		}
 		if err != nil {
-			return "", fmt.Errorf("failed to unmarshal supposedly valid JSON: %w", err)
+			record.DecodeError = err.Error()
+			record.RawKey, record.RawValue = r.Key, r.Value
 		}
+		record.Seq = r.Seq
+		record.State = r.State
+		lsd.Records = append(lsd.Records, record)
+	}
 
-		mime = "application/json"
+	return lsd, nil
+}
 
-		// Determine the type of the JSON value
-		switch val := v.(type) {
-		case json.Number:
-			jsonType = "number"
-			// Check if the number might be too large for float64
-			_, err := val.Float64()
-			if err != nil {
-				// If it can't be converted to float64, treat it as a string in the output
-				strVal := fmt.Sprintf("\"%s\"", val.String())
-				out = []byte(strVal)
-			} else {
-				out = b
-			}
-		case string:
-			jsonType = "string"
-			out = b
-		case bool:
-			jsonType = "boolean"
-			out = b
-		case []interface{}:
-			jsonType = "array"
-			out = b
-		case map[string]interface{}:
-			jsonType = "object"
-			out = b
-		case nil:
-			jsonType = "null"
-			out = b
-		default:
-			jsonType = ""
-			out = b
+func localStorageRecordKey(storageKey, scriptKey string) []byte {
+	key := []byte(localStorageRecordKeyPrefix + storageKey + "\x00")
+	return append(key, encodeString(scriptKey)...)
+}
+
+// updateLocalStorage applies a change to one origin's records along with the
+// matching META: entry, whose size is the total length of the encoded script
+// keys and values, as Chromium counts it.
+func updateLocalStorage(dir, storageKey, scriptKey string, value *string) error {
+	db, err := openLevelDBForWrite(dir)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	key := localStorageRecordKey(storageKey, scriptKey)
+	prefix := []byte(localStorageRecordKeyPrefix + storageKey + "\x00")
+
+	size := 0
+	found := false
+	iter := db.NewIterator(util.BytesPrefix(prefix), nil)
+	for iter.Next() {
+		if bytes.Equal(iter.Key(), key) {
+			found = true
+			continue
 		}
+		size += len(iter.Key()) - len(prefix) + len(iter.Value())
+	}
+	iter.Release()
+	if err := iter.Error(); err != nil {
+		return err
+	}
+
+	batch := new(leveldb.Batch)
+	if value != nil {
+		encoded := encodeString(*value)
+		batch.Put(key, encoded)
+		size += len(key) - len(prefix) + len(encoded)
 	} else {
-		quoted := strconv.Quote(r.Decoded)
-		if json.Valid([]byte(quoted)) {
+		if !found {
+			return fmt.Errorf("no local storage entry %q for %s", scriptKey, storageKey)
+		}
+		batch.Delete(key)
+	}
 
-			out = []byte(quoted)
-			mime = "text/plain"
-			xfer = append(xfer, "strconv.Quote")
-			mime = http.DetectContentType(b)
-			mime = strings.Split(mime, ";")[0]
+	metaKey := []byte(localStorageMetaKeyPrefix + storageKey)
+	if size == 0 {
+		batch.Delete(metaKey)
+	} else {
+		batch.Put(metaKey, StorageMetadataToProtobuff(StorageMetadata{
+			StorageKey: storageKey,
+			Timestamp:  time.Now(),
+			Size:       size,
+		}))
+	}
 
-		} else {
+	return db.Write(batch, nil)
+}
+
+// SetLocalStorage sets a local storage value for an origin (storage key) in
+// the store at dir. It refuses to run while the browser has the store open.
+func SetLocalStorage(dir, storageKey, scriptKey, value string) error {
+	return updateLocalStorage(dir, storageKey, scriptKey, &value)
+}
 
-			b64 := base64.StdEncoding.EncodeToString(b)
-			xfer = append(xfer, "base64.StdEncoding.EncodeToString")
-			out = []byte(strconv.Quote(b64))
-			xfer = append(xfer, "strconv.Quote")
+// DeleteLocalStorage removes a local storage value for an origin (storage
+// key) in the store at dir. It refuses to run while the browser has the
+// store open.
+func DeleteLocalStorage(dir, storageKey, scriptKey string) error {
+	return updateLocalStorage(dir, storageKey, scriptKey, nil)
+}
 
-			magic, _ := filetype.Match(b)
-			if magic != filetype.Unknown {
-				mime = magic.MIME.Value
-			}
+func LocalStorageRecordToJson(r LocalStorageRecord) (string, error) {
 
-		}
+	v, err := classifyValue(r.Decoded)
+	if err != nil {
+		return "", err
 	}
 
-	r.MIME = mime
-	r.Conversions = xfer
-	r.Value = out
-	r.JsonType = jsonType
+	r.MIME = v.MIME
+	r.Conversions = v.Conversions
+	r.Value = v.Value
+	r.JsonType = v.JsonType
 	recordJson, err := json.Marshal(r)
 	if err != nil {
 		return "", fmt.Errorf("failed to marshal record to JSON: %w", err)
@@ -367,5 +357,7 @@ func LocalStorageRecordToJson(r LocalStorageRecord) (string, error) {
 }
 
 func (lsd *LocalStoreDb) Close() {
-	lsd.ldb.Close()
+	if lsd.ldb != nil {
+		lsd.ldb.Close()
+	}
 }
@@ -0,0 +1,113 @@
+package chromedb
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/syndtr/goleveldb/leveldb"
+)
+
+func TestEncodeStringRoundTrip(t *testing.T) {
+	tests := []struct {
+		s           string
+		wantPrefix  byte
+		wantCharset string
+	}{
+		{"", 1, "ISO-8859-1"},
+		{"hello", 1, "ISO-8859-1"},
+		{"héllo", 1, "ISO-8859-1"},
+		{"ÿ\u0080", 1, "ISO-8859-1"},
+		{"héllo 世界", 0, "UTF-16-LE"},
+		{"a😀b", 0, "UTF-16-LE"},
+	}
+	for _, tt := range tests {
+		raw := encodeString(tt.s)
+		if raw[0] != tt.wantPrefix {
+			t.Errorf("encodeString(%q) prefix = %d, want %d", tt.s, raw[0], tt.wantPrefix)
+		}
+		got, charset, err := decodeString(raw)
+		if err != nil {
+			t.Errorf("decodeString(encodeString(%q)): %v", tt.s, err)
+			continue
+		}
+		if got != tt.s || charset != tt.wantCharset {
+			t.Errorf("decodeString(encodeString(%q)) = %q, %s; want %q, %s", tt.s, got, charset, tt.s, tt.wantCharset)
+		}
+	}
+}
+
+func TestDecodeStringLatin1(t *testing.T) {
+	// Latin-1 bytes past ASCII are code points, not UTF-8.
+	got, _, err := decodeString([]byte{1, 'h', 0xE9, 'l', 'l', 'o'})
+	if err != nil || got != "héllo" {
+		t.Errorf("decodeString = %q, %v; want héllo", got, err)
+	}
+	if _, _, err := decodeString([]byte{2, 'x'}); err == nil {
+		t.Error("decodeString with an unknown prefix succeeded, want error")
+	}
+}
+
+// localStorageMeta returns the META: entry for storageKey in the store at
+// dir, or nil if there is none.
+func localStorageMeta(t *testing.T, dir, storageKey string) *StorageMetadata {
+	t.Helper()
+	db, err := leveldb.OpenFile(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	data, err := db.Get([]byte(localStorageMetaKeyPrefix+storageKey), nil)
+	if err == leveldb.ErrNotFound {
+		return nil
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sm StorageMetadata
+	if err := StorageMetadataFromProtobuff(&sm, data); err != nil {
+		t.Fatal(err)
+	}
+	return &sm
+}
+
+func TestSetDeleteLocalStorageMeta(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "leveldb")
+	writeLevelDB(t, dir, false)
+	const origin = "https://example.com"
+	entrySize := func(key, value string) int {
+		return len(encodeString(key)) + len(encodeString(value))
+	}
+
+	steps := []struct {
+		name     string
+		apply    func() error
+		wantSize int // 0 means no META: entry
+	}{
+		{"set", func() error { return SetLocalStorage(dir, origin, "a", "héllo") }, entrySize("a", "héllo")},
+		{"set another", func() error { return SetLocalStorage(dir, origin, "b", "世界") }, entrySize("a", "héllo") + entrySize("b", "世界")},
+		{"overwrite", func() error { return SetLocalStorage(dir, origin, "a", "x") }, entrySize("a", "x") + entrySize("b", "世界")},
+		{"delete", func() error { return DeleteLocalStorage(dir, origin, "b") }, entrySize("a", "x")},
+		{"delete the last", func() error { return DeleteLocalStorage(dir, origin, "a") }, 0},
+	}
+	for _, step := range steps {
+		if err := step.apply(); err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+		sm := localStorageMeta(t, dir, origin)
+		switch {
+		case step.wantSize == 0 && sm != nil:
+			t.Errorf("%s: META: entry left with size %d, want it deleted", step.name, sm.Size)
+		case step.wantSize != 0 && sm == nil:
+			t.Errorf("%s: no META: entry, want size %d", step.name, step.wantSize)
+		case sm != nil && sm.Size != step.wantSize:
+			t.Errorf("%s: META: size = %d, want %d", step.name, sm.Size, step.wantSize)
+		}
+	}
+
+	if err := DeleteLocalStorage(dir, origin, "a"); err == nil {
+		t.Error("deleting a missing entry succeeded, want error")
+	}
+	if sm := localStorageMeta(t, dir, origin); sm != nil {
+		t.Errorf("failed delete wrote a META: entry with size %d", sm.Size)
+	}
+}
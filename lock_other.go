@@ -0,0 +1,15 @@
+//go:build !unix && !windows
+
+package chromedb
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// levelDBLocked reports whether another process holds the store's LOCK file.
+// There's no way to tell here, so rather than risk writing underneath a
+// running browser, writing isn't supported.
+func levelDBLocked(dir string) (bool, error) {
+	return false, fmt.Errorf("can't check for a running browser on %s", runtime.GOOS)
+}
@@ -0,0 +1,29 @@
+//go:build unix
+
+package chromedb
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// levelDBLocked reports whether another process holds the store's LOCK file.
+// Chromium takes an fcntl lock, which goleveldb's flock doesn't see on Linux,
+// so check for it explicitly.
+func levelDBLocked(dir string) (bool, error) {
+	f, err := os.OpenFile(filepath.Join(dir, "LOCK"), os.O_RDWR, 0)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	lk := syscall.Flock_t{Type: syscall.F_WRLCK}
+	if err := syscall.FcntlFlock(f.Fd(), syscall.F_GETLK, &lk); err != nil {
+		return false, err
+	}
+	return lk.Type != syscall.F_UNLCK, nil
+}
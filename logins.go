@@ -0,0 +1,83 @@
+package chromedb
+
+import (
+	"io/fs"
+	"path"
+	"time"
+)
+
+type Login struct {
+	File              string    `json:"file"`
+	OriginURL         string    `json:"origin_url"`
+	ActionURL         string    `json:"action_url"`
+	Username          string    `json:"username"`
+	EncryptedPassword []byte    `json:"encrypted_password"`
+	Password          string    `json:"password"`
+	DateCreated       time.Time `json:"date_created"`
+	DateLastUsed      time.Time `json:"date_last_used"`
+	TimesUsed         int64     `json:"times_used"`
+}
+
+// GetLogins reads saved passwords from a Login Data or Login Data For Account
+// database. Passwords are left encrypted; see DecryptLogin.
+func GetLogins(loginDataPath string) ([]Login, error) {
+	return GetLoginsFS(osFS(loginDataPath))
+}
+
+// GetLoginsFS is GetLogins for a Login Data database in fsys.
+func GetLoginsFS(fsys fs.FS, name string) ([]Login, error) {
+	db, err := openSQLite(fsys, name)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	cols, err := sqliteColumns(db, "logins")
+	if err != nil {
+		return nil, err
+	}
+
+	query := "SELECT " + selectColumns(cols, "",
+		[2]string{"origin_url", "''"},
+		[2]string{"action_url", "''"},
+		[2]string{"username_value", "''"},
+		[2]string{"password_value", "NULL"},
+		[2]string{"date_created", "0"},
+		[2]string{"date_last_used", "0"},
+		[2]string{"times_used", "0"},
+	) + " FROM logins"
+	rows, err := db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var logins []Login
+	for rows.Next() {
+		var (
+			login             Login
+			created, lastUsed int64
+		)
+		err := rows.Scan(&login.OriginURL, &login.ActionURL, &login.Username,
+			&login.EncryptedPassword, &created, &lastUsed, &login.TimesUsed)
+		if err != nil {
+			return nil, err
+		}
+		login.File = path.Base(name)
+		login.DateCreated = chromeTime(created)
+		login.DateLastUsed = chromeTime(lastUsed)
+		logins = append(logins, login)
+	}
+
+	return logins, rows.Err()
+}
+
+// DecryptLogin decrypts a saved password. Unlike cookies, passwords carry no
+// domain digest.
+func DecryptLogin(login Login, key []byte) (string, error) {
+	decrypted, err := Decrypt(login.EncryptedPassword, key)
+	if err != nil {
+		return "", err
+	}
+	return string(decrypted), nil
+}
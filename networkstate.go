@@ -0,0 +1,124 @@
+package chromedb
+
+import (
+	"encoding/json"
+	"io/fs"
+	"sort"
+	"strconv"
+	"time"
+)
+
+type AlternativeService struct {
+	Protocol       string    `json:"protocol"`
+	Host           string    `json:"host,omitempty"`
+	Port           int       `json:"port"`
+	AdvertisedALPN []string  `json:"advertised_alpns,omitempty"`
+	Expiration     time.Time `json:"expiration"`
+}
+
+type NetworkServer struct {
+	Server              string               `json:"server"`
+	SupportsSpdy        bool                 `json:"supports_spdy"`
+	SRTT                int64                `json:"srtt,omitempty"`
+	AlternativeServices []AlternativeService `json:"alternative_services"`
+}
+
+type NetworkState struct {
+	Servers                   []NetworkServer      `json:"servers"`
+	BrokenAlternativeServices []AlternativeService `json:"broken_alternative_services"`
+	QUICAddress               string               `json:"quic_address,omitempty"`
+	NetworkQualities          map[string]string    `json:"network_qualities"`
+}
+
+type networkAlternativeService struct {
+	ProtocolStr     string   `json:"protocol_str"`
+	Host            string   `json:"host"`
+	Port            int      `json:"port"`
+	AdvertisedALPNs []string `json:"advertised_alpns"`
+	Expiration      string   `json:"expiration"`
+	BrokenUntil     string   `json:"broken_until"`
+}
+
+type networkStateFile struct {
+	Net struct {
+		HTTPServerProperties struct {
+			Servers []struct {
+				Server             string                      `json:"server"`
+				SupportsSpdy       bool                        `json:"supports_spdy"`
+				AlternativeService []networkAlternativeService `json:"alternative_service"`
+				NetworkStats       struct {
+					SRTT int64 `json:"srtt"`
+				} `json:"network_stats"`
+			} `json:"servers"`
+			BrokenAlternativeServices []networkAlternativeService `json:"broken_alternative_services"`
+			SupportsQUIC              struct {
+				Address string `json:"address"`
+			} `json:"supports_quic"`
+		} `json:"http_server_properties"`
+		NetworkQualities map[string]string `json:"network_qualities"`
+	} `json:"net"`
+}
+
+func (a networkAlternativeService) alternativeService() AlternativeService {
+	as := AlternativeService{
+		Protocol:       a.ProtocolStr,
+		Host:           a.Host,
+		Port:           a.Port,
+		AdvertisedALPN: a.AdvertisedALPNs,
+		Expiration:     chromeTimeString(a.Expiration),
+	}
+	// Broken services record when they may be retried, in Unix seconds.
+	if a.BrokenUntil != "" {
+		sec, _ := strconv.ParseInt(a.BrokenUntil, 10, 64)
+		as.Expiration = unixTime(sec)
+	}
+	return as
+}
+
+// GetNetworkState reads the servers a profile has connected to, with their
+// HTTP/2 and alternative service (QUIC) support, from its Network Persistent
+// State file.
+func GetNetworkState(networkStatePath string) (*NetworkState, error) {
+	return GetNetworkStateFS(osFS(networkStatePath))
+}
+
+// GetNetworkStateFS is GetNetworkState for a Network Persistent State file
+// in fsys.
+func GetNetworkStateFS(fsys fs.FS, name string) (*NetworkState, error) {
+	data, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return nil, err
+	}
+
+	var f networkStateFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, err
+	}
+
+	props := f.Net.HTTPServerProperties
+	ns := &NetworkState{
+		Servers:                   []NetworkServer{},
+		BrokenAlternativeServices: []AlternativeService{},
+		QUICAddress:               props.SupportsQUIC.Address,
+		NetworkQualities:          f.Net.NetworkQualities,
+	}
+	for _, s := range props.Servers {
+		server := NetworkServer{
+			Server:              s.Server,
+			SupportsSpdy:        s.SupportsSpdy,
+			SRTT:                s.NetworkStats.SRTT,
+			AlternativeServices: []AlternativeService{},
+		}
+		for _, a := range s.AlternativeService {
+			server.AlternativeServices = append(server.AlternativeServices, a.alternativeService())
+		}
+		ns.Servers = append(ns.Servers, server)
+	}
+	sort.SliceStable(ns.Servers, func(i, j int) bool { return ns.Servers[i].Server < ns.Servers[j].Server })
+
+	for _, a := range props.BrokenAlternativeServices {
+		ns.BrokenAlternativeServices = append(ns.BrokenAlternativeServices, a.alternativeService())
+	}
+
+	return ns, nil
+}
@@ -0,0 +1,316 @@
+package chromedb
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Names of the numeric content setting values.
+var contentSettingValues = []string{
+	"default",
+	"allow",
+	"block",
+	"ask",
+	"session_only",
+	"detect_important_content",
+}
+
+// Names of the places an extension can be installed from.
+var extensionLocations = []string{
+	"invalid",
+	"internal",
+	"external_pref",
+	"external_registry",
+	"unpacked",
+	"component",
+	"external_pref_download",
+	"external_policy_download",
+	"command_line",
+	"external_policy",
+	"external_component",
+}
+
+type AccountInfo struct {
+	AccountID    string `json:"account_id"`
+	Email        string `json:"email"`
+	FullName     string `json:"full_name"`
+	GivenName    string `json:"given_name"`
+	Gaia         string `json:"gaia"`
+	HostedDomain string `json:"hd"`
+	Locale       string `json:"locale"`
+	PictureURL   string `json:"picture_url"`
+}
+
+type ContentSetting struct {
+	Type         string      `json:"type"`
+	Pattern      string      `json:"pattern"`
+	Setting      interface{} `json:"setting"`
+	Value        string      `json:"value,omitempty"`
+	LastModified time.Time   `json:"last_modified"`
+}
+
+type Extension struct {
+	ID              string    `json:"id"`
+	Name            string    `json:"name"`
+	Version         string    `json:"version"`
+	Description     string    `json:"description"`
+	Location        string    `json:"location"`
+	Enabled         bool      `json:"enabled"`
+	FromWebstore    bool      `json:"from_webstore"`
+	InstallTime     time.Time `json:"install_time"`
+	Path            string    `json:"path"`
+	Permissions     []string  `json:"permissions"`
+	HostPermissions []string  `json:"host_permissions"`
+}
+
+type Preferences struct {
+	Accounts        []AccountInfo
+	ContentSettings []ContentSetting
+	Extensions      []Extension
+}
+
+type contentSettingEntry struct {
+	LastModified string      `json:"last_modified"`
+	Setting      interface{} `json:"setting"`
+}
+
+type extensionManifest struct {
+	Name            string        `json:"name"`
+	Version         string        `json:"version"`
+	Description     string        `json:"description"`
+	DefaultLocale   string        `json:"default_locale"`
+	Permissions     []interface{} `json:"permissions"`
+	HostPermissions []string      `json:"host_permissions"`
+}
+
+type extensionSettings struct {
+	Manifest          *extensionManifest `json:"manifest"`
+	Location          int                `json:"location"`
+	State             *int               `json:"state"`
+	DisableReasons    interface{}        `json:"disable_reasons"`
+	FromWebstore      bool               `json:"from_webstore"`
+	InstallTime       string             `json:"install_time"`
+	Path              string             `json:"path"`
+	ActivePermissions struct {
+		API          []interface{} `json:"api"`
+		ExplicitHost []string      `json:"explicit_host"`
+	} `json:"active_permissions"`
+}
+
+type preferencesFile struct {
+	AccountInfo []AccountInfo `json:"account_info"`
+	Profile     struct {
+		ContentSettings struct {
+			Exceptions map[string]map[string]contentSettingEntry `json:"exceptions"`
+		} `json:"content_settings"`
+	} `json:"profile"`
+	Extensions struct {
+		Settings map[string]extensionSettings `json:"settings"`
+	} `json:"extensions"`
+}
+
+func readPreferencesFile(fsys fs.FS, name string) (*preferencesFile, error) {
+	data, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return nil, err
+	}
+	var f preferencesFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
+
+// GetPreferences reads signed-in accounts, per-site content settings and
+// installed extensions from a profile directory's Preferences and, where the
+// browser keeps extension settings separately, Secure Preferences.
+func GetPreferences(profileDir string) (*Preferences, error) {
+	return GetPreferencesFS(osFS(profileDir))
+}
+
+// GetPreferencesFS is GetPreferences for a profile directory in fsys.
+// Unpacked extensions recorded with absolute paths are looked for relative
+// to the root of fsys.
+func GetPreferencesFS(fsys fs.FS, profileDir string) (*Preferences, error) {
+	prefs, err := readPreferencesFile(fsys, path.Join(profileDir, "Preferences"))
+	if err != nil {
+		return nil, err
+	}
+
+	settings := prefs.Extensions.Settings
+	secure, err := readPreferencesFile(fsys, path.Join(profileDir, "Secure Preferences"))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	if secure != nil {
+		if settings == nil {
+			settings = map[string]extensionSettings{}
+		}
+		for id, s := range secure.Extensions.Settings {
+			settings[id] = s
+		}
+	}
+
+	return &Preferences{
+		Accounts:        prefs.AccountInfo,
+		ContentSettings: contentSettings(prefs.Profile.ContentSettings.Exceptions),
+		Extensions:      extensions(fsys, profileDir, settings),
+	}, nil
+}
+
+func contentSettings(exceptions map[string]map[string]contentSettingEntry) []ContentSetting {
+	var settings []ContentSetting
+	for typ, patterns := range exceptions {
+		for pattern, entry := range patterns {
+			cs := ContentSetting{
+				Type:         typ,
+				Pattern:      pattern,
+				Setting:      entry.Setting,
+				LastModified: chromeTimeString(entry.LastModified),
+			}
+			if v, ok := entry.Setting.(float64); ok && v >= 0 && int(v) < len(contentSettingValues) && v == float64(int(v)) {
+				cs.Value = contentSettingValues[int(v)]
+			}
+			settings = append(settings, cs)
+		}
+	}
+
+	sort.Slice(settings, func(i, j int) bool {
+		if settings[i].Type != settings[j].Type {
+			return settings[i].Type < settings[j].Type
+		}
+		return settings[i].Pattern < settings[j].Pattern
+	})
+	return settings
+}
+
+func extensions(fsys fs.FS, profileDir string, settings map[string]extensionSettings) []Extension {
+	var exts []Extension
+	for id, s := range settings {
+		ext := Extension{
+			ID:              id,
+			FromWebstore:    s.FromWebstore,
+			InstallTime:     chromeTimeString(s.InstallTime),
+			Path:            s.Path,
+			Permissions:     extensionPermissions(s.ActivePermissions.API),
+			HostPermissions: s.ActivePermissions.ExplicitHost,
+		}
+		if s.Location >= 0 && s.Location < len(extensionLocations) {
+			ext.Location = extensionLocations[s.Location]
+		}
+
+		// Older profiles record an explicit state; newer ones only record
+		// why an extension is disabled.
+		if s.State != nil {
+			ext.Enabled = *s.State == 1
+		} else {
+			switch reasons := s.DisableReasons.(type) {
+			case float64:
+				ext.Enabled = reasons == 0
+			case []interface{}:
+				ext.Enabled = len(reasons) == 0
+			default:
+				ext.Enabled = true
+			}
+		}
+
+		var dir string
+		if s.Path != "" {
+			dir = fsName(path.Join(profileDir, "Extensions"), s.Path)
+		}
+		manifest := s.Manifest
+		if manifest == nil {
+			manifest, dir = readExtensionManifest(fsys, profileDir, id, dir)
+		}
+		if manifest != nil {
+			ext.Name = localizeExtensionString(fsys, dir, manifest.DefaultLocale, manifest.Name)
+			ext.Version = manifest.Version
+			ext.Description = localizeExtensionString(fsys, dir, manifest.DefaultLocale, manifest.Description)
+			if ext.Permissions == nil {
+				ext.Permissions = extensionPermissions(manifest.Permissions)
+			}
+			if ext.HostPermissions == nil {
+				ext.HostPermissions = manifest.HostPermissions
+			}
+		}
+
+		exts = append(exts, ext)
+	}
+
+	sort.Slice(exts, func(i, j int) bool { return exts[i].ID < exts[j].ID })
+	return exts
+}
+
+// extensionPermissions flattens a permission list, in which permissions with
+// arguments are objects keyed by the permission name.
+func extensionPermissions(list []interface{}) []string {
+	var perms []string
+	for _, p := range list {
+		switch p := p.(type) {
+		case string:
+			perms = append(perms, p)
+		case map[string]interface{}:
+			for name := range p {
+				perms = append(perms, name)
+			}
+		}
+	}
+	return perms
+}
+
+// readExtensionManifest reads an extension's manifest.json, for extensions
+// whose manifest isn't copied into Preferences. It returns the directory the
+// manifest was found in.
+func readExtensionManifest(fsys fs.FS, profileDir, id, dir string) (*extensionManifest, string) {
+	dirs := []string{}
+	if dir != "" {
+		dirs = append(dirs, dir)
+	}
+	versions, _ := fs.Glob(fsys, path.Join(profileDir, "Extensions", id, "*"))
+	sort.Sort(sort.Reverse(sort.StringSlice(versions)))
+	dirs = append(dirs, versions...)
+
+	for _, d := range dirs {
+		data, err := fs.ReadFile(fsys, path.Join(d, "manifest.json"))
+		if err != nil {
+			continue
+		}
+		var m extensionManifest
+		if err := json.Unmarshal(data, &m); err != nil {
+			continue
+		}
+		return &m, d
+	}
+	return nil, dir
+}
+
+// localizeExtensionString resolves a __MSG_name__ placeholder from the
+// extension's default locale.
+func localizeExtensionString(fsys fs.FS, dir, locale, s string) string {
+	if !strings.HasPrefix(s, "__MSG_") || !strings.HasSuffix(s, "__") || dir == "" || locale == "" {
+		return s
+	}
+	key := strings.TrimSuffix(strings.TrimPrefix(s, "__MSG_"), "__")
+
+	data, err := fs.ReadFile(fsys, path.Join(dir, "_locales", locale, "messages.json"))
+	if err != nil {
+		return s
+	}
+	var messages map[string]struct {
+		Message string `json:"message"`
+	}
+	if err := json.Unmarshal(data, &messages); err != nil {
+		return s
+	}
+	for name, m := range messages {
+		if strings.EqualFold(name, key) {
+			return m.Message
+		}
+	}
+	return s
+}
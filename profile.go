@@ -0,0 +1,568 @@
+package chromedb
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+)
+
+// ProfileOptions controls how OpenProfile reads a profile.
+type ProfileOptions struct {
+	// Key, if set, decrypts the profile's secrets instead of the browser's
+	// own key.
+	Key []byte
+
+	// KeySource, if set, fetches the key in place of GetBrowserKey. It's
+	// called at most once, and only if something needs decrypting.
+	KeySource func(BrowserProfile) ([]byte, error)
+
+	// Raw reads local and session storage from the raw LevelDB files,
+	// including deleted and overwritten records.
+	Raw bool
+
+	// OnStore, if set, is called with the path of each store before it's
+	// read.
+	OnStore func(store Store, path string)
+
+	// OnDecryptError, if set, is called for each cookie, password, card or
+	// token that can't be decrypted. The record is still returned, without
+	// its plaintext.
+	OnDecryptError func(err error)
+}
+
+// Profile is a browser profile opened for reading. Each store is read on
+// demand, with the key fetched once and shared between them. Stores are
+// read from copies or read-only views, so the profile can be open in a
+// running browser.
+type Profile struct {
+	// Path is the profile's directory: an OS path, or for profiles opened
+	// with OpenProfileFS, a name in the file system.
+	Path string
+	Info BrowserProfile
+
+	fsys fs.FS
+	dir  string
+	// os is set when fsys is the OS file system holding Path.
+	os bool
+	// archive is the path of the archive fsys was read from, if any.
+	archive string
+
+	opts    ProfileOptions
+	key     []byte
+	keyErr  error
+	haveKey bool
+	closers []func()
+}
+
+// OpenProfile opens the profile directory at profilePath. opts may be nil.
+func OpenProfile(profilePath string, opts *ProfileOptions) (*Profile, error) {
+	fi, err := os.Stat(profilePath)
+	if err != nil {
+		return nil, err
+	}
+	if !fi.IsDir() {
+		return nil, fmt.Errorf("%s is not a directory", profilePath)
+	}
+
+	fsys, dir := osFS(profilePath)
+	p, err := OpenProfileFS(fsys, dir, opts)
+	if err != nil {
+		return nil, err
+	}
+	p.Path, p.Info, p.os = profilePath, ProfileForPath(profilePath), true
+	return p, nil
+}
+
+// OpenProfileFS opens the profile directory dir in fsys, such as an
+// archive/zip Reader or a disk image, without extracting it. SQLite
+// databases are staged to temporary files as they're read. Unless opts
+// supplies the key, it's looked up as for a profile of an unknown browser on
+// this machine, or taken to be nil for Android profiles.
+func OpenProfileFS(fsys fs.FS, dir string, opts *ProfileOptions) (*Profile, error) {
+	fi, err := fs.Stat(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+	if !fi.IsDir() {
+		return nil, fmt.Errorf("%s is not a directory", dir)
+	}
+
+	p := &Profile{
+		Path: dir,
+		Info: BrowserProfile{
+			UserDataDir: path.Dir(dir),
+			Dir:         path.Base(dir),
+			Path:        dir,
+			Name:        path.Base(dir),
+		},
+		fsys: fsys,
+		dir:  dir,
+	}
+	if b, ok := androidLocation(path.Dir(dir)); ok {
+		p.Info.Browser, p.Info.BrowserName, p.Info.Android = b.id, b.name, true
+	}
+	if opts != nil {
+		p.opts = *opts
+	}
+	if p.opts.Key != nil {
+		p.key, p.haveKey = p.opts.Key, true
+	}
+	return p, nil
+}
+
+// Close releases the stores read from the profile.
+func (p *Profile) Close() error {
+	for i := len(p.closers) - 1; i >= 0; i-- {
+		p.closers[i]()
+	}
+	p.closers = nil
+	return nil
+}
+
+// Key returns the key that decrypts the profile's secrets, fetching it the
+// first time it's needed; see GetBrowserKey. For profiles opened with
+// OpenProfileFS, Local State is read from the file system, next to the
+// profile or in it.
+func (p *Profile) Key() ([]byte, error) {
+	if !p.haveKey {
+		source := GetBrowserKey
+		if !p.os {
+			source = func(info BrowserProfile) ([]byte, error) {
+				if _, err := fs.Stat(p.fsys, path.Join(p.dir, "Local State")); err == nil {
+					info.UserDataDir = p.dir
+				}
+				return GetBrowserKeyFS(p.fsys, info)
+			}
+		}
+		if p.opts.KeySource != nil {
+			source = p.opts.KeySource
+		}
+		p.key, p.keyErr = source(p.Info)
+		p.haveKey = true
+	}
+	return p.key, p.keyErr
+}
+
+// Resolve returns the path of a store within the profile, in the same form
+// as Path; see ResolveStore.
+func (p *Profile) Resolve(store Store) (string, error) {
+	name, err := p.resolve(store)
+	if err != nil {
+		return "", err
+	}
+	return p.displayPath(name), nil
+}
+
+// resolve returns the name of a store in the profile's file system.
+func (p *Profile) resolve(store Store) (string, error) {
+	name, err := p.find(store)
+	if err != nil {
+		return "", err
+	}
+	if p.opts.OnStore != nil {
+		p.opts.OnStore(store, p.displayPath(name))
+	}
+	return name, nil
+}
+
+// displayPath turns a name in the profile's file system into the form of
+// Path.
+func (p *Profile) displayPath(name string) string {
+	switch {
+	case p.os:
+		return osPath(p.Path, name)
+	case p.archive != "":
+		return filepath.Join(p.archive, filepath.FromSlash(name))
+	}
+	return name
+}
+
+// find is resolve without reporting the store. For OS profiles it also
+// looks in the profile's cache directory, which fsys reaches from its root.
+func (p *Profile) find(store Store) (string, error) {
+	if p.os {
+		storePath, err := ResolveStore(p.Path, store)
+		if err != nil {
+			return "", err
+		}
+		_, name := osFS(storePath)
+		return name, nil
+	}
+	name, err := ResolveStoreFS(p.fsys, p.dir, store)
+	if err != nil {
+		return "", fmt.Errorf("%s not found in %s: %w", store, p.Path, fs.ErrNotExist)
+	}
+	return name, nil
+}
+
+// Has reports whether the profile has the store.
+func (p *Profile) Has(store Store) bool {
+	_, err := p.find(store)
+	return err == nil
+}
+
+func (p *Profile) decryptError(err error) {
+	if p.opts.OnDecryptError != nil {
+		p.opts.OnDecryptError(err)
+	}
+}
+
+// Cookies returns the profile's cookies, then those set by extensions, with
+// their values decrypted.
+func (p *Profile) Cookies() ([]Cookie, error) {
+	cookiesPath, err := p.resolve(StoreCookies)
+	if err != nil {
+		return nil, err
+	}
+	cookiesPaths := []string{cookiesPath}
+	if p.Has(StoreExtensionCookies) {
+		name, _ := p.resolve(StoreExtensionCookies)
+		cookiesPaths = append(cookiesPaths, name)
+	}
+
+	var all []Cookie
+	for _, cookiesPath := range cookiesPaths {
+		cookies, err := GetCookiesFS(p.fsys, cookiesPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open %s database: %w", path.Base(cookiesPath), err)
+		}
+
+		for i, c := range cookies {
+			if len(c.EncryptedValue) == 0 {
+				continue
+			}
+			key, err := p.Key()
+			if err != nil {
+				return nil, fmt.Errorf("failed to get key: %w", err)
+			}
+			value, err := DecryptValue(c.EncryptedValue, key, c.Domain)
+			if err != nil {
+				p.decryptError(fmt.Errorf("cookie %s: %w", c.Name, err))
+			}
+			cookies[i].Value = value
+		}
+		all = append(all, cookies...)
+	}
+	return all, nil
+}
+
+// Logins returns the profile's saved logins, then those saved to the
+// signed-in account, with their passwords decrypted.
+func (p *Profile) Logins() ([]Login, error) {
+	loginDataPath, err := p.resolve(StoreLoginData)
+	if err != nil {
+		return nil, err
+	}
+	loginDataPaths := []string{loginDataPath}
+	if p.Has(StoreLoginDataForAccount) {
+		name, _ := p.resolve(StoreLoginDataForAccount)
+		loginDataPaths = append(loginDataPaths, name)
+	}
+
+	var all []Login
+	for _, loginDataPath := range loginDataPaths {
+		logins, err := GetLoginsFS(p.fsys, loginDataPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open %s database: %w", path.Base(loginDataPath), err)
+		}
+
+		for i, l := range logins {
+			if len(l.EncryptedPassword) == 0 {
+				continue
+			}
+			key, err := p.Key()
+			if err != nil {
+				return nil, fmt.Errorf("failed to get key: %w", err)
+			}
+			password, err := DecryptLogin(l, key)
+			if err != nil {
+				p.decryptError(fmt.Errorf("password for %s: %w", l.OriginURL, err))
+			}
+			logins[i].Password = password
+		}
+		all = append(all, logins...)
+	}
+	return all, nil
+}
+
+// WebData returns the profile's autofill data, search engines and tokens,
+// with card numbers and tokens decrypted.
+func (p *Profile) WebData() (*WebData, error) {
+	webDataPath, err := p.resolve(StoreWebData)
+	if err != nil {
+		return nil, err
+	}
+	wd, err := GetWebDataFS(p.fsys, webDataPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open Web Data database: %w", err)
+	}
+
+	for i, c := range wd.CreditCards {
+		if len(c.EncryptedCardNumber) == 0 {
+			continue
+		}
+		key, err := p.Key()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get key: %w", err)
+		}
+		number, err := Decrypt(c.EncryptedCardNumber, key)
+		if err != nil {
+			p.decryptError(fmt.Errorf("card %s: %w", c.GUID, err))
+		}
+		wd.CreditCards[i].CardNumber = string(number)
+	}
+	for i, t := range wd.Tokens {
+		if len(t.EncryptedToken) == 0 {
+			continue
+		}
+		key, err := p.Key()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get key: %w", err)
+		}
+		token, err := Decrypt(t.EncryptedToken, key)
+		if err != nil {
+			p.decryptError(fmt.Errorf("token for %s: %w", t.Service, err))
+		}
+		wd.Tokens[i].Token = string(token)
+	}
+	return wd, nil
+}
+
+// LocalStorage returns the profile's local storage, read raw if the profile
+// was opened with Raw.
+func (p *Profile) LocalStorage() (*LocalStoreDb, error) {
+	dir, err := p.resolve(StoreLocalStorage)
+	if err != nil {
+		return nil, err
+	}
+	load := LoadLocalStorageFS
+	if p.opts.Raw {
+		load = LoadLocalStorageRawFS
+	}
+	lsd, err := load(p.fsys, dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open LevelDB: %w", err)
+	}
+	p.closers = append(p.closers, lsd.Close)
+	return lsd, nil
+}
+
+// SessionStorage returns the profile's session storage, read raw if the
+// profile was opened with Raw.
+func (p *Profile) SessionStorage() (*SessionStoreDb, error) {
+	dir, err := p.resolve(StoreSessionStorage)
+	if err != nil {
+		return nil, err
+	}
+	load := LoadSessionStorageFS
+	if p.opts.Raw {
+		load = LoadSessionStorageRawFS
+	}
+	ssd, err := load(p.fsys, dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open LevelDB: %w", err)
+	}
+	p.closers = append(p.closers, ssd.Close)
+	return ssd, nil
+}
+
+// IndexedDB returns each of the profile's IndexedDB databases.
+func (p *Profile) IndexedDB() ([]*IndexedStoreDb, error) {
+	dir, err := p.resolve(StoreIndexedDB)
+	if err != nil {
+		return nil, err
+	}
+	dbPaths, err := fs.Glob(p.fsys, path.Join(dir, "*.indexeddb.leveldb"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list IndexedDB databases: %w", err)
+	}
+
+	var dbs []*IndexedStoreDb
+	for _, dbPath := range dbPaths {
+		isd, err := LoadIndexedDbFS(p.fsys, dbPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open LevelDB: %w", err)
+		}
+		p.closers = append(p.closers, isd.Close)
+		dbs = append(dbs, isd)
+	}
+	return dbs, nil
+}
+
+// ExtensionStorage returns the storage of the profile's extensions.
+func (p *Profile) ExtensionStorage() (*ExtensionStoreDb, error) {
+	esd, err := LoadExtensionStorageFS(p.fsys, p.dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open LevelDB: %w", err)
+	}
+	return esd, nil
+}
+
+// CacheStorage returns the profile's Cache Storage API caches, including
+// those kept per storage bucket by profiles with storage partitioning.
+func (p *Profile) CacheStorage() ([]*CacheStoreDb, error) {
+	cacheStoragePaths, err := fs.Glob(p.fsys, path.Join(p.dir, "WebStorage", "*", "CacheStorage"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list Cache Storage directories: %w", err)
+	}
+	cacheStoragePaths = append([]string{path.Join(p.dir, "Service Worker", "CacheStorage")}, cacheStoragePaths...)
+
+	var dbs []*CacheStoreDb
+	for _, cacheStoragePath := range cacheStoragePaths {
+		if _, err := fs.Stat(p.fsys, cacheStoragePath); errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		csd, err := LoadCacheStorageFS(p.fsys, cacheStoragePath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read Cache Storage: %w", err)
+		}
+		dbs = append(dbs, csd)
+	}
+	return dbs, nil
+}
+
+// ServiceWorkers returns the profile's service worker registrations.
+func (p *Profile) ServiceWorkers() ([]ServiceWorkerRegistration, error) {
+	dir, err := p.resolve(StoreServiceWorker)
+	if err != nil {
+		return nil, err
+	}
+	return GetServiceWorkersFS(p.fsys, dir)
+}
+
+// HTTPCache returns the profile's HTTP cache entries, only those whose URL
+// matches urlFilter if it isn't nil.
+func (p *Profile) HTTPCache(urlFilter *regexp.Regexp) (*HTTPCacheDb, error) {
+	dir, err := p.resolve(StoreHTTPCache)
+	if err != nil {
+		return nil, err
+	}
+	return LoadHTTPCacheFS(p.fsys, dir, urlFilter)
+}
+
+// WebSQL returns the rows of the profile's Web SQL databases.
+func (p *Profile) WebSQL() (*WebSQLDb, error) {
+	dir, err := p.resolve(StoreWebSQL)
+	if err != nil {
+		return nil, err
+	}
+	return LoadWebSQLFS(p.fsys, dir)
+}
+
+// History returns the profile's browsing history.
+func (p *Profile) History() (*History, error) {
+	name, err := p.resolve(StoreHistory)
+	if err != nil {
+		return nil, err
+	}
+	return GetHistoryFS(p.fsys, name)
+}
+
+// Bookmarks returns the profile's bookmarks.
+func (p *Profile) Bookmarks() ([]Bookmark, error) {
+	name, err := p.resolve(StoreBookmarks)
+	if err != nil {
+		return nil, err
+	}
+	return GetBookmarksFS(p.fsys, name)
+}
+
+// Preferences returns the accounts, content settings and extensions from
+// the profile's Preferences.
+func (p *Profile) Preferences() (*Preferences, error) {
+	return GetPreferencesFS(p.fsys, p.dir)
+}
+
+// LocalState returns the browser's Local State. It lives in the user data
+// directory, above the profile, except where the user data directory is the
+// profile, as in Opera and Electron apps.
+func (p *Profile) LocalState() (*LocalState, error) {
+	localStatePath := path.Join(p.dir, "Local State")
+	if _, err := fs.Stat(p.fsys, localStatePath); errors.Is(err, fs.ErrNotExist) {
+		localStatePath = path.Join(path.Dir(p.dir), "Local State")
+	}
+	return GetLocalStateFS(p.fsys, localStatePath)
+}
+
+// TransportSecurity returns the profile's HSTS entries.
+func (p *Profile) TransportSecurity() (*TransportSecurity, error) {
+	name, err := p.resolve(StoreTransportSecurity)
+	if err != nil {
+		return nil, err
+	}
+	return GetTransportSecurityFS(p.fsys, name)
+}
+
+// NetworkState returns the servers and alternative services the profile's
+// network service remembers.
+func (p *Profile) NetworkState() (*NetworkState, error) {
+	name, err := p.resolve(StoreNetworkState)
+	if err != nil {
+		return nil, err
+	}
+	return GetNetworkStateFS(p.fsys, name)
+}
+
+// Shortcuts returns the profile's omnibox shortcuts.
+func (p *Profile) Shortcuts() ([]Shortcut, error) {
+	name, err := p.resolve(StoreShortcuts)
+	if err != nil {
+		return nil, err
+	}
+	return GetShortcutsFS(p.fsys, name)
+}
+
+// TopSites returns the profile's most visited sites.
+func (p *Profile) TopSites() ([]TopSite, error) {
+	name, err := p.resolve(StoreTopSites)
+	if err != nil {
+		return nil, err
+	}
+	return GetTopSitesFS(p.fsys, name)
+}
+
+// NetworkActionPredictor returns the profile's typed text and the URLs it
+// led to.
+func (p *Profile) NetworkActionPredictor() ([]PredictorEntry, error) {
+	name, err := p.resolve(StoreNetworkActionPredictor)
+	if err != nil {
+		return nil, err
+	}
+	return GetNetworkActionPredictorFS(p.fsys, name)
+}
+
+// Favicons returns the profile's favicons and the pages that use them.
+func (p *Profile) Favicons() ([]Favicon, error) {
+	name, err := p.resolve(StoreFavicons)
+	if err != nil {
+		return nil, err
+	}
+	return GetFaviconsFS(p.fsys, name)
+}
+
+// VisitedLinks returns the profile's visited link fingerprints.
+func (p *Profile) VisitedLinks() (*VisitedLinks, error) {
+	name, err := p.resolve(StoreVisitedLinks)
+	if err != nil {
+		return nil, err
+	}
+	return GetVisitedLinksFS(p.fsys, name)
+}
+
+// SyncData returns the entities and devices stored by the profile's sync
+// engine.
+func (p *Profile) SyncData() (*SyncData, error) {
+	dir, err := p.resolve(StoreSyncData)
+	if err != nil {
+		return nil, err
+	}
+	sd, err := LoadSyncDataFS(p.fsys, dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open LevelDB: %w", err)
+	}
+	return sd, nil
+}
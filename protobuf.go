@@ -0,0 +1,100 @@
+package chromedb
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// protoField is one field of a protobuf message decoded without its schema.
+// Varint and fixed-width values are held in Uint; length-delimited values,
+// which may be strings, bytes or nested messages, in Bytes.
+type protoField struct {
+	Number protowire.Number
+	Type   protowire.Type
+	Uint   uint64
+	Bytes  []byte
+}
+
+// parseProtoFields splits a protobuf message into its fields, for the many
+// small messages Chromium stores whose schemas aren't worth generating.
+func parseProtoFields(b []byte) ([]protoField, error) {
+	var fields []protoField
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return nil, fmt.Errorf("failed to read protobuf tag: %w", protowire.ParseError(n))
+		}
+		b = b[n:]
+
+		f := protoField{Number: num, Type: typ}
+		switch typ {
+		case protowire.VarintType:
+			f.Uint, n = protowire.ConsumeVarint(b)
+		case protowire.Fixed32Type:
+			var v uint32
+			v, n = protowire.ConsumeFixed32(b)
+			f.Uint = uint64(v)
+		case protowire.Fixed64Type:
+			f.Uint, n = protowire.ConsumeFixed64(b)
+		case protowire.BytesType:
+			f.Bytes, n = protowire.ConsumeBytes(b)
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, b)
+		}
+		if n < 0 {
+			return nil, fmt.Errorf("failed to read protobuf field %d: %w", num, protowire.ParseError(n))
+		}
+		b = b[n:]
+		fields = append(fields, f)
+	}
+	return fields, nil
+}
+
+// protoToMap decodes a protobuf message without its schema into a map keyed
+// by field number, for messages too varied to decode field by field.
+// Length-delimited fields become strings when they're printable text, nested
+// maps when they parse as a message, and base64 otherwise. Repeated fields
+// become slices.
+func protoToMap(b []byte) (map[string]interface{}, error) {
+	fields, err := parseProtoFields(b)
+	if err != nil {
+		return nil, err
+	}
+
+	m := map[string]interface{}{}
+	for _, f := range fields {
+		var v interface{} = f.Uint
+		if f.Type == protowire.BytesType {
+			v = protoBytesValue(f.Bytes)
+		}
+
+		key := strconv.Itoa(int(f.Number))
+		switch prev := m[key].(type) {
+		case nil:
+			m[key] = v
+		case []interface{}:
+			m[key] = append(prev, v)
+		default:
+			m[key] = []interface{}{prev, v}
+		}
+	}
+	return m, nil
+}
+
+func protoBytesValue(b []byte) interface{} {
+	if utf8.Valid(b) && strings.IndexFunc(string(b), func(r rune) bool {
+		return !unicode.IsPrint(r) && !unicode.IsSpace(r)
+	}) < 0 {
+		return string(b)
+	}
+	if nested, err := protoToMap(b); err == nil {
+		return nested
+	}
+	return base64.StdEncoding.EncodeToString(b)
+}
@@ -0,0 +1,360 @@
+package chromedb
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+
+	"github.com/golang/snappy"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// States of a key version recovered from the raw LevelDB files.
+const (
+	RawStateLive        = "live"
+	RawStateDeleted     = "deleted"
+	RawStateOverwritten = "overwritten"
+)
+
+const (
+	rawJournalBlockSize  = 32 * 1024
+	rawJournalHeaderSize = 7
+
+	rawTableFooterSize  = 48
+	rawTableMagic       = 0xdb4775248b80fb57
+	rawBlockTrailerSize = 5
+
+	rawTypeDeletion = 0
+	rawTypeValue    = 1
+)
+
+var (
+	rawJournalName = regexp.MustCompile(`^(\d+)\.log$`)
+	rawTableName   = regexp.MustCompile(`^(\d+)\.(ldb|sst)$`)
+)
+
+// RawLevelDBRecord is a single version of a key, read straight from a
+// journal or table file rather than through the live view of the database.
+type RawLevelDBRecord struct {
+	Key     []byte `json:"key"`
+	Value   []byte `json:"value"`
+	Seq     uint64 `json:"seq"`
+	Deleted bool   `json:"deleted"`
+	State   string `json:"state"`
+	File    string `json:"file"`
+}
+
+// ReadRawLevelDB parses every journal (.log) and table (.ldb/.sst) in dir and
+// returns every key version it finds, including deletions and values that
+// have since been overwritten. The MANIFEST is not consulted, so this works
+// on stores that goleveldb refuses to open. Damaged blocks are skipped.
+func ReadRawLevelDB(dir string) ([]RawLevelDBRecord, error) {
+	return ReadRawLevelDBFS(osFS(dir))
+}
+
+// ReadRawLevelDBFS is ReadRawLevelDB for a store in fsys.
+func ReadRawLevelDBFS(fsys fs.FS, dir string) ([]RawLevelDBRecord, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var records []RawLevelDBRecord
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		name := e.Name()
+
+		var parse func([]byte, string) []RawLevelDBRecord
+		switch {
+		case rawJournalName.MatchString(name):
+			parse = parseRawJournal
+		case rawTableName.MatchString(name):
+			parse = parseRawTable
+		default:
+			continue
+		}
+
+		data, err := fs.ReadFile(fsys, path.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, parse(data, name)...)
+	}
+
+	return resolveRawStates(records), nil
+}
+
+// resolveRawStates drops duplicate versions (a compaction copies entries from
+// journals into tables) and labels each remaining version.
+func resolveRawStates(records []RawLevelDBRecord) []RawLevelDBRecord {
+	type version struct {
+		key     string
+		seq     uint64
+		deleted bool
+	}
+	seen := map[version]bool{}
+	unique := records[:0]
+	for _, r := range records {
+		v := version{string(r.Key), r.Seq, r.Deleted}
+		if seen[v] {
+			continue
+		}
+		seen[v] = true
+		unique = append(unique, r)
+	}
+
+	// Newest version of each key first.
+	sort.SliceStable(unique, func(i, j int) bool {
+		if ki, kj := string(unique[i].Key), string(unique[j].Key); ki != kj {
+			return ki < kj
+		}
+		return unique[i].Seq > unique[j].Seq
+	})
+
+	for i := range unique {
+		r := &unique[i]
+		newest := i == 0 || string(unique[i-1].Key) != string(r.Key)
+		switch {
+		case r.Deleted:
+			r.State = RawStateDeleted
+		case newest:
+			r.State = RawStateLive
+		case unique[i-1].Deleted:
+			r.State = RawStateDeleted
+		default:
+			r.State = RawStateOverwritten
+		}
+	}
+
+	return unique
+}
+
+// parseRawJournal reassembles the records of a LevelDB log file and decodes
+// the write batches they contain.
+func parseRawJournal(data []byte, name string) []RawLevelDBRecord {
+	var records []RawLevelDBRecord
+	var pending []byte
+	inRecord := false
+
+	for block := 0; block < len(data); block += rawJournalBlockSize {
+		end := block + rawJournalBlockSize
+		if end > len(data) {
+			end = len(data)
+		}
+
+		for pos := block; pos+rawJournalHeaderSize <= end; {
+			length := int(binary.LittleEndian.Uint16(data[pos+4:]))
+			chunkType := data[pos+6]
+			start := pos + rawJournalHeaderSize
+			if chunkType == 0 || start+length > end {
+				// Zero padding or a torn write; the rest of the block is
+				// unusable.
+				break
+			}
+			chunk := data[start : start+length]
+			pos = start + length
+
+			switch chunkType {
+			case 1: // full
+				records = append(records, parseRawBatch(chunk, name)...)
+				inRecord = false
+			case 2: // first
+				pending = append(pending[:0], chunk...)
+				inRecord = true
+			case 3: // middle
+				if inRecord {
+					pending = append(pending, chunk...)
+				}
+			case 4: // last
+				if inRecord {
+					pending = append(pending, chunk...)
+					records = append(records, parseRawBatch(pending, name)...)
+				}
+				inRecord = false
+			}
+		}
+	}
+
+	return records
+}
+
+// parseRawBatch decodes a write batch: a sequence number and count followed
+// by put and delete operations. A truncated batch yields what it can.
+func parseRawBatch(b []byte, name string) []RawLevelDBRecord {
+	if len(b) < 12 {
+		return nil
+	}
+	seq := binary.LittleEndian.Uint64(b)
+	count := binary.LittleEndian.Uint32(b[8:])
+	b = b[12:]
+
+	var records []RawLevelDBRecord
+	for i := uint32(0); i < count && len(b) > 0; i++ {
+		kind := b[0]
+		key, n := protowire.ConsumeBytes(b[1:])
+		if n < 0 {
+			break
+		}
+		b = b[1+n:]
+
+		r := RawLevelDBRecord{
+			Key:  append([]byte{}, key...),
+			Seq:  seq + uint64(i),
+			File: name,
+		}
+		switch kind {
+		case rawTypeValue:
+			value, n := protowire.ConsumeBytes(b)
+			if n < 0 {
+				return records
+			}
+			b = b[n:]
+			r.Value = append([]byte{}, value...)
+		case rawTypeDeletion:
+			r.Deleted = true
+		default:
+			return records
+		}
+		records = append(records, r)
+	}
+	return records
+}
+
+// parseRawTable reads every data block listed in a table's index block.
+func parseRawTable(data []byte, name string) []RawLevelDBRecord {
+	if len(data) < rawTableFooterSize {
+		return nil
+	}
+	footer := data[len(data)-rawTableFooterSize:]
+	if binary.LittleEndian.Uint64(footer[40:]) != rawTableMagic {
+		return nil
+	}
+
+	// Skip the metaindex handle to get to the index handle.
+	_, _, n := consumeRawBlockHandle(footer)
+	if n < 0 {
+		return nil
+	}
+	indexOffset, indexSize, m := consumeRawBlockHandle(footer[n:])
+	if m < 0 {
+		return nil
+	}
+	index, err := readRawBlock(data, indexOffset, indexSize)
+	if err != nil {
+		return nil
+	}
+
+	var records []RawLevelDBRecord
+	for _, handle := range parseRawBlock(index) {
+		offset, size, n := consumeRawBlockHandle(handle.value)
+		if n < 0 {
+			continue
+		}
+		block, err := readRawBlock(data, offset, size)
+		if err != nil {
+			continue
+		}
+		for _, e := range parseRawBlock(block) {
+			if len(e.key) < 8 {
+				continue
+			}
+			trailer := binary.LittleEndian.Uint64(e.key[len(e.key)-8:])
+			r := RawLevelDBRecord{
+				Key:     e.key[:len(e.key)-8],
+				Seq:     trailer >> 8,
+				Deleted: trailer&0xff == rawTypeDeletion,
+				File:    name,
+			}
+			if !r.Deleted {
+				r.Value = e.value
+			}
+			records = append(records, r)
+		}
+	}
+	return records
+}
+
+func consumeRawBlockHandle(b []byte) (uint64, uint64, int) {
+	offset, n := protowire.ConsumeVarint(b)
+	if n < 0 {
+		return 0, 0, n
+	}
+	size, m := protowire.ConsumeVarint(b[n:])
+	if m < 0 {
+		return 0, 0, m
+	}
+	return offset, size, n + m
+}
+
+// readRawBlock returns the contents of a table block, decompressing it if
+// it was stored with snappy.
+func readRawBlock(data []byte, offset, size uint64) ([]byte, error) {
+	// offset and size come from the file, so check them without letting the
+	// sum wrap around.
+	n := uint64(len(data))
+	if offset > n || size > n-offset || n-offset-size < rawBlockTrailerSize {
+		return nil, fmt.Errorf("block at %d out of range", offset)
+	}
+	block := data[offset : offset+size]
+	switch data[offset+size] {
+	case 0:
+		return block, nil
+	case 1:
+		return snappy.Decode(nil, block)
+	}
+	return nil, fmt.Errorf("unsupported block compression %d", data[offset+size])
+}
+
+type rawBlockEntry struct {
+	key   []byte
+	value []byte
+}
+
+// parseRawBlock decodes the prefix-compressed entries of a block.
+func parseRawBlock(block []byte) []rawBlockEntry {
+	if len(block) < 4 {
+		return nil
+	}
+	numRestarts := binary.LittleEndian.Uint32(block[len(block)-4:])
+	restartsLen := 4 * (uint64(numRestarts) + 1)
+	if restartsLen > uint64(len(block)) {
+		return nil
+	}
+	b := block[:uint64(len(block))-restartsLen]
+
+	var entries []rawBlockEntry
+	var key []byte
+	for len(b) > 0 {
+		shared, n1 := protowire.ConsumeVarint(b)
+		if n1 < 0 {
+			break
+		}
+		unshared, n2 := protowire.ConsumeVarint(b[n1:])
+		if n2 < 0 {
+			break
+		}
+		valueLen, n3 := protowire.ConsumeVarint(b[n1+n2:])
+		if n3 < 0 {
+			break
+		}
+		b = b[n1+n2+n3:]
+		if shared > uint64(len(key)) || unshared > uint64(len(b)) || valueLen > uint64(len(b))-unshared {
+			break
+		}
+
+		key = append(append([]byte{}, key[:shared]...), b[:unshared]...)
+		value := b[unshared : unshared+valueLen]
+		b = b[unshared+valueLen:]
+
+		entries = append(entries, rawBlockEntry{
+			key:   key,
+			value: append([]byte{}, value...),
+		})
+	}
+	return entries
+}
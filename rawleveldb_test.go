@@ -0,0 +1,209 @@
+package chromedb
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/golang/snappy"
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/util"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// rawEntry encodes a block entry that shares its first shared bytes with the
+// previous key.
+func rawEntry(shared uint64, unshared, value string) []byte {
+	b := protowire.AppendVarint(nil, shared)
+	b = protowire.AppendVarint(b, uint64(len(unshared)))
+	b = protowire.AppendVarint(b, uint64(len(value)))
+	return append(append(b, unshared...), value...)
+}
+
+// rawBlock joins entries into a block with a single restart point.
+func rawBlock(entries ...[]byte) []byte {
+	var b []byte
+	for _, e := range entries {
+		b = append(b, e...)
+	}
+	return binary.LittleEndian.AppendUint32(binary.LittleEndian.AppendUint32(b, 0), 1)
+}
+
+func TestParseRawBlock(t *testing.T) {
+	huge := uint64(math.MaxUint64)
+	hugeEntry := func(shared, unshared, valueLen uint64) []byte {
+		b := protowire.AppendVarint(nil, shared)
+		b = protowire.AppendVarint(b, unshared)
+		b = protowire.AppendVarint(b, valueLen)
+		return append(b, "abcd"...)
+	}
+
+	tests := []struct {
+		name  string
+		block []byte
+		want  []string
+	}{
+		{"empty", nil, nil},
+		{"no entries", rawBlock(), nil},
+		{"entries", rawBlock(rawEntry(0, "apple", "1"), rawEntry(2, "ricot", "2"), rawEntry(0, "b", "")),
+			[]string{"apple=1", "apricot=2", "b="}},
+		{"shared longer than the previous key", rawBlock(rawEntry(0, "a", "1"), rawEntry(2, "b", "2")), []string{"a=1"}},
+		{"value past the block", rawBlock(hugeEntry(0, 1, 9)), nil},
+		{"huge unshared length", rawBlock(rawEntry(0, "a", "1"), hugeEntry(0, huge, 2)), []string{"a=1"}},
+		{"huge value length", rawBlock(hugeEntry(0, 1, huge)), nil},
+		{"huge shared length", rawBlock(hugeEntry(huge, 1, 1)), nil},
+		{"truncated varint", rawBlock([]byte{0x80}), nil},
+		{"too many restarts", binary.LittleEndian.AppendUint32([]byte("abcd"), math.MaxUint32), nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, e := range parseRawBlock(tt.block) {
+				got = append(got, string(e.key)+"="+string(e.value))
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadRawBlock(t *testing.T) {
+	block := rawBlock(rawEntry(0, "k", "v"))
+	trailer := func(compression byte) []byte { return []byte{compression, 0, 0, 0, 0} }
+	plain := append(append([]byte("xx"), block...), trailer(0)...)
+	compressed := append(append([]byte("xx"), snappy.Encode(nil, block)...), trailer(1)...)
+
+	tests := []struct {
+		name         string
+		data         []byte
+		offset, size uint64
+		wantErr      bool
+	}{
+		{"uncompressed", plain, 2, uint64(len(block)), false},
+		{"snappy", compressed, 2, uint64(len(compressed) - 2 - rawBlockTrailerSize), false},
+		{"unknown compression", append(append([]byte("xx"), block...), trailer(7)...), 2, uint64(len(block)), true},
+		{"no room for the trailer", plain, 2, uint64(len(block)) + 1, true},
+		{"offset past the end", plain, uint64(len(plain)) + 1, 0, true},
+		{"offset wraps around", plain, math.MaxUint64, 2, true},
+		{"size wraps around", plain, 2, math.MaxUint64 - 1, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := readRawBlock(tt.data, tt.offset, tt.size)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("readRawBlock succeeded, want error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("readRawBlock: %v", err)
+			}
+			if string(got) != string(block) {
+				t.Errorf("got %x, want %x", got, block)
+			}
+		})
+	}
+}
+
+func TestParseRawBatch(t *testing.T) {
+	batch := binary.LittleEndian.AppendUint64(nil, 7)
+	batch = binary.LittleEndian.AppendUint32(batch, 3)
+	batch = append(batch, rawTypeValue)
+	batch = protowire.AppendBytes(batch, []byte("a"))
+	batch = protowire.AppendBytes(batch, []byte("1"))
+	batch = append(batch, rawTypeDeletion)
+	batch = protowire.AppendBytes(batch, []byte("b"))
+	batch = append(batch, rawTypeValue)
+	batch = protowire.AppendBytes(batch, []byte("c"))
+
+	// The last put is missing its value, so only the first two survive.
+	records := parseRawBatch(batch, "000001.log")
+	if len(records) != 2 {
+		t.Fatalf("got %d records, want 2", len(records))
+	}
+	if r := records[0]; string(r.Key) != "a" || string(r.Value) != "1" || r.Seq != 7 || r.Deleted {
+		t.Errorf("record 0 = %+v", r)
+	}
+	if r := records[1]; string(r.Key) != "b" || r.Seq != 8 || !r.Deleted {
+		t.Errorf("record 1 = %+v", r)
+	}
+}
+
+// writeLevelDB applies puts, or deletes where the value is empty, to a new
+// store in dir, compacting the store into a table first if compact is set.
+func writeLevelDB(t *testing.T, dir string, compact bool, ops ...[2]string) {
+	t.Helper()
+	db, err := leveldb.OpenFile(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	for i, op := range ops {
+		if compact && i == len(ops)-1 {
+			if err := db.CompactRange(util.Range{}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if op[1] == "" {
+			err = db.Delete([]byte(op[0]), nil)
+		} else {
+			err = db.Put([]byte(op[0]), []byte(op[1]), nil)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestReadRawLevelDB(t *testing.T) {
+	ops := [][2]string{{"a", "1"}, {"b", "1"}, {"a", "2"}, {"b", ""}, {"c", "1"}, {"c", "2"}}
+
+	tests := []struct {
+		name    string
+		compact bool
+		want    []string
+	}{
+		{"journal", false, []string{
+			"a=2 live",
+			"a=1 overwritten",
+			"b= deleted",
+			"b=1 deleted",
+			"c=2 live",
+			"c=1 overwritten",
+		}},
+		// Compacting all but the last write into a table drops the versions
+		// it replaces, other than c's, which is still in the table.
+		{"table and journal", true, []string{
+			"a=2 live",
+			"c=2 live",
+			"c=1 overwritten",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeLevelDB(t, dir, tt.compact, ops...)
+			// A damaged table is skipped rather than failing the read.
+			if err := os.WriteFile(filepath.Join(dir, "999999.ldb"), []byte("garbage"), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			records, err := ReadRawLevelDB(dir)
+			if err != nil {
+				t.Fatalf("ReadRawLevelDB: %v", err)
+			}
+			var got []string
+			for _, r := range records {
+				got = append(got, fmt.Sprintf("%s=%s %s", r.Key, r.Value, r.State))
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
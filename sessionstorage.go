@@ -2,23 +2,17 @@ package chromedb
 
 import (
 	"bytes"
-	"encoding/base64"
+	"encoding/binary"
 	"encoding/json"
 	"fmt"
-	"io"
-	"net/http"
-	"os"
-	"path/filepath"
-	"regexp"
+	"io/fs"
 	"strconv"
-	"strings"
+	"unicode/utf16"
 
 	// "time"
 
-	"github.com/h2non/filetype"
 	"github.com/syndtr/goleveldb/leveldb"
-	"github.com/syndtr/goleveldb/leveldb/opt"
-	"github.com/syndtr/goleveldb/leveldb/storage"
+	"github.com/syndtr/goleveldb/leveldb/util"
 	"golang.org/x/text/encoding/unicode"
 )
 
@@ -32,6 +26,11 @@ type SessionStorageRecord struct {
 	MIME        string          `json:"mime"`
 	Conversions []string        `json:"conversions"`
 	JsonType    string          `json:"json_type"`
+	Seq         uint64          `json:"seq,omitempty"`
+	State       string          `json:"state,omitempty"`
+	DecodeError string          `json:"decode_error,omitempty"`
+	RawKey      []byte          `json:"raw_key,omitempty"`
+	RawValue    []byte          `json:"raw_value,omitempty"`
 }
 
 type SessionStoreDb struct {
@@ -49,222 +48,183 @@ func decodeUTF16LE(raw []byte) (string, error) {
 }
 
 func LoadSessionStorage(dir string) (*SessionStoreDb, error) {
-	db, err := leveldb.OpenFile(dir, &opt.Options{
-		ReadOnly: true,
-	})
+	return LoadSessionStorageFS(osFS(dir))
+}
 
+// LoadSessionStorageFS is LoadSessionStorage for a Session Storage directory
+// in fsys.
+func LoadSessionStorageFS(fsys fs.FS, dir string) (*SessionStoreDb, error) {
+	db, err := openLevelDB(fsys, dir)
 	if err != nil {
-		srcDir := dir
-		memStorage := storage.NewMemStorage()
+		return nil, err
+	}
+	defer db.Close()
 
-		err := filepath.Walk(srcDir, func(path string, info os.FileInfo, err error) error {
-			if err != nil {
-				return err
-			}
+	ssd := &SessionStoreDb{
+		ldb: db.DB,
+	}
 
-			relPath, err := filepath.Rel(srcDir, path)
-			if err != nil {
-				return err
-			}
+	iter := db.NewIterator(nil, nil)
+	defer iter.Release()
 
-			if info.IsDir() {
-				return nil
-			}
+	for iter.Next() {
+		key := iter.Key()
+		value := iter.Value()
 
-			srcFile, err := os.Open(path)
-			if err != nil {
-				return err
-			}
-			defer srcFile.Close()
+		record, ok, err := sessionStorageRecordFromEntry(key, value)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			ssd.Records = append(ssd.Records, record)
+		}
+	}
 
-			data, err := io.ReadAll(srcFile)
-			if err != nil {
-				return err
-			}
+	return ssd, nil
+}
 
-			var num int64
-			num = 0
-			re := regexp.MustCompile(`\d+`)
-			match := re.FindString(relPath)
-			if match != "" {
-				matchInt, err := strconv.Atoi(match)
-				if err == nil {
-					num = int64(matchInt)
-				}
-			}
+// sessionStorageRecordFromEntry decodes a "map-<id>-<key>" entry. It returns
+// false for keys that aren't map entries. A nil value, as for a deleted
+// entry, leaves the value and charset empty.
+func sessionStorageRecordFromEntry(key, value []byte) (SessionStorageRecord, bool, error) {
+	record := SessionStorageRecord{}
 
-			var fileType storage.FileType
-			switch {
-			case strings.HasSuffix(relPath, ".ldb"):
-				fileType = storage.TypeTable
-			case strings.HasPrefix(relPath, "MANIFEST"):
-				fileType = storage.TypeManifest
-			case strings.HasSuffix(relPath, ".log"):
-				fileType = storage.TypeJournal
-			case strings.HasSuffix(relPath, ".tmp"):
-				fileType = storage.TypeTemp
-			default:
-				return nil
-			}
+	if !bytes.HasPrefix(key, []byte("map-")) {
+		return record, false, nil
+	}
+	parts := bytes.SplitN(bytes.TrimPrefix(key, []byte("map-")), []byte("-"), 2)
+	if len(parts) != 2 {
+		return record, false, nil
+	}
 
-			fd := storage.FileDesc{Type: fileType, Num: num}
-			if fd.Type == storage.TypeManifest {
-				err = memStorage.SetMeta(fd)
-				if err != nil {
-					return err
-				}
-			}
-			writer, err := memStorage.Create(fd)
-			if err != nil {
-				return err
-			}
+	mapID, err := strconv.Atoi(string(parts[0]))
+	if err != nil {
+		return record, false, fmt.Errorf("failed to decode map ID: %w", err)
+	}
+	record.MapID = mapID
+	record.Key = string(parts[1])
 
-			_, err = writer.Write(data)
-			if err != nil {
-				writer.Close()
-				return err
-			}
+	if value == nil {
+		return record, true, nil
+	}
+	val, err := decodeUTF16LE(value)
+	if err != nil {
+		return record, false, fmt.Errorf("failed to decode value: %w", err)
+	}
+	record.Decoded = val
+	record.Charset = "UTF-16-LE"
 
-			err = writer.Close()
-			if err != nil {
-				return err
-			}
+	return record, true, nil
+}
 
-			return nil
-		})
+// LoadSessionStorageRaw is the session storage counterpart of
+// LoadLocalStorageRaw.
+func LoadSessionStorageRaw(dir string) (*SessionStoreDb, error) {
+	return LoadSessionStorageRawFS(osFS(dir))
+}
 
-		if err != nil {
-			fmt.Println("Error copying directory:", err)
-			return nil, err
-		}
+// LoadSessionStorageRawFS is LoadSessionStorageRaw for a Session Storage
+// directory in fsys.
+func LoadSessionStorageRawFS(fsys fs.FS, dir string) (*SessionStoreDb, error) {
+	raw, err := ReadRawLevelDBFS(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
 
-		db, err = leveldb.Open(memStorage, nil)
+	ssd := &SessionStoreDb{}
+	for _, r := range raw {
+		record, ok, err := sessionStorageRecordFromEntry(r.Key, r.Value)
+		if err == nil && !ok {
+			if !bytes.HasPrefix(r.Key, []byte("map-")) {
+				continue
+			}
+			err = fmt.Errorf("malformed map entry key")
+		}
 		if err != nil {
-			fmt.Println("Error opening LevelDB:", err)
-			return nil, err
+			record.DecodeError = err.Error()
+			record.RawKey, record.RawValue = r.Key, r.Value
 		}
+		record.Seq = r.Seq
+		record.State = r.State
+		ssd.Records = append(ssd.Records, record)
 	}
-	defer db.Close()
 
-	ssd := &SessionStoreDb{
-		ldb: db,
-	}
-
-	iter := db.NewIterator(nil, nil)
-	defer iter.Release()
-
-	for iter.Next() {
-		key := iter.Key()
-		value := iter.Value()
+	return ssd, nil
+}
 
-		if bytes.HasPrefix(key, []byte("map-")) {
-			parts := bytes.SplitN(bytes.TrimPrefix(key, []byte("map-")), []byte("-"), 2)
-			if len(parts) != 2 {
-				continue
-			}
+// encodeUTF16LE is the inverse of decodeUTF16LE.
+func encodeUTF16LE(s string) []byte {
+	b := []byte{}
+	for _, c := range utf16.Encode([]rune(s)) {
+		b = binary.LittleEndian.AppendUint16(b, c)
+	}
+	return b
+}
 
-			mapID, err := strconv.Atoi(string(parts[0]))
-			if err != nil {
-				return nil, fmt.Errorf("failed to decode map ID: %w", err)
-			}
+// updateSessionStorage applies a change to an existing session storage map.
+// Keys are stored as UTF-8 and values as UTF-16-LE, which is what
+// LoadSessionStorage expects.
+func updateSessionStorage(dir string, mapID int, key string, value *string) error {
+	db, err := openLevelDBForWrite(dir)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
 
-			keyStr := string(parts[1])
-			val, err := decodeUTF16LE(value)
-			if err != nil {
-				return nil, fmt.Errorf("failed to decode value: %w", err)
-			}
+	prefix := []byte(fmt.Sprintf("map-%d-", mapID))
+	entryKey := append(append([]byte{}, prefix...), key...)
 
-			record := SessionStorageRecord{
-				MapID: mapID,
-				Key:   keyStr,
-				// Value:   val,
-				Decoded: val,
-				Charset: "UTF-16-LE",
-			}
+	// Only touch maps that a namespace refers to or that already hold
+	// entries, so a typo doesn't create an orphaned map.
+	known := false
+	iter := db.NewIterator(util.BytesPrefix([]byte("namespace-")), nil)
+	for iter.Next() {
+		if string(iter.Value()) == strconv.Itoa(mapID) {
+			known = true
+			break
+		}
+	}
+	iter.Release()
+	if !known {
+		iter = db.NewIterator(util.BytesPrefix(prefix), nil)
+		known = iter.Next()
+		iter.Release()
+	}
+	if !known {
+		return fmt.Errorf("no session storage map %d", mapID)
+	}
 
-			ssd.Records = append(ssd.Records, record)
+	if value == nil {
+		if _, err := db.Get(entryKey, nil); err != nil {
+			return fmt.Errorf("no session storage entry %q in map %d", key, mapID)
 		}
+		return db.Delete(entryKey, nil)
 	}
+	return db.Put(entryKey, encodeUTF16LE(*value), nil)
+}
 
-	return ssd, nil
+// SetSessionStorage sets a value in a session storage map in the store at
+// dir. It refuses to run while the browser has the store open.
+func SetSessionStorage(dir string, mapID int, key, value string) error {
+	return updateSessionStorage(dir, mapID, key, &value)
 }
 
-func SessionStorageRecordToJson(r SessionStorageRecord) (string, error) {
-	mime := "application/octet-stream"
-	xfer := []string{}
-	b := []byte(r.Decoded)
-	validJson := json.Valid(b)
-	jsonType := ""
-	out := []byte{}
-
-	if validJson {
-		// Use a custom decoder to handle large numbers as strings
-		d := json.NewDecoder(bytes.NewReader(b))
-		d.UseNumber() // This makes the decoder use json.Number for numbers
-		var v interface{}
-		err := d.Decode(&v)
-		if err != nil {
-			return "", fmt.Errorf("failed to unmarshal supposedly valid JSON: %w", err)
-		}
+// DeleteSessionStorage removes a value from a session storage map in the
+// store at dir. It refuses to run while the browser has the store open.
+func DeleteSessionStorage(dir string, mapID int, key string) error {
+	return updateSessionStorage(dir, mapID, key, nil)
+}
 
-		mime = "application/json"
-
-		switch val := v.(type) {
-		case json.Number:
-			jsonType = "number"
-			// Check if the number might be too large for float64
-			_, err := val.Float64()
-			if err != nil {
-				// If it can't be converted to float64, treat it as a string in the output
-				strVal := fmt.Sprintf("\"%s\"", val.String())
-				out = []byte(strVal)
-			} else {
-				out = b
-			}
-		case string:
-			jsonType = "string"
-			out = b
-		case bool:
-			jsonType = "boolean"
-			out = b
-		case []interface{}:
-			jsonType = "array"
-			out = b
-		case map[string]interface{}:
-			jsonType = "object"
-			out = b
-		case nil:
-			jsonType = "null"
-			out = b
-		default:
-			jsonType = ""
-			out = b
-		}
-	} else {
-		quoted := strconv.Quote(r.Decoded)
-		if json.Valid([]byte(quoted)) {
-			out = []byte(quoted)
-			mime = "text/plain"
-			xfer = append(xfer, "strconv.Quote")
-			mime = http.DetectContentType(b)
-			mime = strings.Split(mime, ";")[0]
-		} else {
-			b64 := base64.StdEncoding.EncodeToString(b)
-			xfer = append(xfer, "base64.StdEncoding.EncodeToString")
-			out = []byte(strconv.Quote(b64))
-			xfer = append(xfer, "strconv.Quote")
-
-			magic, _ := filetype.Match(b)
-			if magic != filetype.Unknown {
-				mime = magic.MIME.Value
-			}
-		}
+func SessionStorageRecordToJson(r SessionStorageRecord) (string, error) {
+	v, err := classifyValue(r.Decoded)
+	if err != nil {
+		return "", err
 	}
 
-	r.MIME = mime
-	r.Conversions = xfer
-	r.Value = out
-	r.JsonType = jsonType
+	r.MIME = v.MIME
+	r.Conversions = v.Conversions
+	r.Value = v.Value
+	r.JsonType = v.JsonType
 	recordJson, err := json.Marshal(r)
 	if err != nil {
 		return "", fmt.Errorf("failed to marshal record to JSON: %w", err)
@@ -274,5 +234,7 @@ func SessionStorageRecordToJson(r SessionStorageRecord) (string, error) {
 }
 
 func (ssd *SessionStoreDb) Close() {
-	ssd.ldb.Close()
+	if ssd.ldb != nil {
+		ssd.ldb.Close()
+	}
 }
@@ -0,0 +1,126 @@
+package chromedb
+
+import (
+	"io/fs"
+	"time"
+)
+
+type Shortcut struct {
+	ID             string    `json:"id"`
+	Text           string    `json:"text"`
+	FillIntoEdit   string    `json:"fill_into_edit"`
+	URL            string    `json:"url"`
+	Contents       string    `json:"contents"`
+	Description    string    `json:"description"`
+	Transition     string    `json:"transition"`
+	Type           int64     `json:"type"`
+	Keyword        string    `json:"keyword,omitempty"`
+	LastAccessTime time.Time `json:"last_access_time"`
+	NumberOfHits   int64     `json:"number_of_hits"`
+}
+
+type PredictorEntry struct {
+	UserText       string `json:"user_text"`
+	URL            string `json:"url"`
+	NumberOfHits   int64  `json:"number_of_hits"`
+	NumberOfMisses int64  `json:"number_of_misses"`
+}
+
+// GetShortcuts reads the omnibox shortcuts from a profile's Shortcuts
+// database: the text the user typed and the suggestion they picked for it.
+func GetShortcuts(shortcutsPath string) ([]Shortcut, error) {
+	return GetShortcutsFS(osFS(shortcutsPath))
+}
+
+// GetShortcutsFS is GetShortcuts for a Shortcuts database in fsys.
+func GetShortcutsFS(fsys fs.FS, name string) ([]Shortcut, error) {
+	db, err := openSQLite(fsys, name)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	cols, err := sqliteColumns(db, "omni_box_shortcuts")
+	if err != nil || cols == nil {
+		return nil, err
+	}
+
+	query := "SELECT " + selectColumns(cols, "",
+		[2]string{"id", "''"},
+		[2]string{"text", "''"},
+		[2]string{"fill_into_edit", "''"},
+		[2]string{"url", "''"},
+		[2]string{"contents", "''"},
+		[2]string{"description", "''"},
+		[2]string{"transition", "0"},
+		[2]string{"type", "0"},
+		[2]string{"keyword", "''"},
+		[2]string{"last_access_time", "0"},
+		[2]string{"number_of_hits", "0"},
+	) + " FROM omni_box_shortcuts ORDER BY last_access_time"
+	rows, err := db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var shortcuts []Shortcut
+	for rows.Next() {
+		var (
+			s                  Shortcut
+			transition, access int64
+		)
+		err := rows.Scan(&s.ID, &s.Text, &s.FillIntoEdit, &s.URL, &s.Contents, &s.Description,
+			&transition, &s.Type, &s.Keyword, &access, &s.NumberOfHits)
+		if err != nil {
+			return nil, err
+		}
+		s.Transition, _ = historyTransition(transition)
+		s.LastAccessTime = chromeTime(access)
+		shortcuts = append(shortcuts, s)
+	}
+	return shortcuts, rows.Err()
+}
+
+// GetNetworkActionPredictor reads the Network Action Predictor database,
+// which counts how often each typed prefix led the user to a URL.
+func GetNetworkActionPredictor(predictorPath string) ([]PredictorEntry, error) {
+	return GetNetworkActionPredictorFS(osFS(predictorPath))
+}
+
+// GetNetworkActionPredictorFS is GetNetworkActionPredictor for a Network
+// Action Predictor database in fsys.
+func GetNetworkActionPredictorFS(fsys fs.FS, name string) ([]PredictorEntry, error) {
+	db, err := openSQLite(fsys, name)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	cols, err := sqliteColumns(db, "network_action_predictor")
+	if err != nil || cols == nil {
+		return nil, err
+	}
+
+	query := "SELECT " + selectColumns(cols, "",
+		[2]string{"user_text", "''"},
+		[2]string{"url", "''"},
+		[2]string{"number_of_hits", "0"},
+		[2]string{"number_of_misses", "0"},
+	) + " FROM network_action_predictor ORDER BY url, user_text"
+	rows, err := db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []PredictorEntry
+	for rows.Next() {
+		var e PredictorEntry
+		if err := rows.Scan(&e.UserText, &e.URL, &e.NumberOfHits, &e.NumberOfMisses); err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
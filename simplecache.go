@@ -0,0 +1,137 @@
+package chromedb
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const (
+	simpleCacheInitialMagic = 0xfcfb6d1ba7725c30
+	simpleCacheFinalMagic   = 0xf4fa6f45970d41d8
+	simpleCacheHeaderSize   = 24
+	simpleCacheEOFSize      = 24
+
+	simpleCacheFlagHasKeySHA256 = 2
+)
+
+var simpleCacheEntryName = regexp.MustCompile(`^[0-9a-f]{16}_0$`)
+
+// simpleCacheEntry is one entry of a disk cache using the simple backend. An
+// entry's file holds its key and its first two streams: stream 0 with the
+// response metadata and stream 1 with the body.
+type simpleCacheEntry struct {
+	File    string
+	Key     string
+	Stream0 []byte
+	Stream1 []byte
+}
+
+// readSimpleCacheEntry parses a simple cache entry file. The file is laid
+// out as a header, the key, stream 1, an end-of-stream record, stream 0, an
+// optional SHA-256 of the key and a final end-of-stream record, so the
+// streams are located by working back from the end.
+func readSimpleCacheEntry(fsys fs.FS, name string) (*simpleCacheEntry, error) {
+	data, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < simpleCacheHeaderSize+2*simpleCacheEOFSize {
+		return nil, fmt.Errorf("simple cache entry too short")
+	}
+	if binary.LittleEndian.Uint64(data) != simpleCacheInitialMagic {
+		return nil, fmt.Errorf("bad simple cache entry magic")
+	}
+	keyLen := int(binary.LittleEndian.Uint32(data[12:]))
+	keyEnd := simpleCacheHeaderSize + keyLen
+	if keyEnd > len(data) {
+		return nil, fmt.Errorf("simple cache key out of range")
+	}
+	entry := &simpleCacheEntry{
+		File: path.Base(name),
+		Key:  string(data[simpleCacheHeaderSize:keyEnd]),
+	}
+
+	eof0 := len(data) - simpleCacheEOFSize
+	flags, size0, err := simpleCacheEOF(data, eof0)
+	if err != nil {
+		return nil, err
+	}
+	stream0End := eof0
+	if flags&simpleCacheFlagHasKeySHA256 != 0 {
+		stream0End -= 32
+	}
+	stream0Start := stream0End - size0
+
+	eof1 := stream0Start - simpleCacheEOFSize
+	if stream0Start < keyEnd || eof1 < keyEnd {
+		return nil, fmt.Errorf("simple cache stream 0 out of range")
+	}
+	_, size1, err := simpleCacheEOF(data, eof1)
+	if err != nil {
+		return nil, err
+	}
+	if eof1-size1 < keyEnd {
+		return nil, fmt.Errorf("simple cache stream 1 out of range")
+	}
+
+	entry.Stream0 = data[stream0Start:stream0End]
+	entry.Stream1 = data[eof1-size1 : eof1]
+	return entry, nil
+}
+
+// simpleCacheEOF reads the flags and stream size from the end-of-stream
+// record at off.
+func simpleCacheEOF(data []byte, off int) (uint32, int, error) {
+	if off < 0 || off+simpleCacheEOFSize > len(data) {
+		return 0, 0, fmt.Errorf("end-of-stream record out of range")
+	}
+	if binary.LittleEndian.Uint64(data[off:]) != simpleCacheFinalMagic {
+		return 0, 0, fmt.Errorf("bad end-of-stream magic")
+	}
+	flags := binary.LittleEndian.Uint32(data[off+8:])
+	size := int(binary.LittleEndian.Uint32(data[off+16:]))
+	return flags, size, nil
+}
+
+// simpleCacheKeyURL returns the URL from a cache key. HTTP cache keys may
+// be prefixed with the network isolation key, e.g.
+// "1/0/_dk_https://a.com https://a.com https://a.com/x", but always end with
+// the URL.
+func simpleCacheKeyURL(key string) string {
+	if i := strings.LastIndexByte(key, ' '); i >= 0 {
+		return key[i+1:]
+	}
+	return key
+}
+
+// readSimpleCacheDir parses every entry file in a simple cache directory,
+// skipping files that aren't valid entries.
+func readSimpleCacheDir(fsys fs.FS, dir string) ([]simpleCacheEntry, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, e := range entries {
+		if e.Type().IsRegular() && simpleCacheEntryName.MatchString(e.Name()) {
+			names = append(names, e.Name())
+		}
+	}
+	sort.Strings(names)
+
+	var cache []simpleCacheEntry
+	for _, name := range names {
+		entry, err := readSimpleCacheEntry(fsys, path.Join(dir, name))
+		if err != nil {
+			continue
+		}
+		cache = append(cache, *entry)
+	}
+	return cache, nil
+}
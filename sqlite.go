@@ -0,0 +1,149 @@
+package chromedb
+
+import (
+	"database/sql"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// sqliteDB is a profile database opened from a temporary copy, which is
+// removed when the database is closed.
+type sqliteDB struct {
+	*sql.DB
+	tmp string
+}
+
+func (db *sqliteDB) Close() error {
+	err := db.DB.Close()
+	os.RemoveAll(db.tmp)
+	return err
+}
+
+// openSQLite opens one of the profile's SQLite databases. A running browser
+// keeps its databases locked, and SQLite can only open files on disk, so the
+// database and its journal files are copied to a temporary directory and
+// read from there. The copy also means a missing database is reported
+// instead of silently created.
+func openSQLite(fsys fs.FS, name string) (*sqliteDB, error) {
+	tmp, err := os.MkdirTemp("", "chromedb-sqlite-")
+	if err != nil {
+		return nil, err
+	}
+
+	dst := filepath.Join(tmp, path.Base(name))
+	if err := copyFileFS(fsys, name, dst); err != nil {
+		os.RemoveAll(tmp)
+		return nil, err
+	}
+	for _, suffix := range []string{"-wal", "-journal"} {
+		if _, err := fs.Stat(fsys, name+suffix); err == nil {
+			if err := copyFileFS(fsys, name+suffix, dst+suffix); err != nil {
+				os.RemoveAll(tmp)
+				return nil, err
+			}
+		}
+	}
+
+	db, err := sql.Open("sqlite3", dst)
+	if err != nil {
+		os.RemoveAll(tmp)
+		return nil, err
+	}
+	return &sqliteDB{DB: db, tmp: tmp}, nil
+}
+
+// quoteSQLIdentifier quotes a table or column name for SQLite, doubling any
+// quotes within it.
+func quoteSQLIdentifier(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// sqliteColumns returns the columns of a table, or nil if it doesn't exist.
+// Chromium's schemas drift between versions, so readers use this to select
+// only the columns a given profile has.
+func sqliteColumns(db *sqliteDB, table string) (map[string]bool, error) {
+	rows, err := db.Query("PRAGMA table_info(" + quoteSQLIdentifier(table) + ")")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var cols map[string]bool
+	for rows.Next() {
+		var (
+			cid        int
+			name, typ  string
+			notNull    int
+			defaultVal interface{}
+			pk         int
+		)
+		if err := rows.Scan(&cid, &name, &typ, &notNull, &defaultVal, &pk); err != nil {
+			return nil, err
+		}
+		if cols == nil {
+			cols = map[string]bool{}
+		}
+		cols[name] = true
+	}
+	return cols, rows.Err()
+}
+
+// selectColumns builds a select list of the wanted columns, substituting a
+// default for any the table lacks, or any NULL value, so scans keep a fixed
+// shape.
+func selectColumns(cols map[string]bool, prefix string, wanted ...[2]string) string {
+	list := []string{}
+	for _, w := range wanted {
+		name, def := w[0], w[1]
+		if cols[name] {
+			list = append(list, fmt.Sprintf("COALESCE(%s%s, %s)", prefix, name, def))
+		} else {
+			list = append(list, def)
+		}
+	}
+	return strings.Join(list, ", ")
+}
+
+// chromeTime converts a Chromium timestamp, treating 0 as unset.
+func chromeTime(microseconds int64) time.Time {
+	if microseconds == 0 {
+		return time.Time{}
+	}
+	t, _ := fromChromeTimestamp(microseconds)
+	return t.UTC()
+}
+
+// unixTime converts seconds since the Unix epoch, which some tables use
+// instead of Chromium timestamps, treating 0 as unset.
+func unixTime(seconds int64) time.Time {
+	if seconds == 0 {
+		return time.Time{}
+	}
+	return time.Unix(seconds, 0).UTC()
+}
+
+// unixTimeFloat converts fractional seconds since the Unix epoch, treating 0
+// as unset.
+func unixTimeFloat(seconds float64) time.Time {
+	if seconds == 0 {
+		return time.Time{}
+	}
+	sec := int64(seconds)
+	return time.Unix(sec, int64((seconds-float64(sec))*1e9)).UTC()
+}
+
+// unixTimeMilli converts milliseconds since the Unix epoch, as sync stores
+// them, treating 0 as unset.
+func unixTimeMilli(ms int64) time.Time {
+	if ms == 0 {
+		return time.Time{}
+	}
+	return time.UnixMilli(ms).UTC()
+}
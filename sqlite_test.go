@@ -0,0 +1,20 @@
+package chromedb
+
+import "testing"
+
+func TestQuoteSQLIdentifier(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"cookies", `"cookies"`},
+		{`we"ird`, `"we""ird"`},
+		{"tab\there", "\"tab\there\""},
+		{"", `""`},
+	}
+	for _, tt := range tests {
+		if got := quoteSQLIdentifier(tt.name); got != tt.want {
+			t.Errorf("quoteSQLIdentifier(%q) = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
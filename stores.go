@@ -0,0 +1,150 @@
+package chromedb
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// Store names one of the databases or files in a profile.
+type Store string
+
+const (
+	StoreCookies                Store = "Cookies"
+	StoreExtensionCookies       Store = "Extension Cookies"
+	StoreLocalStorage           Store = "Local Storage"
+	StoreSessionStorage         Store = "Session Storage"
+	StoreIndexedDB              Store = "IndexedDB"
+	StoreHistory                Store = "History"
+	StoreLoginData              Store = "Login Data"
+	StoreLoginDataForAccount    Store = "Login Data For Account"
+	StoreWebData                Store = "Web Data"
+	StoreBookmarks              Store = "Bookmarks"
+	StoreServiceWorker          Store = "Service Worker"
+	StoreHTTPCache              Store = "Cache"
+	StoreWebSQL                 Store = "databases"
+	StoreTransportSecurity      Store = "TransportSecurity"
+	StoreNetworkState           Store = "Network Persistent State"
+	StoreShortcuts              Store = "Shortcuts"
+	StoreTopSites               Store = "Top Sites"
+	StoreNetworkActionPredictor Store = "Network Action Predictor"
+	StoreFavicons               Store = "Favicons"
+	StoreVisitedLinks           Store = "Visited Links"
+	StoreSyncData               Store = "Sync Data"
+)
+
+// storeLocations lists every path each store has had within a profile,
+// newest layout first. Chromium 96 moved the network service's files into
+// Network, and the HTTP cache gained a Cache_Data subdirectory in 87.
+var storeLocations = map[Store][]string{
+	StoreCookies:                {"Network/Cookies", "Cookies"},
+	StoreExtensionCookies:       {"Extension Cookies"},
+	StoreLocalStorage:           {"Local Storage/leveldb"},
+	StoreSessionStorage:         {"Session Storage"},
+	StoreIndexedDB:              {"IndexedDB"},
+	StoreHistory:                {"History"},
+	StoreLoginData:              {"Login Data"},
+	StoreLoginDataForAccount:    {"Login Data For Account"},
+	StoreWebData:                {"Web Data"},
+	StoreBookmarks:              {"Bookmarks"},
+	StoreServiceWorker:          {"Service Worker"},
+	StoreHTTPCache:              {"Cache/Cache_Data", "Cache"},
+	StoreWebSQL:                 {"databases"},
+	StoreTransportSecurity:      {"Network/TransportSecurity", "TransportSecurity"},
+	StoreNetworkState:           {"Network/Network Persistent State", "Network Persistent State"},
+	StoreShortcuts:              {"Shortcuts"},
+	StoreTopSites:               {"Top Sites"},
+	StoreNetworkActionPredictor: {"Network Action Predictor"},
+	StoreFavicons:               {"Favicons"},
+	StoreVisitedLinks:           {"Visited Links"},
+	StoreSyncData:               {"Sync Data/LevelDB"},
+}
+
+// cacheStores are the stores the browser keeps in the OS cache directory
+// rather than in the profile on some platforms; see profileCacheDir.
+var cacheStores = map[Store]bool{
+	StoreHTTPCache: true,
+}
+
+// profileCacheDir returns where the browser keeps the caches of the profile
+// at profilePath, as Chromium's GetUserCacheDirectory does: a profile under
+// the user's config directory has them at the same path under the cache
+// directory, such as ~/.cache/google-chrome/Default on Linux or
+// ~/Library/Caches/Google/Chrome/Default on macOS. Other profiles, and all
+// of them on Windows, keep their caches inside the profile.
+func profileCacheDir(profilePath string) (string, bool) {
+	if runtime.GOOS == "windows" {
+		return "", false
+	}
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", false
+	}
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", false
+	}
+	abs, err := filepath.Abs(profilePath)
+	if err != nil {
+		return "", false
+	}
+	rel, err := filepath.Rel(configDir, abs)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	return filepath.Join(cacheDir, rel), true
+}
+
+// ResolveStore returns the path of a store within the profile at
+// profilePath, probing each location it has had, then for the HTTP cache
+// the same locations in the profile's cache directory. The error wraps
+// fs.ErrNotExist if the store isn't in any of them.
+func ResolveStore(profilePath string, store Store) (string, error) {
+	dir, loc, err := resolveStoreLocation(profilePath, store)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, filepath.FromSlash(loc)), nil
+}
+
+// resolveStoreLocation is ResolveStore returning the directory the store
+// was found in, the profile's or its cache directory, and its location
+// within it.
+func resolveStoreLocation(profilePath string, store Store) (dir, loc string, err error) {
+	locations, ok := storeLocations[store]
+	if !ok {
+		return "", "", fmt.Errorf("unknown store %q", store)
+	}
+	dirs := []string{profilePath}
+	if cacheDir, ok := profileCacheDir(profilePath); ok && cacheStores[store] {
+		dirs = append(dirs, cacheDir)
+	}
+	for _, dir := range dirs {
+		for _, loc := range locations {
+			if _, err := os.Stat(filepath.Join(dir, filepath.FromSlash(loc))); err == nil {
+				return dir, loc, nil
+			}
+		}
+	}
+	return "", "", fmt.Errorf("%s not found in %s: %w", store, strings.Join(dirs, " or "), fs.ErrNotExist)
+}
+
+// ResolveStoreFS is ResolveStore for a profile directory in fsys, returning
+// the store's name in fsys.
+func ResolveStoreFS(fsys fs.FS, profileDir string, store Store) (string, error) {
+	locations, ok := storeLocations[store]
+	if !ok {
+		return "", fmt.Errorf("unknown store %q", store)
+	}
+	for _, loc := range locations {
+		name := path.Join(profileDir, loc)
+		if _, err := fs.Stat(fsys, name); err == nil {
+			return name, nil
+		}
+	}
+	return "", fmt.Errorf("%s not found in %s: %w", store, profileDir, fs.ErrNotExist)
+}
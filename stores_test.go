@@ -0,0 +1,96 @@
+package chromedb
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestResolveStoreCacheDir(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Windows keeps the cache in the profile")
+	}
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, ".config"))
+	t.Setenv("XDG_CACHE_HOME", filepath.Join(home, ".cache"))
+	configDir, _ := os.UserConfigDir()
+	cacheDir, _ := os.UserCacheDir()
+
+	profile := filepath.Join(configDir, "google-chrome", "Default")
+	external := filepath.Join(cacheDir, "google-chrome", "Default", "Cache", "Cache_Data")
+	for _, dir := range []string{profile, external} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got, err := ResolveStore(profile, StoreHTTPCache)
+	if err != nil || got != external {
+		t.Errorf("ResolveStore(HTTPCache) = %q, %v; want %q", got, err, external)
+	}
+
+	// Only the caches live outside the profile.
+	if err := os.WriteFile(filepath.Join(cacheDir, "google-chrome", "Default", "Cookies"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ResolveStore(profile, StoreCookies); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("ResolveStore(Cookies) error = %v, want fs.ErrNotExist", err)
+	}
+
+	// A cache in the profile wins.
+	internal := filepath.Join(profile, "Cache", "Cache_Data")
+	if err := os.MkdirAll(internal, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := ResolveStore(profile, StoreHTTPCache); err != nil || got != internal {
+		t.Errorf("ResolveStore(HTTPCache) = %q, %v; want %q", got, err, internal)
+	}
+
+	// Profiles outside the config directory have no separate cache.
+	other := t.TempDir()
+	if _, ok := profileCacheDir(other); ok {
+		t.Errorf("profileCacheDir(%q) found a cache directory", other)
+	}
+}
+
+func TestProfileHTTPCacheDir(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Windows keeps the cache in the profile")
+	}
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, ".config"))
+	t.Setenv("XDG_CACHE_HOME", filepath.Join(home, ".cache"))
+	configDir, _ := os.UserConfigDir()
+	cacheDir, _ := os.UserCacheDir()
+
+	profile := filepath.Join(configDir, "chromium", "Profile 1")
+	external := filepath.Join(cacheDir, "chromium", "Profile 1", "Cache", "Cache_Data")
+	for _, dir := range []string{profile, external} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var reported string
+	p, err := OpenProfile(profile, &ProfileOptions{OnStore: func(store Store, path string) {
+		reported = path
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+	if !p.Has(StoreHTTPCache) {
+		t.Fatal("Has(HTTPCache) = false")
+	}
+	if got, err := p.Resolve(StoreHTTPCache); err != nil || got != external {
+		t.Errorf("Resolve(HTTPCache) = %q, %v; want %q", got, err, external)
+	}
+	if reported != external {
+		t.Errorf("OnStore reported %q, want %q", reported, external)
+	}
+}
@@ -0,0 +1,246 @@
+package chromedb
+
+import (
+	"io/fs"
+	"sort"
+	"strings"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Fields of the EntitySpecifics message, which wraps the specifics of each
+// data type in a field of its own.
+var syncSpecificsTypes = map[string]string{
+	"31729":  "autofill",
+	"32904":  "bookmark",
+	"37702":  "preference",
+	"40781":  "typed_url",
+	"41210":  "theme",
+	"45873":  "password",
+	"47745":  "nigori",
+	"48119":  "extension",
+	"48364":  "app",
+	"50119":  "session",
+	"63951":  "autofill_profile",
+	"88610":  "search_engine",
+	"96159":  "extension_setting",
+	"103656": "app_setting",
+	"150251": "history_delete_directive",
+	"154522": "device_info",
+	"163425": "priority_preference",
+	"170540": "dictionary",
+}
+
+// Device types from DeviceInfoSpecifics.device_type.
+var syncDeviceTypes = []string{"unset", "win", "mac", "linux", "cros", "other", "phone", "tablet"}
+
+// Operating systems from DeviceInfoSpecifics.os_type.
+var syncOSTypes = []string{"unspecified", "windows", "mac", "linux", "chromeos_ash", "android", "ios", "chromeos_lacros", "fuchsia"}
+
+type SyncDevice struct {
+	CacheGUID            string    `json:"cache_guid"`
+	ClientName           string    `json:"client_name"`
+	DeviceType           string    `json:"device_type"`
+	OSType               string    `json:"os_type,omitempty"`
+	SyncUserAgent        string    `json:"sync_user_agent"`
+	ChromeVersion        string    `json:"chrome_version"`
+	SigninScopedDeviceID string    `json:"signin_scoped_device_id"`
+	Manufacturer         string    `json:"manufacturer,omitempty"`
+	Model                string    `json:"model,omitempty"`
+	FullHardwareClass    string    `json:"full_hardware_class,omitempty"`
+	LastUpdated          time.Time `json:"last_updated"`
+}
+
+type SyncEntityMetadata struct {
+	ClientTagHash    string    `json:"client_tag_hash"`
+	ServerID         string    `json:"server_id"`
+	IsDeleted        bool      `json:"is_deleted"`
+	SequenceNumber   int64     `json:"sequence_number"`
+	ServerVersion    int64     `json:"server_version"`
+	CreationTime     time.Time `json:"creation_time"`
+	ModificationTime time.Time `json:"modification_time"`
+}
+
+type SyncEntity struct {
+	Type       string              `json:"type"`
+	StorageKey string              `json:"storage_key"`
+	Name       string              `json:"name,omitempty"`
+	Specifics  interface{}         `json:"specifics"`
+	Metadata   *SyncEntityMetadata `json:"metadata,omitempty"`
+}
+
+type SyncData struct {
+	Entities []SyncEntity
+	Devices  []SyncDevice
+}
+
+// LoadSyncData reads the sync engine's local copy of synced entities from
+// dir, a profile's Sync Data/LevelDB directory. Records are keyed
+// "<type>-dt-<storage key>", with the entity's sync metadata under
+// "<type>-md-<storage key>". Device info is decoded field by field; other
+// specifics are decoded without their schemas, keyed by field number.
+func LoadSyncData(dir string) (*SyncData, error) {
+	return LoadSyncDataFS(osFS(dir))
+}
+
+// LoadSyncDataFS is LoadSyncData for a Sync Data/LevelDB directory in fsys.
+func LoadSyncDataFS(fsys fs.FS, dir string) (*SyncData, error) {
+	db, err := openLevelDB(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	sd := &SyncData{}
+	metadata := map[string]*SyncEntityMetadata{}
+	iter := db.NewIterator(nil, nil)
+	for iter.Next() {
+		// Type tags never contain a hyphen, but storage keys may.
+		typ, rest, _ := strings.Cut(string(iter.Key()), "-")
+		kind, storageKey, _ := strings.Cut(rest, "-")
+		if kind == "md" {
+			if md, err := parseSyncEntityMetadata(iter.Value()); err == nil {
+				metadata[typ+"\x00"+storageKey] = md
+			}
+			continue
+		}
+		if kind != "dt" {
+			continue
+		}
+
+		e := SyncEntity{Type: typ, StorageKey: storageKey}
+		if typ == "device_info" {
+			d, err := parseSyncDevice(iter.Value())
+			if err != nil {
+				continue
+			}
+			e.Name = d.ClientName
+			e.Specifics = d
+			sd.Devices = append(sd.Devices, d)
+		} else if e.Name, e.Specifics, err = parseSyncEntityData(iter.Value()); err != nil {
+			continue
+		}
+		sd.Entities = append(sd.Entities, e)
+	}
+	iter.Release()
+	if err := iter.Error(); err != nil {
+		return nil, err
+	}
+
+	for i, e := range sd.Entities {
+		sd.Entities[i].Metadata = metadata[e.Type+"\x00"+e.StorageKey]
+	}
+	sort.SliceStable(sd.Devices, func(i, j int) bool { return sd.Devices[i].LastUpdated.After(sd.Devices[j].LastUpdated) })
+
+	return sd, nil
+}
+
+// parseSyncEntityData decodes a stored entity. Types synced through the
+// generic bridge store a PersistedEntityData, i.e. the entity's name and its
+// EntitySpecifics; the rest store their specifics directly. Specifics with
+// no known type are returned as the whole message, along with any name.
+func parseSyncEntityData(b []byte) (string, interface{}, error) {
+	fields, err := parseProtoFields(b)
+	if err != nil {
+		return "", nil, err
+	}
+
+	var name string
+	var specifics map[string]interface{}
+	for _, f := range fields {
+		switch {
+		case f.Number == 1 && f.Type == protowire.BytesType:
+			name = string(f.Bytes)
+		case f.Number == 2 && f.Type == protowire.BytesType:
+			specifics, _ = protoToMap(f.Bytes)
+		}
+	}
+	if len(specifics) > 0 {
+		// Field numbers of types we don't know are kept under their number.
+		named := map[string]interface{}{}
+		known := false
+		for num, v := range specifics {
+			if typ, ok := syncSpecificsTypes[num]; ok {
+				named[typ] = v
+				known = true
+			} else {
+				named[num] = v
+			}
+		}
+		if known {
+			return name, named, nil
+		}
+	}
+
+	m, err := protoToMap(b)
+	return name, m, err
+}
+
+func parseSyncEntityMetadata(b []byte) (*SyncEntityMetadata, error) {
+	fields, err := parseProtoFields(b)
+	if err != nil {
+		return nil, err
+	}
+
+	md := &SyncEntityMetadata{}
+	for _, f := range fields {
+		switch f.Number {
+		case 1:
+			md.ClientTagHash = string(f.Bytes)
+		case 2:
+			md.ServerID = string(f.Bytes)
+		case 3:
+			md.IsDeleted = f.Uint != 0
+		case 4:
+			md.SequenceNumber = int64(f.Uint)
+		case 6:
+			md.ServerVersion = int64(f.Uint)
+		case 7:
+			md.CreationTime = unixTimeMilli(int64(f.Uint))
+		case 8:
+			md.ModificationTime = unixTimeMilli(int64(f.Uint))
+		}
+	}
+	return md, nil
+}
+
+func parseSyncDevice(b []byte) (SyncDevice, error) {
+	fields, err := parseProtoFields(b)
+	if err != nil {
+		return SyncDevice{}, err
+	}
+
+	d := SyncDevice{DeviceType: "unknown"}
+	for _, f := range fields {
+		switch f.Number {
+		case 1:
+			d.CacheGUID = string(f.Bytes)
+		case 2:
+			d.ClientName = string(f.Bytes)
+		case 3:
+			if f.Uint < uint64(len(syncDeviceTypes)) {
+				d.DeviceType = syncDeviceTypes[f.Uint]
+			}
+		case 4:
+			d.SyncUserAgent = string(f.Bytes)
+		case 5:
+			d.ChromeVersion = string(f.Bytes)
+		case 7:
+			d.SigninScopedDeviceID = string(f.Bytes)
+		case 8:
+			d.LastUpdated = unixTimeMilli(int64(f.Uint))
+		case 12:
+			d.Manufacturer = string(f.Bytes)
+		case 13:
+			d.Model = string(f.Bytes)
+		case 14:
+			d.FullHardwareClass = string(f.Bytes)
+		case 16:
+			if f.Uint < uint64(len(syncOSTypes)) {
+				d.OSType = syncOSTypes[f.Uint]
+			}
+		}
+	}
+	return d, nil
+}
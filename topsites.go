@@ -0,0 +1,66 @@
+package chromedb
+
+import (
+	"io/fs"
+	"strings"
+)
+
+type TopSite struct {
+	URL       string   `json:"url"`
+	Rank      int64    `json:"rank"`
+	Title     string   `json:"title"`
+	Redirects []string `json:"redirects"`
+}
+
+// GetTopSites reads the most visited pages shown on the new tab page from a
+// profile's Top Sites database.
+func GetTopSites(topSitesPath string) ([]TopSite, error) {
+	return GetTopSitesFS(osFS(topSitesPath))
+}
+
+// GetTopSitesFS is GetTopSites for a Top Sites database in fsys.
+func GetTopSitesFS(fsys fs.FS, name string) ([]TopSite, error) {
+	db, err := openSQLite(fsys, name)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	// Older versions kept the sites alongside their thumbnails.
+	table := "top_sites"
+	cols, err := sqliteColumns(db, table)
+	if err == nil && cols == nil {
+		table = "thumbnails"
+		cols, err = sqliteColumns(db, table)
+	}
+	if err != nil || cols == nil {
+		return nil, err
+	}
+
+	query := "SELECT " + selectColumns(cols, "",
+		[2]string{"url", "''"},
+		[2]string{"url_rank", "0"},
+		[2]string{"title", "''"},
+		[2]string{"redirects", "''"},
+	) + " FROM " + table + " ORDER BY url_rank"
+	rows, err := db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sites []TopSite
+	for rows.Next() {
+		var (
+			s         TopSite
+			redirects string
+		)
+		if err := rows.Scan(&s.URL, &s.Rank, &s.Title, &redirects); err != nil {
+			return nil, err
+		}
+		// Redirects are stored as a space-separated chain ending in the URL.
+		s.Redirects = strings.Fields(redirects)
+		sites = append(sites, s)
+	}
+	return sites, rows.Err()
+}
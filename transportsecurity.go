@@ -0,0 +1,165 @@
+package chromedb
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"io/fs"
+	"sort"
+	"strings"
+	"time"
+)
+
+type HSTSEntry struct {
+	Host              string    `json:"host,omitempty"`
+	HostHash          string    `json:"host_hash"`
+	Mode              string    `json:"mode"`
+	IncludeSubdomains bool      `json:"include_subdomains"`
+	Observed          time.Time `json:"observed"`
+	Expiry            time.Time `json:"expiry"`
+}
+
+// ExpectCTEntry is a host's Expect-CT state, which browsers wrote until
+// Expect-CT was removed.
+type ExpectCTEntry struct {
+	HostHash  string    `json:"host_hash"`
+	Enforce   bool      `json:"enforce"`
+	ReportURI string    `json:"report_uri,omitempty"`
+	Observed  time.Time `json:"observed"`
+	Expiry    time.Time `json:"expiry"`
+}
+
+type TransportSecurity struct {
+	Entries  []HSTSEntry
+	ExpectCT []ExpectCTEntry
+	byHash   map[string]HSTSEntry
+}
+
+type transportSecurityEntry struct {
+	Host                 string  `json:"host"`
+	Mode                 string  `json:"mode"`
+	StsIncludeSubdomains bool    `json:"sts_include_subdomains"`
+	StsObserved          float64 `json:"sts_observed"`
+	Expiry               float64 `json:"expiry"`
+}
+
+type transportSecurityExpectCT struct {
+	Host      string  `json:"host"`
+	Observed  float64 `json:"expect_ct_observed"`
+	Expiry    float64 `json:"expect_ct_expiry"`
+	Enforce   bool    `json:"expect_ct_enforce"`
+	ReportURI string  `json:"expect_ct_report_uri"`
+}
+
+// HSTSHostHash returns the hash under which the browser stores a host's HSTS
+// state: the base64 SHA-256 of the host in DNS wire format, i.e. each
+// lowercased label prefixed with its length, followed by a zero byte.
+func HSTSHostHash(host string) string {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	var wire []byte
+	for _, label := range strings.Split(host, ".") {
+		wire = append(wire, byte(len(label)))
+		wire = append(wire, label...)
+	}
+	wire = append(wire, 0)
+	sum := sha256.Sum256(wire)
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// GetTransportSecurity reads the dynamic HSTS and Expect-CT entries from a
+// profile's TransportSecurity file. Hosts are only stored hashed; use LookupHost to
+// test for a particular host.
+func GetTransportSecurity(transportSecurityPath string) (*TransportSecurity, error) {
+	return GetTransportSecurityFS(osFS(transportSecurityPath))
+}
+
+// GetTransportSecurityFS is GetTransportSecurity for a TransportSecurity
+// file in fsys.
+func GetTransportSecurityFS(fsys fs.FS, name string) (*TransportSecurity, error) {
+	data, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return nil, err
+	}
+
+	// Version 2 lists entries under "sts" and "expect_ct"; older files are an
+	// object keyed by the host hash, with any Expect-CT state nested in each
+	// host's entry.
+	var v2 struct {
+		Version  int                         `json:"version"`
+		STS      []transportSecurityEntry    `json:"sts"`
+		ExpectCT []transportSecurityExpectCT `json:"expect_ct"`
+	}
+	if err := json.Unmarshal(data, &v2); err != nil {
+		return nil, err
+	}
+	entries, expectCT := v2.STS, v2.ExpectCT
+	if v2.Version < 2 && entries == nil {
+		var v1 map[string]json.RawMessage
+		if err := json.Unmarshal(data, &v1); err != nil {
+			return nil, err
+		}
+		for hash, raw := range v1 {
+			var e struct {
+				transportSecurityEntry
+				ExpectCT *transportSecurityExpectCT `json:"expect_ct"`
+			}
+			if err := json.Unmarshal(raw, &e); err != nil {
+				continue
+			}
+			if e.Mode != "" {
+				e.Host = hash
+				entries = append(entries, e.transportSecurityEntry)
+			}
+			if e.ExpectCT != nil {
+				e.ExpectCT.Host = hash
+				expectCT = append(expectCT, *e.ExpectCT)
+			}
+		}
+	}
+
+	ts := &TransportSecurity{byHash: map[string]HSTSEntry{}}
+	for _, e := range entries {
+		entry := HSTSEntry{
+			HostHash:          e.Host,
+			Mode:              e.Mode,
+			IncludeSubdomains: e.StsIncludeSubdomains,
+			Observed:          unixTimeFloat(e.StsObserved),
+			Expiry:            unixTimeFloat(e.Expiry),
+		}
+		ts.Entries = append(ts.Entries, entry)
+		ts.byHash[entry.HostHash] = entry
+	}
+	sort.Slice(ts.Entries, func(i, j int) bool { return ts.Entries[i].HostHash < ts.Entries[j].HostHash })
+
+	for _, e := range expectCT {
+		ts.ExpectCT = append(ts.ExpectCT, ExpectCTEntry{
+			HostHash:  e.Host,
+			Enforce:   e.Enforce,
+			ReportURI: e.ReportURI,
+			Observed:  unixTimeFloat(e.Observed),
+			Expiry:    unixTimeFloat(e.Expiry),
+		})
+	}
+	sort.Slice(ts.ExpectCT, func(i, j int) bool { return ts.ExpectCT[i].HostHash < ts.ExpectCT[j].HostHash })
+
+	return ts, nil
+}
+
+// LookupHost reports whether HSTS applies to host, either through an entry
+// for the host itself or one for a parent domain that includes subdomains.
+// The returned entry's Host is set to the name that matched.
+func (ts *TransportSecurity) LookupHost(host string) (HSTSEntry, bool) {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	for name, exact := host, true; name != ""; exact = false {
+		if e, ok := ts.byHash[HSTSHostHash(name)]; ok && (exact || e.IncludeSubdomains) {
+			e.Host = name
+			return e, true
+		}
+		_, parent, ok := strings.Cut(name, ".")
+		if !ok {
+			break
+		}
+		name = parent
+	}
+	return HSTSEntry{}, false
+}
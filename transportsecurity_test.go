@@ -0,0 +1,94 @@
+package chromedb
+
+import (
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestHSTSHostHash(t *testing.T) {
+	tests := []struct {
+		host string
+		want string
+	}{
+		{"example.com", "kC6cRk+kP8qxCdGmuV3fgzOKjLw6UrT7/hqdhfIkbQ8="},
+		{"Example.COM", "kC6cRk+kP8qxCdGmuV3fgzOKjLw6UrT7/hqdhfIkbQ8="},
+		{"example.com.", "kC6cRk+kP8qxCdGmuV3fgzOKjLw6UrT7/hqdhfIkbQ8="},
+		{"mail.example.com", "S3O+64VCffGuZEOESuojXK2vNh3ZPTav0uIsO0crt9w="},
+		{"localhost", "+5SucHeyam84+YKFvzbLSOOu8EIOl0hrqv6OPw5r2/Q="},
+	}
+	for _, tt := range tests {
+		if got := HSTSHostHash(tt.host); got != tt.want {
+			t.Errorf("HSTSHostHash(%q) = %s, want %s", tt.host, got, tt.want)
+		}
+	}
+}
+
+func TestGetTransportSecurity(t *testing.T) {
+	// The fixture has HSTS entries for example.com, including subdomains,
+	// and localhost, and an Expect-CT entry for mail.example.com.
+	ts, err := GetTransportSecurity("testdata/TransportSecurity")
+	if err != nil {
+		t.Fatalf("GetTransportSecurity: %v", err)
+	}
+	if len(ts.Entries) != 2 {
+		t.Fatalf("got %d HSTS entries, want 2", len(ts.Entries))
+	}
+	if len(ts.ExpectCT) != 1 {
+		t.Fatalf("got %d Expect-CT entries, want 1", len(ts.ExpectCT))
+	}
+	ct := ts.ExpectCT[0]
+	if ct.HostHash != HSTSHostHash("mail.example.com") || !ct.Enforce || ct.ReportURI != "https://report.example/ct" {
+		t.Errorf("Expect-CT entry = %+v", ct)
+	}
+	if want := time.Unix(1600000000, 0).UTC(); !ct.Observed.Equal(want) {
+		t.Errorf("Expect-CT observed = %v, want %v", ct.Observed, want)
+	}
+
+	tests := []struct {
+		host      string
+		wantFound bool
+		wantHost  string
+	}{
+		{"example.com", true, "example.com"},
+		{"mail.example.com", true, "example.com"},
+		{"a.b.example.com.", true, "example.com"},
+		{"localhost", true, "localhost"},
+		{"sub.localhost", false, ""},
+		{"example.org", false, ""},
+		{"com", false, ""},
+	}
+	for _, tt := range tests {
+		e, found := ts.LookupHost(tt.host)
+		if found != tt.wantFound || e.Host != tt.wantHost {
+			t.Errorf("LookupHost(%q) = %q, %v; want %q, %v", tt.host, e.Host, found, tt.wantHost, tt.wantFound)
+		}
+	}
+}
+
+func TestGetTransportSecurityVersion1(t *testing.T) {
+	data := []byte(`{
+		"kC6cRk+kP8qxCdGmuV3fgzOKjLw6UrT7/hqdhfIkbQ8=": {
+			"mode": "force-https", "sts_include_subdomains": true,
+			"sts_observed": 1700000000.0, "expiry": 1800000000.0
+		},
+		"S3O+64VCffGuZEOESuojXK2vNh3ZPTav0uIsO0crt9w=": {
+			"mode": "default", "expiry": 0,
+			"expect_ct": {"expect_ct_observed": 1600000000.0, "expect_ct_expiry": 1700000000.0, "expect_ct_enforce": false}
+		},
+		"bogus": 1
+	}`)
+	ts, err := GetTransportSecurityFS(fstest.MapFS{"TransportSecurity": {Data: data}}, "TransportSecurity")
+	if err != nil {
+		t.Fatalf("GetTransportSecurityFS: %v", err)
+	}
+	if len(ts.Entries) != 2 || len(ts.ExpectCT) != 1 {
+		t.Fatalf("got %d HSTS and %d Expect-CT entries, want 2 and 1", len(ts.Entries), len(ts.ExpectCT))
+	}
+	if ts.ExpectCT[0].HostHash != HSTSHostHash("mail.example.com") {
+		t.Errorf("Expect-CT entry = %+v", ts.ExpectCT[0])
+	}
+	if e, found := ts.LookupHost("www.example.com"); !found || e.Mode != "force-https" {
+		t.Errorf("LookupHost(www.example.com) = %+v, %v", e, found)
+	}
+}
@@ -0,0 +1,818 @@
+package chromedb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// V8 ValueSerializer tags, see v8/src/objects/value-serializer.cc.
+const (
+	v8TagVersion             = 0xFF
+	v8TagPadding             = 0x00
+	v8TagVerifyObjectCount   = '?'
+	v8TagTheHole             = '-'
+	v8TagUndefined           = '_'
+	v8TagNull                = '0'
+	v8TagTrue                = 'T'
+	v8TagFalse               = 'F'
+	v8TagInt32               = 'I'
+	v8TagUint32              = 'U'
+	v8TagDouble              = 'N'
+	v8TagBigInt              = 'Z'
+	v8TagUtf8String          = 'S'
+	v8TagOneByteString       = '"'
+	v8TagTwoByteString       = 'c'
+	v8TagObjectReference     = '^'
+	v8TagBeginJSObject       = 'o'
+	v8TagEndJSObject         = '{'
+	v8TagBeginSparseJSArray  = 'a'
+	v8TagEndSparseJSArray    = '@'
+	v8TagBeginDenseJSArray   = 'A'
+	v8TagEndDenseJSArray     = '$'
+	v8TagDate                = 'D'
+	v8TagTrueObject          = 'y'
+	v8TagFalseObject         = 'x'
+	v8TagNumberObject        = 'n'
+	v8TagBigIntObject        = 'z'
+	v8TagStringObject        = 's'
+	v8TagRegExp              = 'R'
+	v8TagBeginJSMap          = ';'
+	v8TagEndJSMap            = ':'
+	v8TagBeginJSSet          = '\''
+	v8TagEndJSSet            = ','
+	v8TagArrayBuffer         = 'B'
+	v8TagResizableBuffer     = '~'
+	v8TagArrayBufferTransfer = 't'
+	v8TagArrayBufferView     = 'V'
+	v8TagSharedArrayBuffer   = 'u'
+	v8TagHostObject          = '\\'
+	v8TagError               = 'r'
+)
+
+// Blink's trailer offset tag, written between the Blink and V8 version
+// headers by newer serializers.
+const blinkTagTrailerOffset = 0xFE
+
+// jsObject is a deserialized JavaScript object. Properties are kept in
+// insertion order so the JSON output matches what the page stored.
+type jsObject struct {
+	keys   []string
+	values []interface{}
+}
+
+func (o *jsObject) set(key string, value interface{}) {
+	o.keys = append(o.keys, key)
+	o.values = append(o.values, value)
+}
+
+func (o *jsObject) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, k := range o.keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		kb, err := json.Marshal(k)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(kb)
+		buf.WriteByte(':')
+		vb, err := json.Marshal(o.values[i])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(vb)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// jsReference stands in for an object that refers back to one of its own
+// ancestors, which JSON can't represent.
+type jsReference struct {
+	Ref uint32 `json:"$ref"`
+}
+
+type v8Deserializer struct {
+	data    []byte
+	pos     int
+	version uint64
+	nextID  uint32
+	objects map[uint32]interface{}
+	open    map[uint32]bool
+}
+
+// DeserializeV8 decodes a value written by V8's ValueSerializer (optionally
+// wrapped in Blink's SerializedScriptValue header) into plain Go values that
+// can be marshalled to JSON.
+func DeserializeV8(data []byte) (interface{}, error) {
+	d := &v8Deserializer{
+		data:    data,
+		objects: map[uint32]interface{}{},
+		open:    map[uint32]bool{},
+	}
+	if err := d.readHeader(); err != nil {
+		return nil, err
+	}
+	return d.readValue()
+}
+
+func (d *v8Deserializer) readHeader() error {
+	for d.pos < len(d.data) && d.data[d.pos] == v8TagVersion {
+		d.pos++
+		version, err := d.readVarint()
+		if err != nil {
+			return fmt.Errorf("failed to read serializer version: %w", err)
+		}
+		d.version = version
+
+		if d.pos < len(d.data) && d.data[d.pos] == blinkTagTrailerOffset {
+			// uint64 offset and uint32 size of the trailer
+			if d.pos+13 > len(d.data) {
+				return fmt.Errorf("truncated trailer offset")
+			}
+			d.pos += 13
+		}
+	}
+	return nil
+}
+
+func (d *v8Deserializer) readByte() (byte, error) {
+	if d.pos >= len(d.data) {
+		return 0, fmt.Errorf("unexpected end of data at offset %d", d.pos)
+	}
+	b := d.data[d.pos]
+	d.pos++
+	return b, nil
+}
+
+func (d *v8Deserializer) readBytes(n uint64) ([]byte, error) {
+	if n > uint64(len(d.data)-d.pos) {
+		return nil, fmt.Errorf("unexpected end of data reading %d bytes at offset %d", n, d.pos)
+	}
+	b := d.data[d.pos : d.pos+int(n)]
+	d.pos += int(n)
+	return b, nil
+}
+
+func (d *v8Deserializer) readVarint() (uint64, error) {
+	v, n := protowire.ConsumeVarint(d.data[d.pos:])
+	if n < 0 {
+		return 0, fmt.Errorf("invalid varint at offset %d", d.pos)
+	}
+	d.pos += n
+	return v, nil
+}
+
+func (d *v8Deserializer) readZigZag() (int64, error) {
+	v, err := d.readVarint()
+	if err != nil {
+		return 0, err
+	}
+	return protowire.DecodeZigZag(v), nil
+}
+
+func (d *v8Deserializer) readDouble() (float64, error) {
+	b, err := d.readBytes(8)
+	if err != nil {
+		return 0, err
+	}
+	return math.Float64frombits(binary.LittleEndian.Uint64(b)), nil
+}
+
+// readTag returns the next tag, skipping any alignment padding.
+func (d *v8Deserializer) readTag() (byte, error) {
+	for {
+		tag, err := d.readByte()
+		if err != nil {
+			return 0, err
+		}
+		if tag != v8TagPadding {
+			return tag, nil
+		}
+	}
+}
+
+func (d *v8Deserializer) peekTag() (byte, bool) {
+	for i := d.pos; i < len(d.data); i++ {
+		if d.data[i] != v8TagPadding {
+			return d.data[i], true
+		}
+	}
+	return 0, false
+}
+
+// consumeTag consumes the next tag if it equals want.
+func (d *v8Deserializer) consumeTag(want byte) bool {
+	tag, ok := d.peekTag()
+	if !ok || tag != want {
+		return false
+	}
+	d.readTag()
+	return true
+}
+
+func (d *v8Deserializer) addObject(v interface{}) uint32 {
+	id := d.nextID
+	d.nextID++
+	d.objects[id] = v
+	return id
+}
+
+func (d *v8Deserializer) readValue() (interface{}, error) {
+	v, err := d.readValueInternal()
+	if err != nil {
+		return nil, err
+	}
+
+	// A typed array or DataView is serialized as its backing buffer
+	// immediately followed by the view.
+	if buf, ok := v.([]byte); ok && d.consumeTag(v8TagArrayBufferView) {
+		return d.readArrayBufferView(buf)
+	}
+	return v, nil
+}
+
+func (d *v8Deserializer) readValueInternal() (interface{}, error) {
+	tag, err := d.readTag()
+	if err != nil {
+		return nil, err
+	}
+
+	switch tag {
+	case v8TagVerifyObjectCount:
+		if _, err := d.readVarint(); err != nil {
+			return nil, err
+		}
+		return d.readValueInternal()
+	case v8TagUndefined, v8TagNull, v8TagTheHole:
+		return nil, nil
+	case v8TagTrue:
+		return true, nil
+	case v8TagFalse:
+		return false, nil
+	case v8TagInt32:
+		return d.readZigZag()
+	case v8TagUint32:
+		return d.readVarint()
+	case v8TagDouble:
+		f, err := d.readDouble()
+		if err != nil {
+			return nil, err
+		}
+		return jsonNumber(f), nil
+	case v8TagBigInt:
+		return d.readBigInt()
+	case v8TagUtf8String, v8TagOneByteString, v8TagTwoByteString:
+		return d.readString(tag)
+	case v8TagObjectReference:
+		id, err := d.readVarint()
+		if err != nil {
+			return nil, err
+		}
+		if d.open[uint32(id)] {
+			return jsReference{Ref: uint32(id)}, nil
+		}
+		v, ok := d.objects[uint32(id)]
+		if !ok {
+			return nil, fmt.Errorf("invalid object reference %d", id)
+		}
+		return v, nil
+	case v8TagBeginJSObject:
+		obj := &jsObject{}
+		id := d.addObject(obj)
+		d.open[id] = true
+		defer delete(d.open, id)
+		if err := d.readProperties(obj, v8TagEndJSObject); err != nil {
+			return nil, err
+		}
+		if _, err := d.readVarint(); err != nil {
+			return nil, err
+		}
+		return obj, nil
+	case v8TagBeginDenseJSArray:
+		return d.readDenseArray()
+	case v8TagBeginSparseJSArray:
+		return d.readSparseArray()
+	case v8TagDate:
+		ms, err := d.readDouble()
+		if err != nil {
+			return nil, err
+		}
+		var v interface{}
+		if !math.IsNaN(ms) && !math.IsInf(ms, 0) {
+			v = time.UnixMilli(int64(ms)).UTC()
+		}
+		d.addObject(v)
+		return v, nil
+	case v8TagTrueObject, v8TagFalseObject:
+		v := tag == v8TagTrueObject
+		d.addObject(v)
+		return v, nil
+	case v8TagNumberObject:
+		f, err := d.readDouble()
+		if err != nil {
+			return nil, err
+		}
+		v := jsonNumber(f)
+		d.addObject(v)
+		return v, nil
+	case v8TagBigIntObject:
+		v, err := d.readBigInt()
+		if err != nil {
+			return nil, err
+		}
+		d.addObject(v)
+		return v, nil
+	case v8TagStringObject:
+		v, err := d.readStringValue()
+		if err != nil {
+			return nil, err
+		}
+		d.addObject(v)
+		return v, nil
+	case v8TagRegExp:
+		return d.readRegExp()
+	case v8TagBeginJSMap:
+		return d.readMap()
+	case v8TagBeginJSSet:
+		return d.readSet()
+	case v8TagArrayBuffer:
+		n, err := d.readVarint()
+		if err != nil {
+			return nil, err
+		}
+		b, err := d.readBytes(n)
+		if err != nil {
+			return nil, err
+		}
+		d.addObject(b)
+		return b, nil
+	case v8TagResizableBuffer:
+		n, err := d.readVarint()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := d.readVarint(); err != nil {
+			return nil, err
+		}
+		b, err := d.readBytes(n)
+		if err != nil {
+			return nil, err
+		}
+		d.addObject(b)
+		return b, nil
+	case v8TagArrayBufferTransfer, v8TagSharedArrayBuffer:
+		if _, err := d.readVarint(); err != nil {
+			return nil, err
+		}
+		v := &jsObject{}
+		v.set("$unsupported", "transferred array buffer")
+		d.addObject(v)
+		return v, nil
+	case v8TagError:
+		return d.readError()
+	case v8TagHostObject:
+		return d.readHostObject()
+	}
+
+	return nil, fmt.Errorf("unsupported V8 tag 0x%02x at offset %d", tag, d.pos-1)
+}
+
+func jsonNumber(f float64) interface{} {
+	switch {
+	case math.IsNaN(f):
+		return "NaN"
+	case math.IsInf(f, 1):
+		return "Infinity"
+	case math.IsInf(f, -1):
+		return "-Infinity"
+	}
+	return f
+}
+
+func (d *v8Deserializer) readString(tag byte) (string, error) {
+	n, err := d.readVarint()
+	if err != nil {
+		return "", err
+	}
+	b, err := d.readBytes(n)
+	if err != nil {
+		return "", err
+	}
+
+	switch tag {
+	case v8TagUtf8String:
+		return string(b), nil
+	case v8TagOneByteString:
+		runes := make([]rune, len(b))
+		for i, c := range b {
+			runes[i] = rune(c)
+		}
+		return string(runes), nil
+	case v8TagTwoByteString:
+		return decodeUTF16LE(b)
+	}
+	return "", fmt.Errorf("not a string tag: 0x%02x", tag)
+}
+
+func (d *v8Deserializer) readStringValue() (string, error) {
+	tag, err := d.readTag()
+	if err != nil {
+		return "", err
+	}
+	return d.readString(tag)
+}
+
+func (d *v8Deserializer) readBigInt() (json.Number, error) {
+	bitfield, err := d.readVarint()
+	if err != nil {
+		return "", err
+	}
+	b, err := d.readBytes(bitfield >> 1)
+	if err != nil {
+		return "", err
+	}
+
+	// Digits are little-endian; big.Int wants big-endian.
+	be := make([]byte, len(b))
+	for i := range b {
+		be[len(b)-1-i] = b[i]
+	}
+	n := new(big.Int).SetBytes(be)
+	if bitfield&1 == 1 {
+		n.Neg(n)
+	}
+	return json.Number(n.String()), nil
+}
+
+// propertyKey converts a property key, which may be serialized as a
+// number or a string, to an object key.
+func propertyKey(k interface{}) string {
+	switch v := k.(type) {
+	case string:
+		return v
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case uint64:
+		return strconv.FormatUint(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return fmt.Sprint(k)
+}
+
+func (d *v8Deserializer) readProperties(obj *jsObject, end byte) error {
+	for {
+		if d.consumeTag(end) {
+			return nil
+		}
+		k, err := d.readValue()
+		if err != nil {
+			return err
+		}
+		v, err := d.readValue()
+		if err != nil {
+			return err
+		}
+		obj.set(propertyKey(k), v)
+	}
+}
+
+func (d *v8Deserializer) readDenseArray() (interface{}, error) {
+	n, err := d.readVarint()
+	if err != nil {
+		return nil, err
+	}
+	if n > uint64(len(d.data)) {
+		return nil, fmt.Errorf("dense array length %d exceeds data size", n)
+	}
+
+	arr := make([]interface{}, 0, n)
+	id := d.addObject(arr)
+	d.open[id] = true
+	defer delete(d.open, id)
+
+	for i := uint64(0); i < n; i++ {
+		v, err := d.readValue()
+		if err != nil {
+			return nil, err
+		}
+		arr = append(arr, v)
+	}
+
+	// Non-index properties on arrays are read but dropped.
+	extra := &jsObject{}
+	if err := d.readProperties(extra, v8TagEndDenseJSArray); err != nil {
+		return nil, err
+	}
+	if _, err := d.readVarint(); err != nil {
+		return nil, err
+	}
+	if _, err := d.readVarint(); err != nil {
+		return nil, err
+	}
+
+	d.objects[id] = arr
+	return arr, nil
+}
+
+func (d *v8Deserializer) readSparseArray() (interface{}, error) {
+	n, err := d.readVarint()
+	if err != nil {
+		return nil, err
+	}
+
+	obj := &jsObject{}
+	id := d.addObject(obj)
+	d.open[id] = true
+	defer delete(d.open, id)
+
+	if err := d.readProperties(obj, v8TagEndSparseJSArray); err != nil {
+		return nil, err
+	}
+	if _, err := d.readVarint(); err != nil {
+		return nil, err
+	}
+	if _, err := d.readVarint(); err != nil {
+		return nil, err
+	}
+
+	// Materialize small sparse arrays; keep huge ones as index->value objects.
+	if n > 1<<16 {
+		return obj, nil
+	}
+	arr := make([]interface{}, n)
+	for i, k := range obj.keys {
+		idx, err := strconv.ParseUint(k, 10, 64)
+		if err == nil && idx < n {
+			arr[idx] = obj.values[i]
+		}
+	}
+	d.objects[id] = arr
+	return arr, nil
+}
+
+func (d *v8Deserializer) readRegExp() (interface{}, error) {
+	pattern, err := d.readStringValue()
+	if err != nil {
+		return nil, err
+	}
+	bits, err := d.readVarint()
+	if err != nil {
+		return nil, err
+	}
+
+	flags := ""
+	for _, f := range []struct {
+		bit  uint64
+		flag string
+	}{{128, "d"}, {1, "g"}, {2, "i"}, {4, "m"}, {32, "s"}, {16, "u"}, {256, "v"}, {8, "y"}} {
+		if bits&f.bit != 0 {
+			flags += f.flag
+		}
+	}
+	v := "/" + pattern + "/" + flags
+	d.addObject(v)
+	return v, nil
+}
+
+func (d *v8Deserializer) readMap() (interface{}, error) {
+	entries := [][]interface{}{}
+	id := d.addObject(entries)
+	d.open[id] = true
+	defer delete(d.open, id)
+
+	for !d.consumeTag(v8TagEndJSMap) {
+		k, err := d.readValue()
+		if err != nil {
+			return nil, err
+		}
+		v, err := d.readValue()
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, []interface{}{k, v})
+	}
+	if _, err := d.readVarint(); err != nil {
+		return nil, err
+	}
+
+	d.objects[id] = entries
+	return entries, nil
+}
+
+func (d *v8Deserializer) readSet() (interface{}, error) {
+	values := []interface{}{}
+	id := d.addObject(values)
+	d.open[id] = true
+	defer delete(d.open, id)
+
+	for !d.consumeTag(v8TagEndJSSet) {
+		v, err := d.readValue()
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+	if _, err := d.readVarint(); err != nil {
+		return nil, err
+	}
+
+	d.objects[id] = values
+	return values, nil
+}
+
+func (d *v8Deserializer) readArrayBufferView(buf []byte) (interface{}, error) {
+	subtag, err := d.readByte()
+	if err != nil {
+		return nil, err
+	}
+	offset, err := d.readVarint()
+	if err != nil {
+		return nil, err
+	}
+	length, err := d.readVarint()
+	if err != nil {
+		return nil, err
+	}
+	if d.version >= 14 {
+		if _, err := d.readVarint(); err != nil {
+			return nil, err
+		}
+	}
+	if offset > uint64(len(buf)) || length > uint64(len(buf))-offset {
+		return nil, fmt.Errorf("array buffer view out of range")
+	}
+	b := buf[offset : offset+length]
+
+	var size int
+	var elem func([]byte) interface{}
+	switch subtag {
+	case 'b':
+		size, elem = 1, func(b []byte) interface{} { return int8(b[0]) }
+	case 'B', 'C':
+		size, elem = 1, func(b []byte) interface{} { return b[0] }
+	case 'w':
+		size, elem = 2, func(b []byte) interface{} { return int16(binary.LittleEndian.Uint16(b)) }
+	case 'W':
+		size, elem = 2, func(b []byte) interface{} { return binary.LittleEndian.Uint16(b) }
+	case 'd':
+		size, elem = 4, func(b []byte) interface{} { return int32(binary.LittleEndian.Uint32(b)) }
+	case 'D':
+		size, elem = 4, func(b []byte) interface{} { return binary.LittleEndian.Uint32(b) }
+	case 'f':
+		size, elem = 4, func(b []byte) interface{} {
+			return jsonNumber(float64(math.Float32frombits(binary.LittleEndian.Uint32(b))))
+		}
+	case 'F':
+		size, elem = 8, func(b []byte) interface{} {
+			return jsonNumber(math.Float64frombits(binary.LittleEndian.Uint64(b)))
+		}
+	case 'q':
+		size, elem = 8, func(b []byte) interface{} {
+			return json.Number(strconv.FormatInt(int64(binary.LittleEndian.Uint64(b)), 10))
+		}
+	case 'Q':
+		size, elem = 8, func(b []byte) interface{} {
+			return json.Number(strconv.FormatUint(binary.LittleEndian.Uint64(b), 10))
+		}
+	case '?':
+		// DataView has no element type; expose the raw bytes.
+		d.addObject(b)
+		return b, nil
+	default:
+		return nil, fmt.Errorf("unsupported array buffer view type %q", subtag)
+	}
+
+	arr := make([]interface{}, 0, len(b)/size)
+	for i := 0; i+size <= len(b); i += size {
+		arr = append(arr, elem(b[i:i+size]))
+	}
+	d.addObject(arr)
+	return arr, nil
+}
+
+func (d *v8Deserializer) readError() (interface{}, error) {
+	obj := &jsObject{}
+	id := d.addObject(obj)
+	d.open[id] = true
+	defer delete(d.open, id)
+
+	name := "Error"
+	for {
+		tag, err := d.readByte()
+		if err != nil {
+			return nil, err
+		}
+		switch tag {
+		case 'E':
+			name = "EvalError"
+		case 'R':
+			name = "RangeError"
+		case 'F':
+			name = "ReferenceError"
+		case 'S':
+			name = "SyntaxError"
+		case 'T':
+			name = "TypeError"
+		case 'U':
+			name = "URIError"
+		case 'm':
+			msg, err := d.readStringValue()
+			if err != nil {
+				return nil, err
+			}
+			obj.set("message", msg)
+		case 's':
+			stack, err := d.readStringValue()
+			if err != nil {
+				return nil, err
+			}
+			obj.set("stack", stack)
+		case 'c':
+			cause, err := d.readValue()
+			if err != nil {
+				return nil, err
+			}
+			obj.set("cause", cause)
+		case '.':
+			obj.set("name", name)
+			return obj, nil
+		default:
+			return nil, fmt.Errorf("unsupported error tag %q", tag)
+		}
+	}
+}
+
+// readHostObject handles the objects Blink serializes itself. Only the blob
+// and file references IndexedDB commonly stores are understood.
+func (d *v8Deserializer) readHostObject() (interface{}, error) {
+	tag, err := d.readByte()
+	if err != nil {
+		return nil, err
+	}
+
+	readUTF8 := func() (string, error) {
+		n, err := d.readVarint()
+		if err != nil {
+			return "", err
+		}
+		b, err := d.readBytes(n)
+		return string(b), err
+	}
+
+	obj := &jsObject{}
+	switch tag {
+	case 'b': // Blob
+		uuid, err := readUTF8()
+		if err != nil {
+			return nil, err
+		}
+		typ, err := readUTF8()
+		if err != nil {
+			return nil, err
+		}
+		size, err := d.readVarint()
+		if err != nil {
+			return nil, err
+		}
+		obj.set("$blob", uuid)
+		obj.set("type", typ)
+		obj.set("size", size)
+	case 'i', 'e': // Blob or File stored by index
+		idx, err := d.readVarint()
+		if err != nil {
+			return nil, err
+		}
+		obj.set("$blob_index", idx)
+	case 'L': // FileList stored by index
+		n, err := d.readVarint()
+		if err != nil {
+			return nil, err
+		}
+		indexes := []uint64{}
+		for i := uint64(0); i < n; i++ {
+			idx, err := d.readVarint()
+			if err != nil {
+				return nil, err
+			}
+			indexes = append(indexes, idx)
+		}
+		obj.set("$blob_indexes", indexes)
+	default:
+		return nil, fmt.Errorf("unsupported host object tag %q", tag)
+	}
+
+	d.addObject(obj)
+	return obj, nil
+}
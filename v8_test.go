@@ -0,0 +1,97 @@
+package chromedb
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// v8 prefixes a serialized value with a V8 version 13 header.
+func v8(b ...byte) []byte {
+	return append([]byte{0xFF, 0x0D}, b...)
+}
+
+func TestDeserializeV8(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{"null", v8('0'), `null`},
+		{"true", v8('T'), `true`},
+		{"int32", v8('I', 0x0A), `5`},
+		{"negative int32", v8('I', 0x09), `-5`},
+		{"double", v8('N', 0, 0, 0, 0, 0, 0, 0xF8, 0x3F), `1.5`},
+		{"NaN", v8('N', 1, 0, 0, 0, 0, 0, 0xF8, 0x7F), `"NaN"`},
+		{"bigint", v8('Z', 0x10, 1, 0, 0, 0, 0, 0, 0, 0), `1`},
+		{"negative bigint", v8('Z', 0x11, 2, 0, 0, 0, 0, 0, 0, 0), `-2`},
+		{"utf-8 string", v8('S', 3, 'a', 0xC3, 0xA9), `"aé"`},
+		{"one-byte string is Latin-1", v8('"', 3, 'a', 0xE9, 'c'), `"aéc"`},
+		{"two-byte string", v8('c', 4, 'h', 0, 'i', 0), `"hi"`},
+		{"padding before a tag", v8(0, 0, 'T'), `true`},
+		{"object", v8('o', '"', 1, 'a', 'I', 2, '"', 1, 'b', 'T', '{', 2), `{"a":1,"b":true}`},
+		{"numeric property key", v8('o', 'I', 6, '"', 1, 'x', '{', 1), `{"3":"x"}`},
+		{"dense array", v8('A', 2, 'I', 2, '_', '$', 0, 2), `[1,null]`},
+		{"sparse array", v8('a', 3, 'I', 4, 'T', '@', 1, 3), `[null,null,true]`},
+		{"map", v8(';', '"', 1, 'k', 'I', 2, ':', 2), `[["k",1]]`},
+		{"set", v8('\'', 'I', 2, 'I', 4, ',', 2), `[1,2]`},
+		{"regexp", v8('R', '"', 2, 'a', 'b', 3), `"/ab/gi"`},
+		{"date", v8('D', 0, 0, 0, 0, 0, 0, 0, 0), `"1970-01-01T00:00:00Z"`},
+		{"reference to an earlier object", v8('A', 2, 'o', '{', 0, '^', 1, '$', 0, 2), `[{},{}]`},
+		{"reference to an enclosing object", v8('o', '"', 1, 's', '^', 0, '{', 1), `{"s":{"$ref":0}}`},
+		{"array buffer", v8('B', 2, 1, 2), `"AQI="`},
+		{"uint8 array", v8('B', 4, 1, 2, 3, 4, 'V', 'B', 1, 2), `[2,3]`},
+		{"int16 array", v8('B', 4, 0xFF, 0xFF, 2, 0, 'V', 'w', 0, 4), `[-1,2]`},
+		{"uint8 array with flags", []byte{0xFF, 0x0F, 'B', 2, 7, 8, 'V', 'B', 0, 2, 0}, `[7,8]`},
+		{"data view", v8('B', 3, 1, 2, 3, 'V', '?', 1, 1), `"Ag=="`},
+		{"blink header", []byte{0xFF, 0x14, 0xFF, 0x0D, 'T'}, `true`},
+		{"blink trailer offset", []byte{0xFF, 0x15, 0xFE, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0xFF, 0x0D, 'F'}, `false`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v, err := DeserializeV8(tt.data)
+			if err != nil {
+				t.Fatalf("DeserializeV8: %v", err)
+			}
+			got, err := json.Marshal(v)
+			if err != nil {
+				t.Fatalf("json.Marshal: %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDeserializeV8Errors(t *testing.T) {
+	// 2^64-1 as a varint.
+	huge := []byte{0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0x01}
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"empty", nil},
+		{"unknown tag", v8('!')},
+		{"truncated string", v8('"', 5, 'a')},
+		{"huge string length", v8(append([]byte{'"'}, huge...)...)},
+		{"huge array buffer length", v8(append([]byte{'B'}, huge...)...)},
+		{"huge bigint length", v8(append([]byte{'Z'}, huge...)...)},
+		{"huge dense array length", v8(append([]byte{'A'}, huge...)...)},
+		{"truncated varint", v8('I', 0x80)},
+		{"truncated object", v8('o', '"', 1, 'a')},
+		{"dangling reference", v8('^', 3)},
+		{"view past its buffer", v8('B', 2, 1, 2, 'V', 'B', 1, 2)},
+		{"view with huge offset", v8(append(append([]byte{'B', 2, 1, 2, 'V', 'B'}, huge...), 2)...)},
+		{"view with huge length", v8(append([]byte{'B', 2, 1, 2, 'V', 'B', 1}, huge...)...)},
+		{"unknown view type", v8('B', 2, 1, 2, 'V', 'Z', 0, 2)},
+		{"truncated blink trailer offset", []byte{0xFF, 0x15, 0xFE, 0, 0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if v, err := DeserializeV8(tt.data); err == nil {
+				t.Errorf("DeserializeV8 = %v, want error", v)
+			}
+		})
+	}
+}
@@ -0,0 +1,110 @@
+package chromedb
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/h2non/filetype"
+)
+
+// classifiedValue is a stored string value converted for JSON output, along
+// with what it was found to contain and how it was converted.
+type classifiedValue struct {
+	MIME        string
+	Conversions []string
+	JsonType    string
+	Value       json.RawMessage
+}
+
+// classifyValue embeds JSON values as-is, quotes printable text and base64
+// encodes anything else, guessing each value's MIME type along the way.
+func classifyValue(decoded string) (classifiedValue, error) {
+
+	mime := "application/octet-stream"
+	xfer := []string{}
+	b := []byte(decoded)
+	validJson := json.Valid(b)
+	jsonType := ""
+	out := []byte{}
+
+	if validJson {
+		// Use a custom decoder to handle large numbers as strings
+		d := json.NewDecoder(bytes.NewReader(b))
+		d.UseNumber() // This makes the decoder use json.Number for numbers
+		var v interface{}
+		err := d.Decode(&v)
+		if err != nil {
+			return classifiedValue{}, fmt.Errorf("failed to unmarshal supposedly valid JSON: %w", err)
+		}
+
+		mime = "application/json"
+
+		// Determine the type of the JSON value
+		switch val := v.(type) {
+		case json.Number:
+			jsonType = "number"
+			// Check if the number might be too large for float64
+			_, err := val.Float64()
+			if err != nil {
+				// If it can't be converted to float64, treat it as a string in the output
+				strVal := fmt.Sprintf("\"%s\"", val.String())
+				out = []byte(strVal)
+			} else {
+				out = b
+			}
+		case string:
+			jsonType = "string"
+			out = b
+		case bool:
+			jsonType = "boolean"
+			out = b
+		case []interface{}:
+			jsonType = "array"
+			out = b
+		case map[string]interface{}:
+			jsonType = "object"
+			out = b
+		case nil:
+			jsonType = "null"
+			out = b
+		default:
+			jsonType = ""
+			out = b
+		}
+	} else {
+		quoted := strconv.Quote(decoded)
+		if json.Valid([]byte(quoted)) {
+
+			out = []byte(quoted)
+			mime = "text/plain"
+			xfer = append(xfer, "strconv.Quote")
+			mime = http.DetectContentType(b)
+			mime = strings.Split(mime, ";")[0]
+
+		} else {
+
+			b64 := base64.StdEncoding.EncodeToString(b)
+			xfer = append(xfer, "base64.StdEncoding.EncodeToString")
+			out = []byte(strconv.Quote(b64))
+			xfer = append(xfer, "strconv.Quote")
+
+			magic, _ := filetype.Match(b)
+			if magic != filetype.Unknown {
+				mime = magic.MIME.Value
+			}
+
+		}
+	}
+
+	return classifiedValue{
+		MIME:        mime,
+		Conversions: xfer,
+		JsonType:    jsonType,
+		Value:       out,
+	}, nil
+}
@@ -0,0 +1,127 @@
+package chromedb
+
+import (
+	"crypto/md5"
+	"encoding/binary"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"strings"
+)
+
+const (
+	visitedLinksSignature  = 0x6b6e4c56 // "VLnk"
+	visitedLinksHeaderSize = 24
+	visitedLinksSaltLength = 8
+)
+
+// VisitedLinks is the browser's table of visited URL fingerprints, used to
+// style visited links. It outlives History entries and can't be enumerated,
+// only queried for a known URL.
+type VisitedLinks struct {
+	Version uint32
+	Length  uint32
+	Used    uint32
+	Salt    [visitedLinksSaltLength]byte
+	table   []uint64
+}
+
+// GetVisitedLinks reads a profile's Visited Links file: a 24-byte header
+// (signature, version, table length, used count and salt) followed by the
+// hash table of 64-bit fingerprints.
+func GetVisitedLinks(visitedLinksPath string) (*VisitedLinks, error) {
+	return GetVisitedLinksFS(osFS(visitedLinksPath))
+}
+
+// GetVisitedLinksFS is GetVisitedLinks for a Visited Links file in fsys.
+func GetVisitedLinksFS(fsys fs.FS, name string) (*VisitedLinks, error) {
+	data, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < visitedLinksHeaderSize {
+		return nil, fmt.Errorf("visited links file too short: %d bytes", len(data))
+	}
+	if sig := binary.LittleEndian.Uint32(data); sig != visitedLinksSignature {
+		return nil, fmt.Errorf("bad visited links signature: %#x", sig)
+	}
+
+	vl := &VisitedLinks{
+		Version: binary.LittleEndian.Uint32(data[4:]),
+		Length:  binary.LittleEndian.Uint32(data[8:]),
+		Used:    binary.LittleEndian.Uint32(data[12:]),
+	}
+	copy(vl.Salt[:], data[16:visitedLinksHeaderSize])
+
+	body := data[visitedLinksHeaderSize:]
+	if vl.Length == 0 || uint64(len(body)) < uint64(vl.Length)*8 {
+		return nil, fmt.Errorf("visited links table truncated: %d entries, %d bytes", vl.Length, len(body))
+	}
+	vl.table = make([]uint64, vl.Length)
+	for i := range vl.table {
+		vl.table[i] = binary.LittleEndian.Uint64(body[i*8:])
+	}
+	return vl, nil
+}
+
+// Fingerprint returns the fingerprint of a canonical URL: the first 8 bytes
+// of MD5(salt + URL).
+func (vl *VisitedLinks) Fingerprint(canonicalURL string) uint64 {
+	h := md5.New()
+	h.Write(vl.Salt[:])
+	h.Write([]byte(canonicalURL))
+	return binary.LittleEndian.Uint64(h.Sum(nil))
+}
+
+// IsVisited reports whether rawURL's fingerprint is in the table. The URL is
+// canonicalized first, so "https://Example.com" matches "https://example.com/".
+// Fingerprints can collide, so a match is strong but not certain evidence.
+func (vl *VisitedLinks) IsVisited(rawURL string) bool {
+	fp := vl.Fingerprint(canonicalVisitedURL(rawURL))
+	if fp == 0 {
+		return false
+	}
+
+	// Open addressing with linear probing; an empty slot ends the chain.
+	first := fp % uint64(vl.Length)
+	for i := first; ; {
+		switch vl.table[i] {
+		case fp:
+			return true
+		case 0:
+			return false
+		}
+		if i++; i == uint64(vl.Length) {
+			i = 0
+		}
+		if i == first {
+			return false
+		}
+	}
+}
+
+// canonicalVisitedURL approximates the browser's URL canonicalization for the
+// common cases: lowercase scheme and host, default ports dropped and an empty
+// path replaced with "/".
+func canonicalVisitedURL(rawURL string) string {
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil || u.Host == "" {
+		return rawURL
+	}
+	u.Scheme = strings.ToLower(u.Scheme)
+	host, port := strings.ToLower(u.Hostname()), u.Port()
+	if (u.Scheme == "http" && port == "80") || (u.Scheme == "https" && port == "443") {
+		port = ""
+	}
+	if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+	if port != "" {
+		host += ":" + port
+	}
+	u.Host = host
+	if u.Path == "" {
+		u.Path = "/"
+	}
+	return u.String()
+}
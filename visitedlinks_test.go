@@ -0,0 +1,100 @@
+package chromedb
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeVisitedLinks writes data to a Visited Links file in a temporary
+// directory and returns its path.
+func writeVisitedLinks(t *testing.T, data []byte) string {
+	t.Helper()
+	name := filepath.Join(t.TempDir(), "Visited Links")
+	if err := os.WriteFile(name, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return name
+}
+
+func TestVisitedLinksIsVisited(t *testing.T) {
+	// The fixture's salt is 01..08. https://example.com/ and
+	// http://a.example/x?y=1 both hash to slot 4 of its 8, so the second is
+	// in slot 5.
+	vl, err := GetVisitedLinks("testdata/Visited Links")
+	if err != nil {
+		t.Fatalf("GetVisitedLinks: %v", err)
+	}
+	if vl.Version != 3 || vl.Length != 8 || vl.Used != 3 {
+		t.Errorf("header = %d, %d, %d; want 3, 8, 3", vl.Version, vl.Length, vl.Used)
+	}
+
+	tests := []struct {
+		url  string
+		want bool
+	}{
+		{"https://example.com/", true},
+		{"https://example.com", true},
+		{"HTTPS://Example.COM:443", true},
+		{"  https://example.com/  ", true},
+		{"http://a.example/x?y=1", true},
+		{"http://a.example:80/x?y=1", true},
+		{"http://example.com/", false},
+		{"https://example.com:8443/", false},
+		{"http://a.example/x?y=2", false},
+		{"not a url", false},
+	}
+	for _, tt := range tests {
+		if got := vl.IsVisited(tt.url); got != tt.want {
+			t.Errorf("IsVisited(%q) = %v, want %v", tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestVisitedLinksFullTable(t *testing.T) {
+	// A table with no empty slots must still stop probing.
+	data := binary.LittleEndian.AppendUint32(nil, visitedLinksSignature)
+	data = binary.LittleEndian.AppendUint32(data, 3)
+	data = binary.LittleEndian.AppendUint32(data, 2)
+	data = binary.LittleEndian.AppendUint32(data, 2)
+	data = append(data, make([]byte, visitedLinksSaltLength)...)
+	data = binary.LittleEndian.AppendUint64(data, 1)
+	data = binary.LittleEndian.AppendUint64(data, 2)
+
+	vl, err := GetVisitedLinks(writeVisitedLinks(t, data))
+	if err != nil {
+		t.Fatalf("GetVisitedLinks: %v", err)
+	}
+	if vl.IsVisited("https://example.com/") {
+		t.Error("IsVisited in a full table without the URL = true")
+	}
+}
+
+func TestGetVisitedLinksErrors(t *testing.T) {
+	header := func(sig, length uint32) []byte {
+		b := binary.LittleEndian.AppendUint32(nil, sig)
+		b = binary.LittleEndian.AppendUint32(b, 3)
+		b = binary.LittleEndian.AppendUint32(b, length)
+		b = binary.LittleEndian.AppendUint32(b, 0)
+		return append(b, make([]byte, visitedLinksSaltLength)...)
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"too short", []byte("VLnk")},
+		{"bad signature", header(0x12345678, 1)},
+		{"empty table", header(visitedLinksSignature, 0)},
+		{"truncated table", append(header(visitedLinksSignature, 2), make([]byte, 8)...)},
+		{"huge table", header(visitedLinksSignature, 0xFFFFFFFF)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := GetVisitedLinks(writeVisitedLinks(t, tt.data)); err == nil {
+				t.Error("GetVisitedLinks succeeded, want error")
+			}
+		})
+	}
+}
@@ -0,0 +1,439 @@
+package chromedb
+
+import (
+	"fmt"
+	"io/fs"
+	"time"
+)
+
+// Names of the autofill field types used in address type tokens.
+var webDataFieldTypes = map[int64]string{
+	3:  "name_first",
+	4:  "name_middle",
+	5:  "name_last",
+	7:  "name_full",
+	9:  "email_address",
+	14: "phone_home_whole_number",
+	30: "address_home_line1",
+	31: "address_home_line2",
+	33: "address_home_city",
+	34: "address_home_state",
+	35: "address_home_zip",
+	36: "address_home_country",
+	60: "company_name",
+	77: "address_home_street_address",
+	78: "address_home_sorting_code",
+	79: "address_home_dependent_locality",
+	80: "address_home_line3",
+}
+
+// Field type names for the columns of the pre-2023 autofill_profiles table
+// and its side tables, so both address schemas produce the same fields.
+var webDataProfileColumns = map[string]string{
+	"company_name":       "company_name",
+	"street_address":     "address_home_street_address",
+	"dependent_locality": "address_home_dependent_locality",
+	"city":               "address_home_city",
+	"state":              "address_home_state",
+	"zipcode":            "address_home_zip",
+	"sorting_code":       "address_home_sorting_code",
+	"country_code":       "address_home_country",
+	"full_name":          "name_full",
+	"email":              "email_address",
+	"number":             "phone_home_whole_number",
+}
+
+type AutofillEntry struct {
+	Name         string    `json:"name"`
+	Value        string    `json:"value"`
+	DateCreated  time.Time `json:"date_created"`
+	DateLastUsed time.Time `json:"date_last_used"`
+	Count        int64     `json:"count"`
+}
+
+type Address struct {
+	GUID         string            `json:"guid"`
+	Fields       map[string]string `json:"fields"`
+	UseCount     int64             `json:"use_count"`
+	UseDate      time.Time         `json:"use_date"`
+	DateModified time.Time         `json:"date_modified"`
+}
+
+type CreditCard struct {
+	GUID                string    `json:"guid"`
+	NameOnCard          string    `json:"name_on_card"`
+	ExpirationMonth     int64     `json:"expiration_month"`
+	ExpirationYear      int64     `json:"expiration_year"`
+	EncryptedCardNumber []byte    `json:"encrypted_card_number"`
+	CardNumber          string    `json:"card_number"`
+	Nickname            string    `json:"nickname,omitempty"`
+	UseCount            int64     `json:"use_count"`
+	UseDate             time.Time `json:"use_date"`
+	DateModified        time.Time `json:"date_modified"`
+}
+
+type Keyword struct {
+	ID           int64     `json:"id"`
+	ShortName    string    `json:"short_name"`
+	Keyword      string    `json:"keyword"`
+	URL          string    `json:"url"`
+	FaviconURL   string    `json:"favicon_url"`
+	DateCreated  time.Time `json:"date_created"`
+	LastModified time.Time `json:"last_modified"`
+}
+
+type Token struct {
+	Service        string `json:"service"`
+	EncryptedToken []byte `json:"encrypted_token"`
+	Token          string `json:"token"`
+}
+
+type WebData struct {
+	Autofill    []AutofillEntry
+	Addresses   []Address
+	CreditCards []CreditCard
+	Keywords    []Keyword
+	Tokens      []Token
+}
+
+// GetWebData reads autofill entries, addresses, payment cards, search engine
+// keywords and OAuth tokens from a profile's Web Data database. Card numbers
+// and tokens are left encrypted; decrypt them with Decrypt.
+func GetWebData(webDataPath string) (*WebData, error) {
+	return GetWebDataFS(osFS(webDataPath))
+}
+
+// GetWebDataFS is GetWebData for a Web Data database in fsys.
+func GetWebDataFS(fsys fs.FS, name string) (*WebData, error) {
+	db, err := openSQLite(fsys, name)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	wd := &WebData{}
+	if wd.Autofill, err = getAutofillEntries(db); err != nil {
+		return nil, fmt.Errorf("failed to read autofill: %w", err)
+	}
+	if wd.Addresses, err = getAddresses(db); err != nil {
+		return nil, fmt.Errorf("failed to read addresses: %w", err)
+	}
+	if wd.CreditCards, err = getCreditCards(db); err != nil {
+		return nil, fmt.Errorf("failed to read credit cards: %w", err)
+	}
+	if wd.Keywords, err = getKeywords(db); err != nil {
+		return nil, fmt.Errorf("failed to read keywords: %w", err)
+	}
+	if wd.Tokens, err = getTokens(db); err != nil {
+		return nil, fmt.Errorf("failed to read token service: %w", err)
+	}
+	return wd, nil
+}
+
+func getAutofillEntries(db *sqliteDB) ([]AutofillEntry, error) {
+	cols, err := sqliteColumns(db, "autofill")
+	if err != nil || cols == nil {
+		return nil, err
+	}
+
+	query := "SELECT " + selectColumns(cols, "",
+		[2]string{"name", "''"},
+		[2]string{"value", "''"},
+		[2]string{"date_created", "0"},
+		[2]string{"date_last_used", "0"},
+		[2]string{"count", "0"},
+	) + " FROM autofill"
+	rows, err := db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []AutofillEntry
+	for rows.Next() {
+		var (
+			e                 AutofillEntry
+			created, lastUsed int64
+		)
+		if err := rows.Scan(&e.Name, &e.Value, &created, &lastUsed, &e.Count); err != nil {
+			return nil, err
+		}
+		e.DateCreated = unixTime(created)
+		e.DateLastUsed = unixTime(lastUsed)
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+// getAddresses reads addresses from whichever schema the profile has: the
+// type token tables used since 2023, or the older autofill_profiles table and
+// its name, email and phone side tables.
+func getAddresses(db *sqliteDB) ([]Address, error) {
+	for _, t := range [][2]string{
+		{"local_addresses", "local_addresses_type_tokens"},
+		{"addresses", "address_type_tokens"},
+	} {
+		cols, err := sqliteColumns(db, t[0])
+		if err != nil {
+			return nil, err
+		}
+		if cols != nil {
+			return getAddressesFromTokens(db, cols, t[0], t[1])
+		}
+	}
+
+	cols, err := sqliteColumns(db, "autofill_profiles")
+	if err != nil || cols == nil {
+		return nil, err
+	}
+	return getAddressesFromProfiles(db, cols)
+}
+
+func getAddressesFromTokens(db *sqliteDB, cols map[string]bool, table, tokenTable string) ([]Address, error) {
+	query := "SELECT guid, " + selectColumns(cols, "",
+		[2]string{"use_count", "0"},
+		[2]string{"use_date", "0"},
+		[2]string{"date_modified", "0"},
+	) + " FROM " + table
+	rows, err := db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var addresses []Address
+	index := map[string]int{}
+	for rows.Next() {
+		var (
+			a                 Address
+			useDate, modified int64
+		)
+		if err := rows.Scan(&a.GUID, &a.UseCount, &useDate, &modified); err != nil {
+			return nil, err
+		}
+		a.Fields = map[string]string{}
+		a.UseDate = unixTime(useDate)
+		a.DateModified = unixTime(modified)
+		index[a.GUID] = len(addresses)
+		addresses = append(addresses, a)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	tokens, err := db.Query("SELECT guid, type, COALESCE(value, '') FROM " + tokenTable)
+	if err != nil {
+		return nil, err
+	}
+	defer tokens.Close()
+	for tokens.Next() {
+		var (
+			guid, value string
+			fieldType   int64
+		)
+		if err := tokens.Scan(&guid, &fieldType, &value); err != nil {
+			return nil, err
+		}
+		i, ok := index[guid]
+		if !ok || value == "" {
+			continue
+		}
+		name, ok := webDataFieldTypes[fieldType]
+		if !ok {
+			name = fmt.Sprintf("type_%d", fieldType)
+		}
+		addresses[i].Fields[name] = value
+	}
+	return addresses, tokens.Err()
+}
+
+func getAddressesFromProfiles(db *sqliteDB, cols map[string]bool) ([]Address, error) {
+	fields := [][2]string{
+		{"company_name", "''"},
+		{"street_address", "''"},
+		{"dependent_locality", "''"},
+		{"city", "''"},
+		{"state", "''"},
+		{"zipcode", "''"},
+		{"sorting_code", "''"},
+		{"country_code", "''"},
+	}
+	query := "SELECT guid, " + selectColumns(cols, "",
+		[2]string{"use_count", "0"},
+		[2]string{"use_date", "0"},
+		[2]string{"date_modified", "0"},
+	) + ", " + selectColumns(cols, "", fields...) + " FROM autofill_profiles"
+	rows, err := db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var addresses []Address
+	index := map[string]int{}
+	for rows.Next() {
+		var (
+			a                 Address
+			useDate, modified int64
+		)
+		values := make([]string, len(fields))
+		dest := []interface{}{&a.GUID, &a.UseCount, &useDate, &modified}
+		for i := range values {
+			dest = append(dest, &values[i])
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		a.Fields = map[string]string{}
+		for i, f := range fields {
+			if values[i] != "" {
+				a.Fields[webDataProfileColumns[f[0]]] = values[i]
+			}
+		}
+		a.UseDate = unixTime(useDate)
+		a.DateModified = unixTime(modified)
+		index[a.GUID] = len(addresses)
+		addresses = append(addresses, a)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Names, emails and phone numbers live in side tables keyed by guid.
+	for _, side := range [][2]string{
+		{"autofill_profile_names", "full_name"},
+		{"autofill_profile_emails", "email"},
+		{"autofill_profile_phones", "number"},
+	} {
+		sideCols, err := sqliteColumns(db, side[0])
+		if err != nil {
+			return nil, err
+		}
+		if !sideCols[side[1]] {
+			continue
+		}
+		err = func() error {
+			rows, err := db.Query(fmt.Sprintf("SELECT guid, COALESCE(%s, '') FROM %s", side[1], side[0]))
+			if err != nil {
+				return err
+			}
+			defer rows.Close()
+			for rows.Next() {
+				var guid, value string
+				if err := rows.Scan(&guid, &value); err != nil {
+					return err
+				}
+				if i, ok := index[guid]; ok && value != "" {
+					addresses[i].Fields[webDataProfileColumns[side[1]]] = value
+				}
+			}
+			return rows.Err()
+		}()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return addresses, nil
+}
+
+func getCreditCards(db *sqliteDB) ([]CreditCard, error) {
+	cols, err := sqliteColumns(db, "credit_cards")
+	if err != nil || cols == nil {
+		return nil, err
+	}
+
+	query := "SELECT " + selectColumns(cols, "",
+		[2]string{"guid", "''"},
+		[2]string{"name_on_card", "''"},
+		[2]string{"expiration_month", "0"},
+		[2]string{"expiration_year", "0"},
+		[2]string{"card_number_encrypted", "NULL"},
+		[2]string{"nickname", "''"},
+		[2]string{"use_count", "0"},
+		[2]string{"use_date", "0"},
+		[2]string{"date_modified", "0"},
+	) + " FROM credit_cards"
+	rows, err := db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var cards []CreditCard
+	for rows.Next() {
+		var (
+			c                 CreditCard
+			useDate, modified int64
+		)
+		err := rows.Scan(&c.GUID, &c.NameOnCard, &c.ExpirationMonth, &c.ExpirationYear,
+			&c.EncryptedCardNumber, &c.Nickname, &c.UseCount, &useDate, &modified)
+		if err != nil {
+			return nil, err
+		}
+		c.UseDate = unixTime(useDate)
+		c.DateModified = unixTime(modified)
+		cards = append(cards, c)
+	}
+	return cards, rows.Err()
+}
+
+func getKeywords(db *sqliteDB) ([]Keyword, error) {
+	cols, err := sqliteColumns(db, "keywords")
+	if err != nil || cols == nil {
+		return nil, err
+	}
+
+	query := "SELECT " + selectColumns(cols, "",
+		[2]string{"id", "0"},
+		[2]string{"short_name", "''"},
+		[2]string{"keyword", "''"},
+		[2]string{"url", "''"},
+		[2]string{"favicon_url", "''"},
+		[2]string{"date_created", "0"},
+		[2]string{"last_modified", "0"},
+	) + " FROM keywords"
+	rows, err := db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keywords []Keyword
+	for rows.Next() {
+		var (
+			k                 Keyword
+			created, modified int64
+		)
+		err := rows.Scan(&k.ID, &k.ShortName, &k.Keyword, &k.URL, &k.FaviconURL, &created, &modified)
+		if err != nil {
+			return nil, err
+		}
+		k.DateCreated = chromeTime(created)
+		k.LastModified = chromeTime(modified)
+		keywords = append(keywords, k)
+	}
+	return keywords, rows.Err()
+}
+
+func getTokens(db *sqliteDB) ([]Token, error) {
+	cols, err := sqliteColumns(db, "token_service")
+	if err != nil || cols == nil {
+		return nil, err
+	}
+
+	rows, err := db.Query("SELECT service, encrypted_token FROM token_service")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tokens []Token
+	for rows.Next() {
+		var t Token
+		if err := rows.Scan(&t.Service, &t.EncryptedToken); err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, t)
+	}
+	return tokens, rows.Err()
+}
@@ -0,0 +1,217 @@
+package chromedb
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"strconv"
+	"strings"
+)
+
+type WebSQLValue struct {
+	Decoded     string          `json:"-"`
+	MIME        string          `json:"mime"`
+	Conversions []string        `json:"conversions"`
+	JsonType    string          `json:"-"`
+	Value       json.RawMessage `json:"value"`
+	null        bool
+}
+
+type WebSQLRecord struct {
+	Origin     string                 `json:"origin"`
+	DatabaseID int64                  `json:"database_id"`
+	Database   string                 `json:"database"`
+	Table      string                 `json:"table"`
+	Row        int                    `json:"row"`
+	Values     map[string]WebSQLValue `json:"values"`
+	// DecodeError is set, with no values, for a database or table that
+	// couldn't be read.
+	DecodeError string `json:"decode_error,omitempty"`
+}
+
+type WebSQLDb struct {
+	Records []WebSQLRecord `json:"records"`
+}
+
+// webSQLOrigin turns a database origin identifier, e.g.
+// "https_app.example.com_0", back into an origin. A port of 0 is the
+// scheme's default.
+func webSQLOrigin(identifier string) string {
+	scheme, rest, ok := strings.Cut(identifier, "_")
+	if !ok {
+		return identifier
+	}
+	i := strings.LastIndexByte(rest, '_')
+	if i < 0 {
+		return identifier
+	}
+	host, port := rest[:i], rest[i+1:]
+	if port == "0" {
+		return scheme + "://" + host
+	}
+	return scheme + "://" + host + ":" + port
+}
+
+// LoadWebSQL reads every Web SQL database in dir, a profile's databases
+// directory. Databases.db maps each database to its origin and name; the
+// databases themselves are SQLite files at <origin>/<id>. Every row of every
+// table is returned; a database or table that can't be read is returned as a
+// record with a DecodeError.
+func LoadWebSQL(dir string) (*WebSQLDb, error) {
+	return LoadWebSQLFS(osFS(dir))
+}
+
+// LoadWebSQLFS is LoadWebSQL for a databases directory in fsys.
+func LoadWebSQLFS(fsys fs.FS, dir string) (*WebSQLDb, error) {
+	index, err := openSQLite(fsys, path.Join(dir, "Databases.db"))
+	if err != nil {
+		return nil, err
+	}
+	defer index.Close()
+
+	rows, err := index.Query("SELECT id, origin, name FROM Databases ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	wsd := &WebSQLDb{}
+	for rows.Next() {
+		var (
+			id           int64
+			origin, name string
+		)
+		if err := rows.Scan(&id, &origin, &name); err != nil {
+			return nil, err
+		}
+
+		dbName := path.Join(dir, origin, strconv.FormatInt(id, 10))
+		if _, err := fs.Stat(fsys, dbName); errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		records, err := readWebSQLDatabase(fsys, dbName)
+		if err != nil {
+			records = []WebSQLRecord{{DecodeError: fmt.Sprintf("failed to read database: %v", err)}}
+		}
+		for _, r := range records {
+			r.Origin = webSQLOrigin(origin)
+			r.DatabaseID = id
+			r.Database = name
+			wsd.Records = append(wsd.Records, r)
+		}
+	}
+	return wsd, rows.Err()
+}
+
+func readWebSQLDatabase(fsys fs.FS, name string) ([]WebSQLRecord, error) {
+	db, err := openSQLite(fsys, name)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	// Skip SQLite's own tables and the one the browser adds to each database.
+	tables, err := db.Query(`SELECT name FROM sqlite_master WHERE type = 'table'
+		AND name NOT LIKE 'sqlite_%' AND name != '__WebKitDatabaseInfoTable__'
+		ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for tables.Next() {
+		var name string
+		if err := tables.Scan(&name); err != nil {
+			tables.Close()
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	tables.Close()
+	if err := tables.Err(); err != nil {
+		return nil, err
+	}
+
+	var records []WebSQLRecord
+	for _, table := range names {
+		tableRecords, err := readWebSQLTable(db, table)
+		if err != nil {
+			records = append(records, WebSQLRecord{Table: table, DecodeError: fmt.Sprintf("failed to read table: %v", err)})
+			continue
+		}
+		records = append(records, tableRecords...)
+	}
+	return records, nil
+}
+
+func readWebSQLTable(db *sqliteDB, table string) ([]WebSQLRecord, error) {
+	rows, err := db.Query("SELECT * FROM " + quoteSQLIdentifier(table))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	cols, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+
+	var records []WebSQLRecord
+	for row := 0; rows.Next(); row++ {
+		values := make([]interface{}, len(cols))
+		dest := make([]interface{}, len(cols))
+		for i := range values {
+			dest[i] = &values[i]
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+
+		r := WebSQLRecord{Table: table, Row: row, Values: map[string]WebSQLValue{}}
+		for i, col := range cols {
+			var v WebSQLValue
+			switch val := values[i].(type) {
+			case nil:
+				v.null = true
+			case []byte:
+				v.Decoded = string(val)
+			case string:
+				v.Decoded = val
+			default:
+				v.Decoded = fmt.Sprint(val)
+			}
+			r.Values[col] = v
+		}
+		records = append(records, r)
+	}
+	return records, rows.Err()
+}
+
+func WebSQLRecordToJson(r WebSQLRecord) (string, error) {
+	values := make(map[string]WebSQLValue, len(r.Values))
+	for col, v := range r.Values {
+		if v.null {
+			v.Conversions = []string{}
+			v.Value = json.RawMessage("null")
+		} else {
+			cv, err := classifyValue(v.Decoded)
+			if err != nil {
+				return "", err
+			}
+			v.MIME = cv.MIME
+			v.Conversions = cv.Conversions
+			v.Value = cv.Value
+			v.JsonType = cv.JsonType
+		}
+		values[col] = v
+	}
+	r.Values = values
+
+	recordJson, err := json.Marshal(r)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal record to JSON: %w", err)
+	}
+
+	return string(recordJson), nil
+}